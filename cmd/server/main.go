@@ -1,22 +1,45 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
 	"social-network/internal/auth"
 	"social-network/internal/handler"
 	"social-network/internal/middleware"
+	"social-network/internal/realtime"
 	"social-network/internal/service"
 	"social-network/pkg/db/sqlite"
 	"strings"
+	"syscall"
+	"time"
 )
 
 func main() {
 	migrateDown := flag.Bool("down", false, "Run down migrations")
 	steps := flag.Int("steps", 0, "Number of migration steps (positive for up, negative for down)")
+	wsPingInterval := flag.Duration("ws-ping-interval", realtime.DefaultConfig.PingInterval, "Interval between WebSocket pings")
+	wsPongWait := flag.Duration("ws-pong-wait", realtime.DefaultConfig.PongWait, "Time a WebSocket may stay silent before it is closed")
+	wsWriteWait := flag.Duration("ws-write-wait", realtime.DefaultConfig.WriteWait, "Time allowed to write a WebSocket frame")
+	chatEditWindow := flag.Duration("chat-edit-window", service.DefaultChatConfig.EditWindow, "Time after sending during which a chat message may be edited or deleted")
+	chatMaxAttachmentSize := flag.Int64("chat-max-attachment-size", service.DefaultChatConfig.MaxAttachmentSize, "Largest chat attachment accepted, in bytes")
+	chatAttachmentTypes := flag.String("chat-attachment-types", strings.Join(service.DefaultChatConfig.AttachmentTypes, ","), "Comma-separated MIME types accepted as chat attachments besides images")
+	chatAttachmentDir := flag.String("chat-attachment-dir", service.DefaultChatConfig.AttachmentDir, "Directory chat attachments are stored in; must not be under ./uploads")
+	trustedProxiesFlag := flag.String("trusted-proxies", "", "Comma-separated addresses or CIDR ranges of reverse proxies whose X-Forwarded-For header is trusted")
 	flag.Parse()
 
+	if *wsPingInterval >= *wsPongWait {
+		log.Fatal("ws-ping-interval must be shorter than ws-pong-wait")
+	}
+	trustedProxies, err := handler.ParseTrustedProxies(*trustedProxiesFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	dbPath := "./data/social_network.db"
 	migrationsPath := "./pkg/db/migrations/sqlite"
 
@@ -50,20 +73,47 @@ func main() {
 	}
 
 	// Initialize services and middleware
-	sessionManager := auth.NewSessionManager()
+	sessionManager := auth.NewSessionManager(auth.NewSQLiteSessionStore(db.DB), true, auth.DefaultSessionPolicy)
+	sessionManager.StartCleanup(time.Hour)
+	defer sessionManager.Stop()
 	authService := service.NewAuthService(db.DB)
 	authMiddleware := middleware.NewAuthMiddleware(sessionManager)
-	postService := service.NewPostService(db.DB)
 	userService := service.NewUserService(db.DB)
-	notificationService := service.NewNotificationService(db.DB)
-	chatService := service.NewChatService(db.DB, notificationService)
+	hubConfig := realtime.DefaultConfig
+	hubConfig.PingInterval = *wsPingInterval
+	hubConfig.PongWait = *wsPongWait
+	hubConfig.WriteWait = *wsWriteWait
+	hub := realtime.NewHub(hubConfig)
+	notificationService := service.NewNotificationService(db.DB, hub)
+	postService := service.NewPostService(db.DB, notificationService)
+	chatConfig := service.DefaultChatConfig
+	chatConfig.EditWindow = *chatEditWindow
+	chatConfig.MaxAttachmentSize = *chatMaxAttachmentSize
+	chatConfig.AttachmentTypes = nil
+	for _, mimeType := range strings.Split(*chatAttachmentTypes, ",") {
+		if mimeType = strings.TrimSpace(mimeType); mimeType != "" {
+			chatConfig.AttachmentTypes = append(chatConfig.AttachmentTypes, mimeType)
+		}
+	}
+	chatConfig.AttachmentDir = *chatAttachmentDir
+	if err := moveLegacyAttachments(chatConfig.AttachmentDir); err != nil {
+		log.Fatal(err)
+	}
+	chatService := service.NewChatService(db.DB, hub, notificationService, chatConfig)
 	followerService := service.NewFollowerService(db.DB, notificationService)
 	groupService := service.NewGroupService(db.DB, notificationService)
-	webSocketHandler := handler.NewWebSocketHandler(notificationService, chatService)
+	presenceService := service.NewPresenceService(db.DB, hub)
+	reactionService := service.NewReactionService(db.DB, hub, postService)
+	profileService := service.NewProfileService(db.DB, userService, postService)
+	hub.OnPresenceChange(presenceService.HandlePresenceChange)
+	hub.OnSessionActivity(sessionManager.TouchSession)
+	webSocketHandler := handler.NewWebSocketHandler(hub, notificationService, chatService, presenceService)
 	// Initialize handlers
 	authHandler := &handler.AuthHandler{
 		AuthService:    authService,
 		SessionManager: sessionManager,
+		Hub:            hub,
+		TrustedProxies: trustedProxies,
 	}
 	postHandler := &handler.PostHandler{
 		PostService: postService,
@@ -83,6 +133,15 @@ func main() {
 	chatHandler := &handler.ChatHandler{
 		ChatService: chatService,
 	}
+	presenceHandler := &handler.PresenceHandler{
+		PresenceService: presenceService,
+	}
+	reactionHandler := &handler.ReactionHandler{
+		ReactionService: reactionService,
+	}
+	profileHandler := &handler.ProfileHandler{
+		ProfileService: profileService,
+	}
 
 	// Setup routes
 	router := http.NewServeMux()
@@ -90,14 +149,21 @@ func main() {
 	router.HandleFunc("/login", authHandler.Login)
 	router.HandleFunc("/logout", authMiddleware.RequireAuth(authHandler.Logout))
 	router.HandleFunc("/auth", authHandler.VerifySession)
+	router.HandleFunc("/sessions", authMiddleware.RequireAuth(authHandler.GetSessions))
+	router.HandleFunc("/sessions/revoke", authMiddleware.RequireAuth(authHandler.RevokeSession))
+	router.HandleFunc("/sessions/revoke-others", authMiddleware.RequireAuth(authHandler.RevokeOtherSessions))
 
-	// Static file server for uploads
-	fs := http.FileServer(http.Dir("."))
-	router.Handle("/uploads/", http.StripPrefix("/", fs))
+	// Static file server for uploads. Nothing outside ./uploads is reachable
+	// through it.
+	fs := http.FileServer(http.Dir("./uploads"))
+	router.Handle("/uploads/", http.StripPrefix("/uploads/", fs))
+	// Chat attachments are only served to participants of the conversation
+	router.HandleFunc(service.AttachmentPathPrefix, authMiddleware.RequireAuth(chatHandler.GetAttachment))
 
 	// Post routes
 	router.HandleFunc("/posts", authMiddleware.RequireAuth(postHandler.CreatePost))
 	router.HandleFunc("/posts/public", authMiddleware.RequireAuth(postHandler.GetPublicPosts))
+	router.HandleFunc("/feed", authMiddleware.RequireAuth(postHandler.GetFeed))
 	router.HandleFunc("/posts/", authMiddleware.RequireAuth(func(w http.ResponseWriter, r *http.Request) {
 		if strings.HasSuffix(r.URL.Path, "/comments") {
 			if r.Method == http.MethodPost {
@@ -110,6 +176,14 @@ func main() {
 			}
 		}
 		if strings.Contains(r.URL.Path, "/comments/") {
+			if strings.HasSuffix(r.URL.Path, "/edits") {
+				postHandler.GetCommentEdits(w, r)
+				return
+			}
+			if r.Method == http.MethodPut {
+				postHandler.EditComment(w, r)
+				return
+			}
 			if r.Method == http.MethodDelete {
 				postHandler.DeleteComment(w, r)
 				return
@@ -148,6 +222,7 @@ func main() {
 	router.HandleFunc("/groups/invites/respond", authMiddleware.RequireAuth(groupHandler.HandleInviteResponse))
 	router.HandleFunc("/groups/posts", authMiddleware.RequireAuth(groupHandler.HandlePosts))
 	router.HandleFunc("/groups/posts/comments", authMiddleware.RequireAuth(groupHandler.HandlePostComments))
+	router.HandleFunc("/groups/posts/comments/edits", authMiddleware.RequireAuth(groupHandler.GetPostCommentEdits))
 	router.HandleFunc("/groups/events", authMiddleware.RequireAuth(groupHandler.HandleEvents))
 	router.HandleFunc("/groups/events/respond", authMiddleware.RequireAuth(groupHandler.HandleEventResponse))
 	router.HandleFunc("/groups/events/responses", authMiddleware.RequireAuth(groupHandler.GetEventResponses))
@@ -159,11 +234,20 @@ func main() {
 	router.HandleFunc("/chat/group/send", authMiddleware.RequireAuth(chatHandler.SendGroupMessage))
 	router.HandleFunc("/chat/unread", authMiddleware.RequireAuth(chatHandler.GetUnreadMessageSenders))
 	router.HandleFunc("/chat/mark-read", authMiddleware.RequireAuth(chatHandler.MarkMessagesRead))
+	router.HandleFunc("/chat/conversations", authMiddleware.RequireAuth(chatHandler.GetConversations))
+	router.HandleFunc("/chat/messages/edit", authMiddleware.RequireAuth(chatHandler.EditMessage))
+	router.HandleFunc("/chat/messages/delete", authMiddleware.RequireAuth(chatHandler.DeleteMessage))
+	router.HandleFunc("/chat/messages/edits", authMiddleware.RequireAuth(chatHandler.GetMessageEdits))
+	router.HandleFunc("/presence", authMiddleware.RequireAuth(presenceHandler.GetPresence))
+
+	// Reaction routes
+	router.HandleFunc("/reactions", authMiddleware.RequireAuth(reactionHandler.HandleReactions))
 
 	// User routes
-	router.HandleFunc("/users", userHandler.GetAllUsers)
-	router.HandleFunc("/users/", userHandler.GetUserByUUID)
+	router.HandleFunc("/users", authMiddleware.RequireAuth(userHandler.GetAllUsers))
+	router.HandleFunc("/users/", authMiddleware.RequireAuth(userHandler.GetUserByUUID))
 	router.HandleFunc("/users/posts", authMiddleware.RequireAuth(postHandler.GetUserPosts))
+	router.HandleFunc("/users/profile", authMiddleware.RequireAuth(profileHandler.GetProfile))
 	router.HandleFunc("/users/visibility", authMiddleware.RequireAuth(userHandler.GetProfileVisibility))
 	router.HandleFunc("/users/visibility/update", authMiddleware.RequireAuth(userHandler.UpdateProfileVisibility))
 
@@ -173,8 +257,62 @@ func main() {
 	// Notifications
 	router.HandleFunc("/notifications", authMiddleware.RequireAuth(notificationHandler.GetNotifications))
 	router.HandleFunc("/notifications/read", authMiddleware.RequireAuth(notificationHandler.MarkAsRead))
+	router.HandleFunc("/notifications/read-all", authMiddleware.RequireAuth(notificationHandler.MarkAllAsRead))
+	router.HandleFunc("/notifications/delete", authMiddleware.RequireAuth(notificationHandler.DeleteNotifications))
+	router.HandleFunc("/notifications/unread-count", authMiddleware.RequireAuth(notificationHandler.GetUnreadCount))
+	router.HandleFunc("/notifications/preferences", authMiddleware.RequireAuth(notificationHandler.HandlePreferences))
+	router.HandleFunc("/notifications/mutes", authMiddleware.RequireAuth(notificationHandler.HandleMutes))
 
 	// Start server
-	log.Printf("Server starting on :8080")
-	log.Fatal(http.ListenAndServe(":8080", middleware.CORS(router)))
+	server := &http.Server{
+		Addr:    ":8080",
+		Handler: middleware.CORS(router),
+	}
+
+	go func() {
+		log.Printf("Server starting on :8080")
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+
+	log.Println("Shutting down server...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Close WebSockets first: hijacked connections are not tracked by
+	// server.Shutdown.
+	if err := hub.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Error closing WebSocket connections: %v", err)
+	}
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Error shutting down server: %v", err)
+	}
+	log.Println("Server stopped")
+}
+
+// moveLegacyAttachments moves chat attachments out of ./uploads/chat, where
+// earlier versions stored them in reach of the public file server, into dir.
+func moveLegacyAttachments(dir string) error {
+	const legacyDir = "./uploads/chat"
+	entries, err := os.ReadDir(legacyDir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := os.Rename(filepath.Join(legacyDir, entry.Name()), filepath.Join(dir, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return os.Remove(legacyDir)
 }
@@ -0,0 +1,36 @@
+package auth
+
+import (
+	"net/http"
+	"time"
+)
+
+const CookieName = "session_id"
+
+// SetCookie writes the session cookie. Without "remember me" it is a browser
+// session cookie, dropped when the browser closes. A remembered session's
+// cookie lasts as long as the session can, since sessions kept alive over
+// WebSocket are refreshed without a response to re-issue the cookie on; the
+// idle expiry is enforced on the server.
+func SetCookie(w http.ResponseWriter, session Session) {
+	cookie := &http.Cookie{
+		Name:     CookieName,
+		Value:    session.ID,
+		Path:     "/",
+		HttpOnly: true,
+	}
+	if session.RememberMe {
+		cookie.Expires = session.AbsoluteExpiresAt
+	}
+	http.SetCookie(w, cookie)
+}
+
+func ClearCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     CookieName,
+		Value:    "",
+		Path:     "/",
+		HttpOnly: true,
+		Expires:  time.Now().Add(-time.Hour),
+	})
+}
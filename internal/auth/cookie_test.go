@@ -0,0 +1,31 @@
+package auth
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSessionCookieLifetime(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	session := Session{
+		ID:                "session",
+		ExpiresAt:         now.Add(time.Hour),
+		AbsoluteExpiresAt: now.Add(72 * time.Hour),
+	}
+
+	w := httptest.NewRecorder()
+	SetCookie(w, session)
+	cookie := w.Result().Cookies()[0]
+	if !cookie.Expires.IsZero() || cookie.MaxAge != 0 {
+		t.Errorf("cookie expires %v with max age %d, want a browser session cookie", cookie.Expires, cookie.MaxAge)
+	}
+
+	session.RememberMe = true
+	w = httptest.NewRecorder()
+	SetCookie(w, session)
+	cookie = w.Result().Cookies()[0]
+	if !cookie.Expires.Equal(session.AbsoluteExpiresAt) {
+		t.Errorf("remember me cookie expires %v, want %v", cookie.Expires, session.AbsoluteExpiresAt)
+	}
+}
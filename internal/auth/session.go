@@ -1,73 +1,352 @@
 package auth
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
 	"social-network/internal/model"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+// SessionPolicy controls how long sessions live. A session expires after
+// IdleTimeout without activity, and never outlives AbsoluteLifetime from its
+// creation no matter how active it is. "Remember me" sessions use the longer
+// RememberMe* durations.
+type SessionPolicy struct {
+	IdleTimeout                time.Duration
+	AbsoluteLifetime           time.Duration
+	RememberMeIdleTimeout      time.Duration
+	RememberMeAbsoluteLifetime time.Duration
+	// RefreshInterval is the minimum time between two expiry refreshes of the
+	// same session, so active users don't cause a write on every request.
+	RefreshInterval time.Duration
+}
+
+var DefaultSessionPolicy = SessionPolicy{
+	IdleTimeout:                24 * time.Hour,
+	AbsoluteLifetime:           7 * 24 * time.Hour,
+	RememberMeIdleTimeout:      30 * 24 * time.Hour,
+	RememberMeAbsoluteLifetime: 90 * 24 * time.Hour,
+	RefreshInterval:            time.Minute,
+}
+
 type Session struct {
-	ID        string
-	UserID    string
-	CreatedAt time.Time
-	ExpiresAt time.Time
+	ID           string    `json:"-"`
+	UserID       string    `json:"user_id"`
+	Device       string    `json:"device"`
+	UserAgent    string    `json:"user_agent"`
+	IPAddress    string    `json:"ip_address"`
+	RememberMe   bool      `json:"remember_me"`
+	CreatedAt    time.Time `json:"created_at"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	LastActiveAt time.Time `json:"last_active_at"`
+	// AbsoluteExpiresAt caps ExpiresAt regardless of activity.
+	AbsoluteExpiresAt time.Time `json:"absolute_expires_at"`
+}
+
+// SessionMetadata describes the client a session was issued to.
+type SessionMetadata struct {
+	UserAgent string
+	IPAddress string
 }
 
+// PublicID identifies a session to its owner without exposing the session
+// token itself, so it is safe to send to the client when listing sessions.
+func (s Session) PublicID() string {
+	sum := sha256.Sum256([]byte(s.ID))
+	return hex.EncodeToString(sum[:16])
+}
+
+// SessionManager issues and validates sessions. Sessions are persisted in the
+// store; the in-memory map is only a read-through cache and is nil when
+// caching is disabled.
 type SessionManager struct {
+	store    SessionStore
+	policy   SessionPolicy
 	sessions map[string]Session
 	mu       sync.RWMutex
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func NewSessionManager(store SessionStore, useCache bool, policy SessionPolicy) *SessionManager {
+	sm := &SessionManager{
+		store:  store,
+		policy: policy,
+		stop:   make(chan struct{}),
+	}
+	if useCache {
+		sm.sessions = make(map[string]Session)
+	}
+	return sm
+}
+
+func (sm *SessionManager) CreateSession(user *model.User, metadata SessionMetadata, rememberMe bool) (Session, error) {
+	now := time.Now()
+	session := Session{
+		ID:                uuid.New().String(),
+		UserID:            user.ID,
+		Device:            deviceFromUserAgent(metadata.UserAgent),
+		UserAgent:         metadata.UserAgent,
+		IPAddress:         metadata.IPAddress,
+		RememberMe:        rememberMe,
+		CreatedAt:         now,
+		AbsoluteExpiresAt: now.Add(sm.absoluteLifetime(rememberMe)),
+		LastActiveAt:      now,
+	}
+	session.ExpiresAt = sm.nextExpiry(session, now)
+
+	if err := sm.store.Save(session); err != nil {
+		return Session{}, err
+	}
+
+	sm.cache(session)
+	return session, nil
+}
+
+// RefreshSession slides the idle expiry of an active session forward, bounded
+// by its absolute lifetime. It reports whether the session was updated, in
+// which case the caller should re-issue the cookie.
+func (sm *SessionManager) RefreshSession(session Session) (Session, bool) {
+	now := time.Now()
+	if now.Sub(session.LastActiveAt) < sm.policy.RefreshInterval {
+		return session, false
+	}
+
+	expiresAt := sm.nextExpiry(session, now)
+	if err := sm.store.Touch(session.ID, now, expiresAt); err != nil {
+		log.Printf("Error refreshing session: %v", err)
+		return session, false
+	}
+
+	session.LastActiveAt = now
+	session.ExpiresAt = expiresAt
+	sm.cache(session)
+	return session, true
+}
+
+// TouchSession refreshes the session with the given ID, if it is still
+// valid, for activity that doesn't pass through the HTTP middleware such as
+// WebSocket traffic.
+func (sm *SessionManager) TouchSession(sessionID string) {
+	if session, ok := sm.GetSession(sessionID); ok {
+		sm.RefreshSession(session)
+	}
 }
 
-func NewSessionManager() *SessionManager {
-	return &SessionManager{
-		sessions: make(map[string]Session),
+func (sm *SessionManager) GetSession(sessionID string) (Session, bool) {
+	session, ok := sm.cached(sessionID)
+	if !ok {
+		var err error
+		session, err = sm.store.Get(sessionID)
+		if err != nil {
+			if err != ErrSessionNotFound {
+				log.Printf("Error loading session: %v", err)
+			}
+			return Session{}, false
+		}
+		sm.cache(session)
+	}
+
+	if time.Now().After(session.ExpiresAt) {
+		sm.DeleteSession(sessionID)
+		return Session{}, false
 	}
+	return session, true
 }
 
-func (sm *SessionManager) CreateSession(user *model.User) (string, error) {
+func (sm *SessionManager) DeleteSession(sessionID string) {
+	sm.mu.Lock()
+	if sm.sessions != nil {
+		delete(sm.sessions, sessionID)
+	}
+	sm.mu.Unlock()
+
+	if err := sm.store.Delete(sessionID); err != nil {
+		log.Printf("Error deleting session: %v", err)
+	}
+}
+
+// GetUserSessions returns the active sessions of a user, most recently used
+// first.
+func (sm *SessionManager) GetUserSessions(userID string) ([]Session, error) {
+	sessions, err := sm.store.GetByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	active := []Session{}
+	for _, session := range sessions {
+		if now.Before(session.ExpiresAt) {
+			active = append(active, session)
+		}
+	}
+	return active, nil
+}
+
+// RevokeSession ends the session with the given public ID, provided it
+// belongs to userID.
+func (sm *SessionManager) RevokeSession(userID string, publicID string) error {
+	sessions, err := sm.store.GetByUser(userID)
+	if err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		if session.PublicID() == publicID {
+			sm.DeleteSession(session.ID)
+			return nil
+		}
+	}
+	return ErrSessionNotFound
+}
+
+// RevokeOtherSessions ends every session of userID except currentSessionID.
+func (sm *SessionManager) RevokeOtherSessions(userID string, currentSessionID string) error {
+	if err := sm.store.DeleteByUserExcept(userID, currentSessionID); err != nil {
+		return err
+	}
+
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
+	for id, session := range sm.sessions {
+		if session.UserID == userID && id != currentSessionID {
+			delete(sm.sessions, id)
+		}
+	}
+	return nil
+}
+
+func (sm *SessionManager) GetAllSessions() map[string]Session {
+	sessions, err := sm.store.GetAll()
+	if err != nil {
+		log.Printf("Error loading sessions: %v", err)
+		return map[string]Session{}
+	}
+
+	result := make(map[string]Session, len(sessions))
+	for _, session := range sessions {
+		result[session.ID] = session
+	}
+	return result
+}
+
+// StartCleanup purges expired sessions from the store and the cache every
+// interval until Stop is called.
+func (sm *SessionManager) StartCleanup(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				sm.purgeExpired()
+			case <-sm.stop:
+				return
+			}
+		}
+	}()
+}
 
+func (sm *SessionManager) Stop() {
+	sm.stopOnce.Do(func() {
+		close(sm.stop)
+	})
+}
+
+func (sm *SessionManager) purgeExpired() {
+	now := time.Now()
+	removed, err := sm.store.DeleteExpired(now)
+	if err != nil {
+		log.Printf("Error purging expired sessions: %v", err)
+		return
+	}
+	if removed > 0 {
+		log.Printf("Purged %d expired sessions", removed)
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
 	for id, session := range sm.sessions {
-		if session.UserID == user.ID {
+		if now.After(session.ExpiresAt) {
 			delete(sm.sessions, id)
 		}
 	}
+}
 
-	sessionID := uuid.New().String()
-	session := Session{
-		ID:        sessionID,
-		UserID:    user.ID,
-		CreatedAt: time.Now(),
-		ExpiresAt: time.Now().Add(24 * time.Hour),
+func (sm *SessionManager) nextExpiry(session Session, now time.Time) time.Time {
+	idleTimeout := sm.policy.IdleTimeout
+	if session.RememberMe {
+		idleTimeout = sm.policy.RememberMeIdleTimeout
 	}
 
-	sm.sessions[sessionID] = session
-	return sessionID, nil
+	expiresAt := now.Add(idleTimeout)
+	if expiresAt.After(session.AbsoluteExpiresAt) {
+		return session.AbsoluteExpiresAt
+	}
+	return expiresAt
 }
 
-func (sm *SessionManager) GetSession(sessionID string) (Session, bool) {
+func (sm *SessionManager) absoluteLifetime(rememberMe bool) time.Duration {
+	if rememberMe {
+		return sm.policy.RememberMeAbsoluteLifetime
+	}
+	return sm.policy.AbsoluteLifetime
+}
+
+func (sm *SessionManager) cached(sessionID string) (Session, bool) {
 	sm.mu.RLock()
 	defer sm.mu.RUnlock()
 
-	session, exists := sm.sessions[sessionID]
-	if !exists || time.Now().After(session.ExpiresAt) {
+	if sm.sessions == nil {
 		return Session{}, false
 	}
-	return session, true
+	session, ok := sm.sessions[sessionID]
+	return session, ok
 }
 
-func (sm *SessionManager) DeleteSession(sessionID string) {
+func (sm *SessionManager) cache(session Session) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
-	delete(sm.sessions, sessionID)
+
+	if sm.sessions != nil {
+		sm.sessions[session.ID] = session
+	}
 }
 
-func (sm *SessionManager) GetAllSessions() map[string]Session {
-	sm.mu.RLock()
-	defer sm.mu.RUnlock()
+func deviceFromUserAgent(userAgent string) string {
+	ua := strings.ToLower(userAgent)
 
-	return sm.sessions
+	var platform string
+	switch {
+	case ua == "":
+		return "Unknown device"
+	case strings.Contains(ua, "ipad") || strings.Contains(ua, "tablet"):
+		platform = "Tablet"
+	case strings.Contains(ua, "iphone") || strings.Contains(ua, "android") || strings.Contains(ua, "mobile"):
+		platform = "Mobile"
+	default:
+		platform = "Desktop"
+	}
+
+	var browser string
+	switch {
+	case strings.Contains(ua, "edg/"):
+		browser = "Edge"
+	case strings.Contains(ua, "firefox/"):
+		browser = "Firefox"
+	case strings.Contains(ua, "chrome/"):
+		browser = "Chrome"
+	case strings.Contains(ua, "safari/"):
+		browser = "Safari"
+	default:
+		return platform
+	}
+	return platform + " - " + browser
 }
@@ -0,0 +1,168 @@
+package auth
+
+import (
+	"social-network/internal/model"
+	"testing"
+	"time"
+)
+
+func TestSessionsPerDevice(t *testing.T) {
+	db := newTestDB(t, "alice", "bob")
+	sm := NewSessionManager(NewSQLiteSessionStore(db), true, DefaultSessionPolicy)
+	alice := &model.User{ID: "alice"}
+
+	laptop, err := sm.CreateSession(alice, SessionMetadata{UserAgent: "Mozilla/5.0 Chrome/120.0 Safari/537.36"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	phone, err := sm.CreateSession(alice, SessionMetadata{UserAgent: "Mozilla/5.0 (iPhone) Mobile Safari/604.1"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tablet, err := sm.CreateSession(alice, SessionMetadata{UserAgent: "Mozilla/5.0 (iPad) Safari/604.1"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := sm.CreateSession(&model.User{ID: "bob"}, SessionMetadata{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Logging in again keeps the earlier sessions
+	sessions, err := sm.GetUserSessions("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 3 {
+		t.Fatalf("alice has %d sessions, want 3", len(sessions))
+	}
+	devices := map[string]bool{}
+	for _, session := range sessions {
+		devices[session.Device] = true
+	}
+	for _, device := range []string{"Desktop - Chrome", "Mobile - Safari", "Tablet - Safari"} {
+		if !devices[device] {
+			t.Errorf("no %q session among %v", device, devices)
+		}
+	}
+
+	// Sessions are revoked by public ID, and only by their owner
+	if err := sm.RevokeSession("bob", phone.PublicID()); err != ErrSessionNotFound {
+		t.Errorf("bob revoking alice's session: %v, want ErrSessionNotFound", err)
+	}
+	if err := sm.RevokeSession("alice", phone.PublicID()); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := sm.GetSession(phone.ID); ok {
+		t.Error("revoked session still valid")
+	}
+
+	if err := sm.RevokeOtherSessions("alice", laptop.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := sm.GetSession(tablet.ID); ok {
+		t.Error("other session still valid after revoking the others")
+	}
+	for _, session := range []Session{laptop, other} {
+		if _, ok := sm.GetSession(session.ID); !ok {
+			t.Errorf("session of %s was revoked too", session.UserID)
+		}
+	}
+}
+
+func TestPublicIDDoesNotRevealSessionID(t *testing.T) {
+	session := Session{ID: "0f8e4c9a-session-token"}
+	if id := session.PublicID(); id == session.ID || len(id) != 32 {
+		t.Errorf("PublicID() = %q", id)
+	}
+	if session.PublicID() != session.PublicID() {
+		t.Error("PublicID is not stable")
+	}
+}
+
+var testPolicy = SessionPolicy{
+	IdleTimeout:                time.Hour,
+	AbsoluteLifetime:           3 * time.Hour,
+	RememberMeIdleTimeout:      24 * time.Hour,
+	RememberMeAbsoluteLifetime: 72 * time.Hour,
+	RefreshInterval:            time.Minute,
+}
+
+// within reports whether got is within a second of want.
+func within(got, want time.Time) bool {
+	d := got.Sub(want)
+	return d > -time.Second && d < time.Second
+}
+
+func TestRememberMeLifetimes(t *testing.T) {
+	db := newTestDB(t, "alice")
+	sm := NewSessionManager(NewSQLiteSessionStore(db), false, testPolicy)
+	now := time.Now()
+
+	short, err := sm.CreateSession(&model.User{ID: "alice"}, SessionMetadata{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	long, err := sm.CreateSession(&model.User{ID: "alice"}, SessionMetadata{}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !within(short.ExpiresAt, now.Add(time.Hour)) || !within(short.AbsoluteExpiresAt, now.Add(3*time.Hour)) {
+		t.Errorf("session expires at %v, absolutely at %v", short.ExpiresAt, short.AbsoluteExpiresAt)
+	}
+	if !within(long.ExpiresAt, now.Add(24*time.Hour)) || !within(long.AbsoluteExpiresAt, now.Add(72*time.Hour)) {
+		t.Errorf("remember me session expires at %v, absolutely at %v", long.ExpiresAt, long.AbsoluteExpiresAt)
+	}
+
+	stored, ok := sm.GetSession(long.ID)
+	if !ok || !stored.RememberMe {
+		t.Errorf("remember me not persisted: %+v", stored)
+	}
+}
+
+func TestRefreshSessionSlidesExpiry(t *testing.T) {
+	db := newTestDB(t, "alice")
+	store := NewSQLiteSessionStore(db)
+	sm := NewSessionManager(store, false, testPolicy)
+	now := time.Now()
+
+	session := Session{
+		ID:                "session",
+		UserID:            "alice",
+		CreatedAt:         now.Add(-2 * time.Hour),
+		LastActiveAt:      now.Add(-30 * time.Minute),
+		ExpiresAt:         now.Add(30 * time.Minute),
+		AbsoluteExpiresAt: now.Add(time.Hour),
+	}
+	if err := store.Save(session); err != nil {
+		t.Fatal(err)
+	}
+
+	refreshed, ok := sm.RefreshSession(session)
+	if !ok {
+		t.Fatal("active session was not refreshed")
+	}
+	if !within(refreshed.ExpiresAt, now.Add(time.Hour)) || !within(refreshed.LastActiveAt, now) {
+		t.Errorf("refreshed session expires at %v, last active %v", refreshed.ExpiresAt, refreshed.LastActiveAt)
+	}
+	stored, err := store.Get(session.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !within(stored.ExpiresAt, refreshed.ExpiresAt) {
+		t.Errorf("stored expiry %v, want %v", stored.ExpiresAt, refreshed.ExpiresAt)
+	}
+
+	// Within the refresh interval nothing is written
+	if _, ok := sm.RefreshSession(refreshed); ok {
+		t.Error("session refreshed again within the refresh interval")
+	}
+
+	// Activity never pushes expiry past the absolute lifetime
+	session.AbsoluteExpiresAt = now.Add(10 * time.Minute)
+	capped, ok := sm.RefreshSession(session)
+	if !ok || !within(capped.ExpiresAt, session.AbsoluteExpiresAt) {
+		t.Errorf("expiry %v, want the absolute expiry %v", capped.ExpiresAt, session.AbsoluteExpiresAt)
+	}
+}
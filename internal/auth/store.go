@@ -0,0 +1,161 @@
+package auth
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// SessionStore persists sessions so they survive server restarts.
+type SessionStore interface {
+	Save(session Session) error
+	Get(sessionID string) (Session, error)
+	GetByUser(userID string) ([]Session, error)
+	Touch(sessionID string, lastActiveAt time.Time, expiresAt time.Time) error
+	Delete(sessionID string) error
+	DeleteByUser(userID string) error
+	DeleteByUserExcept(userID string, keepSessionID string) error
+	DeleteExpired(now time.Time) (int64, error)
+	GetAll() ([]Session, error)
+}
+
+type SQLiteSessionStore struct {
+	db *sql.DB
+}
+
+func NewSQLiteSessionStore(db *sql.DB) *SQLiteSessionStore {
+	return &SQLiteSessionStore{db: db}
+}
+
+const sessionColumns = `id, user_id, device, user_agent, ip_address, remember_me,
+        created_at, expires_at, absolute_expires_at, last_active_at`
+
+func (s *SQLiteSessionStore) Save(session Session) error {
+	_, err := s.db.Exec(`
+        INSERT OR REPLACE INTO sessions (`+sessionColumns+`)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		session.ID, session.UserID, session.Device, session.UserAgent, session.IPAddress,
+		session.RememberMe, session.CreatedAt.UTC(), session.ExpiresAt.UTC(),
+		session.AbsoluteExpiresAt.UTC(), session.LastActiveAt.UTC())
+	return err
+}
+
+func (s *SQLiteSessionStore) Get(sessionID string) (Session, error) {
+	session, err := scanSession(s.db.QueryRow(`
+        SELECT `+sessionColumns+`
+        FROM sessions
+        WHERE id = ?`,
+		sessionID,
+	))
+	if err == sql.ErrNoRows {
+		return Session{}, ErrSessionNotFound
+	}
+	if err != nil {
+		return Session{}, err
+	}
+	return session, nil
+}
+
+func (s *SQLiteSessionStore) GetByUser(userID string) ([]Session, error) {
+	rows, err := s.db.Query(`
+        SELECT `+sessionColumns+`
+        FROM sessions
+        WHERE user_id = ?
+        ORDER BY last_active_at DESC`,
+		userID)
+	if err != nil {
+		return nil, err
+	}
+	return scanSessions(rows)
+}
+
+func (s *SQLiteSessionStore) Touch(sessionID string, lastActiveAt time.Time, expiresAt time.Time) error {
+	_, err := s.db.Exec(`
+        UPDATE sessions
+        SET last_active_at = ?, expires_at = ?
+        WHERE id = ?`,
+		lastActiveAt.UTC(), expiresAt.UTC(), sessionID)
+	return err
+}
+
+func (s *SQLiteSessionStore) Delete(sessionID string) error {
+	_, err := s.db.Exec(`DELETE FROM sessions WHERE id = ?`, sessionID)
+	return err
+}
+
+func (s *SQLiteSessionStore) DeleteByUser(userID string) error {
+	_, err := s.db.Exec(`DELETE FROM sessions WHERE user_id = ?`, userID)
+	return err
+}
+
+func (s *SQLiteSessionStore) DeleteByUserExcept(userID string, keepSessionID string) error {
+	_, err := s.db.Exec(`DELETE FROM sessions WHERE user_id = ? AND id != ?`, userID, keepSessionID)
+	return err
+}
+
+func (s *SQLiteSessionStore) DeleteExpired(now time.Time) (int64, error) {
+	result, err := s.db.Exec(`DELETE FROM sessions WHERE expires_at <= ?`, now.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (s *SQLiteSessionStore) GetAll() ([]Session, error) {
+	rows, err := s.db.Query(`
+        SELECT ` + sessionColumns + `
+        FROM sessions
+        ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	return scanSessions(rows)
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanSession(row rowScanner) (Session, error) {
+	var session Session
+	var device, userAgent, ipAddress sql.NullString
+	var rememberMe sql.NullBool
+	var absoluteExpiresAt, lastActiveAt sql.NullTime
+	err := row.Scan(
+		&session.ID, &session.UserID, &device, &userAgent, &ipAddress, &rememberMe,
+		&session.CreatedAt, &session.ExpiresAt, &absoluteExpiresAt, &lastActiveAt,
+	)
+	if err != nil {
+		return Session{}, err
+	}
+
+	session.Device = device.String
+	session.UserAgent = userAgent.String
+	session.IPAddress = ipAddress.String
+	session.RememberMe = rememberMe.Bool
+	session.AbsoluteExpiresAt = session.ExpiresAt
+	if absoluteExpiresAt.Valid {
+		session.AbsoluteExpiresAt = absoluteExpiresAt.Time
+	}
+	session.LastActiveAt = session.CreatedAt
+	if lastActiveAt.Valid {
+		session.LastActiveAt = lastActiveAt.Time
+	}
+	return session, nil
+}
+
+func scanSessions(rows *sql.Rows) ([]Session, error) {
+	defer rows.Close()
+
+	var sessions []Session
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"database/sql"
+	"fmt"
+	"social-network/internal/model"
+	"social-network/pkg/db/sqlite"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var testDBCount atomic.Int64
+
+// newTestDB returns an in-memory database with every migration applied and
+// the given users.
+func newTestDB(t *testing.T, userIDs ...string) *sql.DB {
+	t.Helper()
+	name := strings.ReplaceAll(t.Name(), "/", "_")
+	db, err := sqlite.New(fmt.Sprintf("file:%s_%d?mode=memory&cache=shared&_busy_timeout=5000",
+		name, testDBCount.Add(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.RunMigrations("../../pkg/db/migrations/sqlite"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	for _, id := range userIDs {
+		_, err := db.Exec(`
+            INSERT INTO users (id, email, password, first_name, last_name, date_of_birth, nickname)
+            VALUES (?, ?, 'x', ?, 'Test', '2000-01-01', ?)`,
+			id, id+"@example.com", id, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db.DB
+}
+
+func TestSessionsSurviveRestart(t *testing.T) {
+	db := newTestDB(t, "alice")
+	user := &model.User{ID: "alice"}
+
+	before := NewSessionManager(NewSQLiteSessionStore(db), true, DefaultSessionPolicy)
+	session, err := before.CreateSession(user, SessionMetadata{UserAgent: "Mozilla/5.0 Firefox/120.0"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before.Stop()
+
+	// A new manager starts with an empty cache, as after a restart
+	after := NewSessionManager(NewSQLiteSessionStore(db), true, DefaultSessionPolicy)
+	got, ok := after.GetSession(session.ID)
+	if !ok {
+		t.Fatal("session lost across restart")
+	}
+	if got.UserID != "alice" || got.Device != "Desktop - Firefox" {
+		t.Errorf("restored session = %+v", got)
+	}
+	if !got.ExpiresAt.Equal(session.ExpiresAt) {
+		t.Errorf("expiry changed from %v to %v", session.ExpiresAt, got.ExpiresAt)
+	}
+}
+
+func TestExpiredSessionsAreRejectedAndPurged(t *testing.T) {
+	db := newTestDB(t, "alice")
+	store := NewSQLiteSessionStore(db)
+	now := time.Now()
+	for id, expiresAt := range map[string]time.Time{
+		"expired": now.Add(-time.Minute),
+		"active":  now.Add(time.Hour),
+	} {
+		err := store.Save(Session{ID: id, UserID: "alice", CreatedAt: now.Add(-time.Hour),
+			ExpiresAt: expiresAt, AbsoluteExpiresAt: expiresAt, LastActiveAt: now.Add(-time.Hour)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sm := NewSessionManager(store, false, DefaultSessionPolicy)
+	if _, ok := sm.GetSession("expired"); ok {
+		t.Error("expired session accepted")
+	}
+	if _, err := store.Get("expired"); err != ErrSessionNotFound {
+		t.Errorf("expired session still stored after lookup: %v", err)
+	}
+
+	if err := store.Save(Session{ID: "stale", UserID: "alice", ExpiresAt: now.Add(-time.Second)}); err != nil {
+		t.Fatal(err)
+	}
+	sm.purgeExpired()
+	if _, err := store.Get("stale"); err != ErrSessionNotFound {
+		t.Errorf("stale session survived the purge: %v", err)
+	}
+	if _, ok := sm.GetSession("active"); !ok {
+		t.Error("active session purged")
+	}
+}
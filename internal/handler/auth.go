@@ -4,12 +4,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"os"
 	"path/filepath"
 	"social-network/internal/auth"
 	"social-network/internal/model"
+	"social-network/internal/realtime"
 	"social-network/internal/service"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -18,6 +21,11 @@ import (
 type AuthHandler struct {
 	AuthService    *service.AuthService
 	SessionManager *auth.SessionManager
+	// Hub, when set, has the WebSocket connections of ended sessions closed.
+	Hub *realtime.Hub
+	// TrustedProxies are the addresses allowed to report the client address
+	// in X-Forwarded-For. The header is ignored from anyone else.
+	TrustedProxies []*net.IPNet
 }
 
 // AuthHandler
@@ -96,19 +104,13 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	sessionID, err := h.SessionManager.CreateSession(user)
+	session, err := h.SessionManager.CreateSession(user, h.sessionMetadata(r), false)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	http.SetCookie(w, &http.Cookie{
-		Name:     "session_id",
-		Value:    sessionID,
-		Path:     "/",
-		HttpOnly: true,
-		Expires:  time.Now().Add(24 * time.Hour),
-	})
+	auth.SetCookie(w, session)
 
 	json.NewEncoder(w).Encode(user)
 }
@@ -131,19 +133,13 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	sessionID, err := h.SessionManager.CreateSession(user)
+	session, err := h.SessionManager.CreateSession(user, h.sessionMetadata(r), input.RememberMe)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	http.SetCookie(w, &http.Cookie{
-		Name:     "session_id",
-		Value:    sessionID,
-		Path:     "/",
-		HttpOnly: true,
-		Expires:  time.Now().Add(24 * time.Hour),
-	})
+	auth.SetCookie(w, session)
 
 	json.NewEncoder(w).Encode(user)
 }
@@ -154,26 +150,22 @@ func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	cookie, err := r.Cookie("session_id")
+	cookie, err := r.Cookie(auth.CookieName)
 	if err != nil {
 		http.Error(w, "No active session", http.StatusUnauthorized)
 		return
 	}
 
-	if _, valid := h.SessionManager.GetSession(cookie.Value); !valid {
+	session, valid := h.SessionManager.GetSession(cookie.Value)
+	if !valid {
 		http.Error(w, "Invalid session", http.StatusUnauthorized)
 		return
 	}
 
 	h.SessionManager.DeleteSession(cookie.Value)
+	h.closeEndedSessions(session.UserID)
 
-	http.SetCookie(w, &http.Cookie{
-		Name:     "session_id",
-		Value:    "",
-		Path:     "/",
-		HttpOnly: true,
-		Expires:  time.Now().Add(-time.Hour),
-	})
+	auth.ClearCookie(w)
 
 	w.WriteHeader(http.StatusOK)
 }
@@ -190,7 +182,7 @@ func (h *AuthHandler) DebugSessions(w http.ResponseWriter, r *http.Request) {
 
 // VerifySession verifies the session and returns user info
 func (h *AuthHandler) VerifySession(w http.ResponseWriter, r *http.Request) {
-	cookie, err := r.Cookie("session_id")
+	cookie, err := r.Cookie(auth.CookieName)
 	if err != nil {
 		http.Error(w, "No active session", http.StatusUnauthorized)
 		return
@@ -208,3 +200,173 @@ func (h *AuthHandler) VerifySession(w http.ResponseWriter, r *http.Request) {
 
 	json.NewEncoder(w).Encode(response)
 }
+
+// GetSessions lists the active sessions of the current user
+func (h *AuthHandler) GetSessions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := r.Context().Value("user_id").(string)
+	currentSessionID := r.Context().Value("session_id").(string)
+
+	sessions, err := h.SessionManager.GetUserSessions(userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	type sessionResponse struct {
+		ID string `json:"id"`
+		auth.Session
+		Current bool `json:"current"`
+	}
+
+	response := make([]sessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		response = append(response, sessionResponse{
+			ID:      session.PublicID(),
+			Session: session,
+			Current: session.ID == currentSessionID,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// RevokeSession ends a single session of the current user
+func (h *AuthHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var input struct {
+		SessionID string `json:"session_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	userID := r.Context().Value("user_id").(string)
+	if err := h.SessionManager.RevokeSession(userID, input.SessionID); err != nil {
+		if err == auth.ErrSessionNotFound {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.closeEndedSessions(userID)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// RevokeOtherSessions ends every session of the current user except this one
+func (h *AuthHandler) RevokeOtherSessions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := r.Context().Value("user_id").(string)
+	currentSessionID := r.Context().Value("session_id").(string)
+	if err := h.SessionManager.RevokeOtherSessions(userID, currentSessionID); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.closeEndedSessions(userID)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// closeEndedSessions closes the WebSocket connections userID opened under
+// sessions that no longer exist.
+func (h *AuthHandler) closeEndedSessions(userID string) {
+	if h.Hub == nil {
+		return
+	}
+	h.Hub.CloseSessions(userID, func(sessionID string) bool {
+		_, valid := h.SessionManager.GetSession(sessionID)
+		return !valid
+	})
+}
+
+func (h *AuthHandler) sessionMetadata(r *http.Request) auth.SessionMetadata {
+	return auth.SessionMetadata{
+		UserAgent: r.UserAgent(),
+		IPAddress: h.clientIP(r),
+	}
+}
+
+// clientIP returns the address of the client that sent r. X-Forwarded-For is
+// only read when the request comes from a trusted proxy, and then from the
+// right, so a client can't choose the address by sending the header itself.
+func (h *AuthHandler) clientIP(r *http.Request) string {
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		ip = host
+	}
+	if !h.trustedProxy(ip) {
+		return ip
+	}
+
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(forwarded[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+		ip = hop
+		if !h.trustedProxy(hop) {
+			break
+		}
+	}
+	return ip
+}
+
+func (h *AuthHandler) trustedProxy(ip string) bool {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+	for _, proxy := range h.TrustedProxies {
+		if proxy.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseTrustedProxies parses a comma-separated list of IP addresses and CIDR
+// ranges, as given to the -trusted-proxies flag.
+func ParseTrustedProxies(list string) ([]*net.IPNet, error) {
+	var proxies []*net.IPNet
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", entry)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, proxy, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+		}
+		proxies = append(proxies, proxy)
+	}
+	return proxies, nil
+}
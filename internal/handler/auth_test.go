@@ -0,0 +1,176 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"social-network/internal/auth"
+	"social-network/internal/middleware"
+	"social-network/internal/model"
+	"social-network/internal/realtime"
+	"social-network/internal/service"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestClientIPTrustsForwardedForFromProxiesOnly(t *testing.T) {
+	proxies, err := ParseTrustedProxies("10.0.0.1, 192.168.0.0/16")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &AuthHandler{TrustedProxies: proxies}
+
+	tests := []struct {
+		name, remoteAddr, forwarded, want string
+	}{
+		{"direct client", "203.0.113.7:5000", "", "203.0.113.7"},
+		{"spoofed by a direct client", "203.0.113.7:5000", "198.51.100.1", "203.0.113.7"},
+		{"through a proxy", "10.0.0.1:5000", "198.51.100.1", "198.51.100.1"},
+		{"spoofed through a proxy", "10.0.0.1:5000", "1.2.3.4, 198.51.100.1", "198.51.100.1"},
+		{"through a chain of proxies", "10.0.0.1:5000", "198.51.100.1, 192.168.1.2", "198.51.100.1"},
+		{"garbage through a proxy", "10.0.0.1:5000", "not an address", "10.0.0.1"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/login", nil)
+		r.RemoteAddr = tt.remoteAddr
+		if tt.forwarded != "" {
+			r.Header.Set("X-Forwarded-For", tt.forwarded)
+		}
+		if got := h.clientIP(r); got != tt.want {
+			t.Errorf("%s: clientIP = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	if _, err := ParseTrustedProxies("10.0.0.1, proxy.local"); err == nil {
+		t.Error("ParseTrustedProxies accepted a host name")
+	}
+}
+
+func TestRevokedSessionsLoseTheirWebSockets(t *testing.T) {
+	db := newTestDB(t)
+	seedUsers(t, db, "alice")
+	hub := realtime.NewHub(realtime.DefaultConfig)
+	sessions := auth.NewSessionManager(auth.NewSQLiteSessionStore(db), false, auth.DefaultSessionPolicy)
+	requireAuth := middleware.NewAuthMiddleware(sessions).RequireAuth
+	h := &AuthHandler{SessionManager: sessions, Hub: hub}
+	ws := NewWebSocketHandler(hub, service.NewNotificationService(db, hub), nil, nil)
+
+	router := http.NewServeMux()
+	router.HandleFunc("/ws", requireAuth(ws.HandleConnections))
+	router.HandleFunc("/sessions/revoke", requireAuth(h.RevokeSession))
+	router.HandleFunc("/sessions/revoke-others", requireAuth(h.RevokeOtherSessions))
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	cookies := map[string]*http.Cookie{}
+	conns := map[string]*websocket.Conn{}
+	var phone auth.Session
+	for _, device := range []string{"laptop", "phone", "tablet"} {
+		session, err := sessions.CreateSession(&model.User{ID: "alice"}, auth.SessionMetadata{}, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if device == "phone" {
+			phone = session
+		}
+		cookies[device] = &http.Cookie{Name: auth.CookieName, Value: session.ID}
+		header := http.Header{"Cookie": {cookies[device].String()}}
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		conns[device] = conn
+	}
+	waitForConnections := func(n int) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for hub.ConnectionCount("alice") != n {
+			if time.Now().After(deadline) {
+				t.Fatalf("alice has %d connections, want %d", hub.ConnectionCount("alice"), n)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	waitForConnections(3)
+
+	post := func(path, body, device string) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodPost, server.URL+path, bytes.NewBufferString(body))
+		req.AddCookie(cookies[device])
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s: status %d", path, resp.StatusCode)
+		}
+	}
+	expectClosed := func(device string) {
+		t.Helper()
+		conns[device].SetReadDeadline(time.Now().Add(2 * time.Second))
+		if _, _, err := conns[device].ReadMessage(); !websocket.IsCloseError(err, websocket.ClosePolicyViolation) {
+			t.Errorf("%s: read after revoking its session: %v, want a policy violation close", device, err)
+		}
+	}
+
+	body, _ := json.Marshal(map[string]string{"session_id": phone.PublicID()})
+	post("/sessions/revoke", string(body), "laptop")
+	expectClosed("phone")
+	waitForConnections(2)
+
+	post("/sessions/revoke-others", "", "laptop")
+	expectClosed("tablet")
+	waitForConnections(1)
+
+	if err := hub.Publish("alice", realtime.NewFrame(realtime.TypeNotification, "still here")); err != nil {
+		t.Fatal(err)
+	}
+	conns["laptop"].SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, _, err := conns["laptop"].ReadMessage(); err != nil {
+		t.Errorf("laptop: %v, want its connection kept open", err)
+	}
+}
+
+func TestWebSocketTrafficRefreshesTheSession(t *testing.T) {
+	db := newTestDB(t)
+	seedUsers(t, db, "alice")
+	hub := realtime.NewHub(realtime.DefaultConfig)
+	store := auth.NewSQLiteSessionStore(db)
+	sessions := auth.NewSessionManager(store, false, auth.DefaultSessionPolicy)
+	hub.OnSessionActivity(sessions.TouchSession)
+	ws := NewWebSocketHandler(hub, service.NewNotificationService(db, hub), nil, nil)
+	server := httptest.NewServer(middleware.NewAuthMiddleware(sessions).RequireAuth(ws.HandleConnections))
+	t.Cleanup(server.Close)
+
+	session, err := sessions.CreateSession(&model.User{ID: "alice"}, auth.SessionMetadata{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := http.Header{"Cookie": {(&http.Cookie{Name: auth.CookieName, Value: session.ID}).String()}}
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	// The session has been idle since well before the connection opened
+	idleSince := time.Now().Add(-time.Hour)
+	if err := store.Touch(session.ID, idleSince, time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	request(t, conn, `{"type":"get_notifications","request_id":"r1"}`, "r1")
+
+	stored, err := store.Get(session.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stored.LastActiveAt.After(idleSince) || time.Until(stored.ExpiresAt) < time.Hour {
+		t.Errorf("session last active %v, expires %v; want it refreshed by the frame", stored.LastActiveAt, stored.ExpiresAt)
+	}
+}
@@ -2,8 +2,30 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"mime"
 	"net/http"
+	"os"
+	"path/filepath"
 	"social-network/internal/service"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Private history formats. The split format returns separate "sent" and
+// "received" arrays and is kept for older clients.
+const (
+	historyFormatTimeline = "timeline"
+	historyFormatSplit    = "split"
 )
 
 type ChatHandler struct {
@@ -30,13 +52,32 @@ func (h *ChatHandler) GetPrivateMessageHistory(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	messages, err := h.ChatService.GetPrivateMessageHistory(userID, peerID)
+	page, err := pageRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var history interface{}
+	switch r.URL.Query().Get("format") {
+	case "", historyFormatTimeline:
+		history, err = h.ChatService.GetPrivateTimeline(userID, peerID, page)
+	case historyFormatSplit:
+		history, err = h.ChatService.GetPrivateMessageHistory(userID, peerID, page)
+	default:
+		http.Error(w, "format must be timeline or split", http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, service.ErrInvalidCursor) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	json.NewEncoder(w).Encode(messages)
+	json.NewEncoder(w).Encode(history)
 }
 
 // Get group message history
@@ -53,7 +94,21 @@ func (h *ChatHandler) GetGroupMessageHistory(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	messages, err := h.ChatService.GetGroupMessageHistory(groupID, userID)
+	page, err := pageRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	messages, err := h.ChatService.GetGroupMessageHistory(groupID, userID, page)
+	if errors.Is(err, service.ErrInvalidCursor) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, service.ErrNotGroupMember) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -73,18 +128,37 @@ func (h *ChatHandler) SendPrivateMessage(w http.ResponseWriter, r *http.Request)
 		RecipientID string `json:"recipient_id"`
 		Content     string `json:"content"`
 	}
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+	var attachment *service.Attachment
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		// Messages with an attachment are sent as a multipart form
+		var err error
+		attachment, err = h.saveAttachment(w, r)
+		if err != nil {
+			http.Error(w, err.Error(), messageErrorStatus(err))
+			return
+		}
+		input.RecipientID = r.FormValue("recipient_id")
+		input.Content = r.FormValue("content")
+	} else if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if input.RecipientID == "" || (input.Content == "" && attachment == nil) {
+		h.removeAttachment(attachment)
+		http.Error(w, "recipient_id and content or an attachment are required", http.StatusBadRequest)
+		return
+	}
 
 	userID := r.Context().Value("user_id").(string)
-	if err := h.ChatService.SendPrivateMessage(userID, input.RecipientID, input.Content); err != nil {
+	message, err := h.ChatService.SendPrivateMessage(userID, input.RecipientID, input.Content, attachment)
+	if err != nil {
+		h.removeAttachment(attachment)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(message)
 }
 
 // Send group message (alternative to WebSocket)
@@ -98,18 +172,41 @@ func (h *ChatHandler) SendGroupMessage(w http.ResponseWriter, r *http.Request) {
 		GroupID string `json:"group_id"`
 		Content string `json:"content"`
 	}
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+	var attachment *service.Attachment
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		// Messages with an attachment are sent as a multipart form
+		var err error
+		attachment, err = h.saveAttachment(w, r)
+		if err != nil {
+			http.Error(w, err.Error(), messageErrorStatus(err))
+			return
+		}
+		input.GroupID = r.FormValue("group_id")
+		input.Content = r.FormValue("content")
+	} else if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if input.GroupID == "" || (input.Content == "" && attachment == nil) {
+		h.removeAttachment(attachment)
+		http.Error(w, "group_id and content or an attachment are required", http.StatusBadRequest)
+		return
+	}
 
 	userID := r.Context().Value("user_id").(string)
-	if err := h.ChatService.SendGroupMessage(input.GroupID, userID, input.Content); err != nil {
+	message, err := h.ChatService.SendGroupMessage(input.GroupID, userID, input.Content, attachment)
+	if err != nil {
+		h.removeAttachment(attachment)
+		if errors.Is(err, service.ErrNotGroupMember) {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(message)
 }
 
 // Mark messages as read
@@ -121,14 +218,29 @@ func (h *ChatHandler) MarkMessagesRead(w http.ResponseWriter, r *http.Request) {
 
 	var input struct {
 		SenderID string `json:"sender_id"`
+		GroupID  string `json:"group_id"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if (input.SenderID == "") == (input.GroupID == "") {
+		http.Error(w, "exactly one of sender_id or group_id is required", http.StatusBadRequest)
+		return
+	}
 
 	userID := r.Context().Value("user_id").(string)
-	if err := h.ChatService.MarkMessagesAsRead(input.SenderID, userID); err != nil {
+	var err error
+	if input.GroupID != "" {
+		err = h.ChatService.MarkGroupRead(input.GroupID, userID)
+	} else {
+		err = h.ChatService.MarkMessagesAsRead(input.SenderID, userID)
+	}
+	if errors.Is(err, service.ErrNotGroupMember) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -155,13 +267,266 @@ func (h *ChatHandler) GetUnreadMessageSenders(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	hasUnread := len(senderIDs) > 0
+	groupCounts, err := h.ChatService.GetGroupUnreadCounts(userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	hasUnread := len(senderIDs) > 0 || len(groupCounts) > 0
 
 	response := map[string]interface{}{
 		"has_unread": hasUnread,
 		"senders":    senderIDs,
+		"groups":     groupCounts,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
+
+// Edit one of the user's own messages
+func (h *ChatHandler) EditMessage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var input struct {
+		MessageID string `json:"message_id"`
+		Content   string `json:"content"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if input.MessageID == "" || input.Content == "" {
+		http.Error(w, "message_id and content are required", http.StatusBadRequest)
+		return
+	}
+
+	userID := r.Context().Value("user_id").(string)
+	message, err := h.ChatService.EditMessage(input.MessageID, userID, input.Content)
+	if err != nil {
+		http.Error(w, err.Error(), messageErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(message)
+}
+
+// Delete a message for everyone
+func (h *ChatHandler) DeleteMessage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var input struct {
+		MessageID string `json:"message_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if input.MessageID == "" {
+		http.Error(w, "message_id is required", http.StatusBadRequest)
+		return
+	}
+
+	userID := r.Context().Value("user_id").(string)
+	message, err := h.ChatService.DeleteMessage(input.MessageID, userID)
+	if err != nil {
+		http.Error(w, err.Error(), messageErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(message)
+}
+
+// Get the edit history of a message
+func (h *ChatHandler) GetMessageEdits(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	messageID := r.URL.Query().Get("message_id")
+	if messageID == "" {
+		http.Error(w, "message_id is required", http.StatusBadRequest)
+		return
+	}
+
+	userID := r.Context().Value("user_id").(string)
+	edits, err := h.ChatService.GetMessageEdits(messageID, userID)
+	if err != nil {
+		http.Error(w, err.Error(), messageErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(edits)
+}
+
+// Get every private and group conversation of the user, most recent first
+func (h *ChatHandler) GetConversations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := r.Context().Value("user_id").(string)
+	conversations, err := h.ChatService.GetConversations(userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(conversations)
+}
+
+// Serve a chat attachment to participants of its conversation
+func (h *ChatHandler) GetAttachment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := r.Context().Value("user_id").(string)
+	attachment, err := h.ChatService.GetAttachmentByPath(r.URL.Path, userID)
+	if err != nil {
+		http.Error(w, err.Error(), messageErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", attachment.MimeType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if !service.IsImage(attachment.MimeType) {
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+			"filename": attachment.FileName,
+		}))
+	}
+	http.ServeFile(w, r, h.ChatService.AttachmentFile(attachment))
+}
+
+// saveAttachment stores the "attachment" file of a multipart request in the
+// configured attachment directory. It returns nil if the request has no
+// attachment.
+func (h *ChatHandler) saveAttachment(w http.ResponseWriter, r *http.Request) (*service.Attachment, error) {
+	maxSize := h.ChatService.Config().MaxAttachmentSize
+	r.Body = http.MaxBytesReader(w, r.Body, maxSize+1<<20)
+	if err := r.ParseMultipartForm(maxSize); err != nil {
+		return nil, service.ErrAttachmentTooLarge
+	}
+
+	file, header, err := r.FormFile("attachment")
+	if errors.Is(err, http.ErrMissingFile) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	// Trust the file contents rather than the client's Content-Type
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	mimeType, _, err := mime.ParseMediaType(http.DetectContentType(head[:n]))
+	if err != nil {
+		return nil, service.ErrAttachmentType
+	}
+	if err := h.ChatService.ValidateAttachment(mimeType, header.Size); err != nil {
+		return nil, err
+	}
+
+	attachment := &service.Attachment{
+		FileName: filepath.Base(header.Filename),
+		MimeType: mimeType,
+		Size:     header.Size,
+	}
+
+	if service.IsImage(mimeType) {
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		if config, _, err := image.DecodeConfig(file); err == nil {
+			attachment.Width = config.Width
+			attachment.Height = config.Height
+		}
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	dir := h.ChatService.Config().AttachmentDir
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.New("failed to create upload directory")
+	}
+
+	filename := fmt.Sprintf("%s-%s%s",
+		uuid.New().String(),
+		time.Now().Format("20060102150405"),
+		filepath.Ext(header.Filename),
+	)
+	fullPath := filepath.Join(dir, filename)
+	dst, err := os.Create(fullPath)
+	if err != nil {
+		return nil, errors.New("failed to create file")
+	}
+	defer dst.Close()
+
+	if _, err := io.Copy(dst, file); err != nil {
+		os.Remove(fullPath) // Clean up on error
+		return nil, errors.New("failed to save file")
+	}
+
+	attachment.Path = service.AttachmentPathPrefix + filename
+	return attachment, nil
+}
+
+// removeAttachment deletes the file of an attachment that was saved for a
+// message which could not be sent.
+func (h *ChatHandler) removeAttachment(attachment *service.Attachment) {
+	if attachment != nil {
+		os.Remove(h.ChatService.AttachmentFile(attachment))
+	}
+}
+
+func messageErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrAttachmentType), errors.Is(err, service.ErrAttachmentTooLarge):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrMessageNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrNotMessageSender), errors.Is(err, service.ErrNotGroupMember):
+		return http.StatusForbidden
+	case errors.Is(err, service.ErrEditWindowExpired), errors.Is(err, service.ErrMessageDeleted):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// pageRequest reads the before, after and limit query parameters.
+func pageRequest(r *http.Request) (service.PageRequest, error) {
+	query := r.URL.Query()
+	page := service.PageRequest{
+		Before: query.Get("before"),
+		After:  query.Get("after"),
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			return page, errors.New("limit must be a positive integer")
+		}
+		page.Limit = n
+	}
+	return page, nil
+}
@@ -0,0 +1,226 @@
+package handler
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"social-network/internal/auth"
+	"social-network/internal/middleware"
+	"social-network/internal/model"
+	"social-network/internal/realtime"
+	"social-network/internal/service"
+	"strings"
+	"testing"
+)
+
+// seedUsers inserts public users with the given IDs.
+func seedUsers(t *testing.T, db *sql.DB, ids ...string) {
+	t.Helper()
+	for _, id := range ids {
+		_, err := db.Exec(`
+            INSERT INTO users (id, email, password, first_name, last_name, date_of_birth, nickname, is_public)
+            VALUES (?, ?, 'x', ?, 'Test', '2000-01-01', ?, true)`,
+			id, id+"@example.com", id, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// attachmentServer serves message sending and attachments as the server
+// routes them, behind the auth middleware, and holds a session cookie for
+// each user.
+type attachmentServer struct {
+	*httptest.Server
+	dir     string
+	cookies map[string]*http.Cookie
+}
+
+func newAttachmentServer(t *testing.T, config service.ChatConfig) *attachmentServer {
+	t.Helper()
+	db := newTestDB(t)
+	seedUsers(t, db, "alice", "bob", "carol")
+
+	config.AttachmentDir = t.TempDir()
+	hub := realtime.NewHub(realtime.DefaultConfig)
+	h := NewChatHandler(service.NewChatService(db, hub, service.NewNotificationService(db, hub), config))
+	sessions := auth.NewSessionManager(auth.NewSQLiteSessionStore(db), false, auth.DefaultSessionPolicy)
+	requireAuth := middleware.NewAuthMiddleware(sessions).RequireAuth
+
+	router := http.NewServeMux()
+	router.HandleFunc("/messages/private", requireAuth(h.SendPrivateMessage))
+	router.HandleFunc(service.AttachmentPathPrefix, requireAuth(h.GetAttachment))
+	s := &attachmentServer{Server: httptest.NewServer(router), dir: config.AttachmentDir, cookies: map[string]*http.Cookie{}}
+	t.Cleanup(s.Close)
+
+	for _, id := range []string{"alice", "bob", "carol"} {
+		session, err := sessions.CreateSession(&model.User{ID: id}, auth.SessionMetadata{}, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.cookies[id] = &http.Cookie{Name: auth.CookieName, Value: session.ID}
+	}
+	return s
+}
+
+// send sends a private message with an attachment from alice to bob.
+func (s *attachmentServer) send(t *testing.T, fileName string, contents []byte) *http.Response {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	form.WriteField("recipient_id", "bob")
+	part, err := form.CreateFormFile("attachment", fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(contents)
+	form.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, s.URL+"/messages/private", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.AddCookie(s.cookies["alice"])
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+// get fetches path as userID, or anonymously when userID is empty.
+func (s *attachmentServer) get(t *testing.T, path, userID string) *http.Response {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, s.URL+path, nil)
+	if userID != "" {
+		req.AddCookie(s.cookies[userID])
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+// storedFiles returns the number of files in the attachment directory.
+func (s *attachmentServer) storedFiles(t *testing.T) int {
+	t.Helper()
+	entries, err := os.ReadDir(s.dir)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	return len(entries)
+}
+
+func pngImage(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 2, 3))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestAttachmentsAreServedToParticipantsOnly(t *testing.T) {
+	s := newAttachmentServer(t, service.DefaultChatConfig)
+	picture := pngImage(t)
+
+	resp := s.send(t, "picture.png", picture)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("send: status %d", resp.StatusCode)
+	}
+	var message service.Message
+	if err := json.NewDecoder(resp.Body).Decode(&message); err != nil {
+		t.Fatal(err)
+	}
+	attachment := message.Attachment
+	if attachment == nil || attachment.MimeType != "image/png" || attachment.Width != 2 || attachment.Height != 3 {
+		t.Fatalf("attachment = %+v, want a 2x3 image/png", attachment)
+	}
+	if !strings.HasPrefix(attachment.Path, service.AttachmentPathPrefix) {
+		t.Fatalf("attachment path %q is not under %s", attachment.Path, service.AttachmentPathPrefix)
+	}
+
+	for _, userID := range []string{"alice", "bob"} {
+		resp := s.get(t, attachment.Path, userID)
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != http.StatusOK || !bytes.Equal(body, picture) {
+			t.Errorf("%s: status %d with %d bytes, want the picture", userID, resp.StatusCode, len(body))
+		}
+		if got := resp.Header.Get("Content-Type"); got != "image/png" {
+			t.Errorf("%s: Content-Type %q, want image/png", userID, got)
+		}
+	}
+	if resp := s.get(t, attachment.Path, "carol"); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("non-participant: status %d, want 404", resp.StatusCode)
+	}
+	if resp := s.get(t, attachment.Path, ""); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("anonymous: status %d, want 401", resp.StatusCode)
+	}
+}
+
+func TestAttachmentsOtherThanImagesAreDownloads(t *testing.T) {
+	s := newAttachmentServer(t, service.DefaultChatConfig)
+
+	resp := s.send(t, "notes.txt", []byte("remember the tent"))
+	var message service.Message
+	if err := json.NewDecoder(resp.Body).Decode(&message); err != nil || message.Attachment == nil {
+		t.Fatalf("send: status %d, err %v", resp.StatusCode, err)
+	}
+
+	resp = s.get(t, message.Attachment.Path, "bob")
+	if got := resp.Header.Get("Content-Disposition"); got != `attachment; filename=notes.txt` {
+		t.Errorf("Content-Disposition = %q, want an attachment named notes.txt", got)
+	}
+	if got := resp.Header.Get("X-Content-Type-Options"); got != "nosniff" {
+		t.Errorf("X-Content-Type-Options = %q, want nosniff", got)
+	}
+}
+
+func TestRejectedAttachmentsAreNotStored(t *testing.T) {
+	config := service.DefaultChatConfig
+	config.MaxAttachmentSize = 100
+	s := newAttachmentServer(t, config)
+
+	tests := map[string]struct {
+		fileName string
+		contents []byte
+	}{
+		// The type is detected from the contents, not the name
+		"disallowed type": {"page.txt", []byte("<html><body><script>alert(1)</script></body></html>")},
+		"too large":       {"notes.txt", bytes.Repeat([]byte("a"), 101)},
+	}
+	for name, tt := range tests {
+		if resp := s.send(t, tt.fileName, tt.contents); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", name, resp.StatusCode)
+		}
+	}
+	if n := s.storedFiles(t); n != 0 {
+		t.Errorf("%d files stored for rejected attachments", n)
+	}
+}
+
+func TestMessageErrorStatusUnwraps(t *testing.T) {
+	tests := map[error]int{
+		service.ErrAttachmentType:                               http.StatusBadRequest,
+		fmt.Errorf("saving: %w", service.ErrAttachmentTooLarge): http.StatusBadRequest,
+		fmt.Errorf("loading: %w", service.ErrMessageNotFound):   http.StatusNotFound,
+		fmt.Errorf("sending: %w", service.ErrNotGroupMember):    http.StatusForbidden,
+		fmt.Errorf("editing: %w", service.ErrMessageDeleted):    http.StatusConflict,
+		errors.New("disk full"):                                 http.StatusInternalServerError,
+	}
+	for err, want := range tests {
+		if got := messageErrorStatus(err); got != want {
+			t.Errorf("messageErrorStatus(%v) = %d, want %d", err, got, want)
+		}
+	}
+}
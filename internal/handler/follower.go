@@ -74,10 +74,11 @@ func (h *FollowerHandler) GetFollowers(w http.ResponseWriter, r *http.Request) {
 		userID = ctxUserID
 	}
 
-	// Fetch followers
-	followers, err := h.FollowerService.GetFollowers(userID)
+	// Fetch followers, hidden for private profiles the requester doesn't follow
+	viewerID := r.Context().Value("user_id").(string)
+	followers, err := h.FollowerService.GetFollowers(userID, viewerID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), followerErrorStatus(err))
 		return
 	}
 
@@ -104,10 +105,11 @@ func (h *FollowerHandler) GetFollowing(w http.ResponseWriter, r *http.Request) {
 		userID = ctxUserID
 	}
 
-	// Fetch following
-	following, err := h.FollowerService.GetFollowing(userID)
+	// Fetch following, hidden for private profiles the requester doesn't follow
+	viewerID := r.Context().Value("user_id").(string)
+	following, err := h.FollowerService.GetFollowing(userID, viewerID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), followerErrorStatus(err))
 		return
 	}
 
@@ -178,3 +180,13 @@ func (h *FollowerHandler) Unfollow(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusOK)
 }
+
+func followerErrorStatus(err error) int {
+	switch err {
+	case service.ErrPrivateProfile:
+		return http.StatusForbidden
+	case service.ErrUserNotFound:
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
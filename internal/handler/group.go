@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"social-network/internal/model"
 	"social-network/internal/service"
+	"strings"
 )
 
 type GroupHandler struct {
@@ -109,7 +110,13 @@ func (h *GroupHandler) GetGroupPosts(w http.ResponseWriter, r *http.Request, use
 		return
 	}
 
-	posts, err := h.GroupService.GetGroupPosts(groupID, userID)
+	opts, err := commentThreadOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	posts, err := h.GroupService.GetGroupPosts(groupID, userID, opts)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -353,14 +360,19 @@ func (h *GroupHandler) GetUserGroups(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *GroupHandler) HandlePostComments(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPut {
+		h.editPostComment(w, r)
+		return
+	}
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	var input struct {
-		PostID  string `json:"post_id"`
-		Content string `json:"content"`
+		PostID          string `json:"post_id"`
+		Content         string `json:"content"`
+		ParentCommentID string `json:"parent_comment_id"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
@@ -368,11 +380,58 @@ func (h *GroupHandler) HandlePostComments(w http.ResponseWriter, r *http.Request
 	}
 
 	userID := r.Context().Value("user_id").(string)
-	comment, err := h.GroupService.CreatePostComment(input.PostID, userID, input.Content)
+	comment, err := h.GroupService.CreatePostComment(input.PostID, userID, input.Content, input.ParentCommentID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), commentErrorStatus(err))
+		return
+	}
+
+	json.NewEncoder(w).Encode(comment)
+}
+
+func (h *GroupHandler) editPostComment(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		CommentID string `json:"comment_id"`
+		Content   string `json:"content"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if input.CommentID == "" || strings.TrimSpace(input.Content) == "" {
+		http.Error(w, "comment_id and content are required", http.StatusBadRequest)
+		return
+	}
+
+	userID := r.Context().Value("user_id").(string)
+	comment, err := h.GroupService.EditPostComment(input.CommentID, userID, input.Content)
+	if err != nil {
+		http.Error(w, err.Error(), commentErrorStatus(err))
 		return
 	}
 
 	json.NewEncoder(w).Encode(comment)
 }
+
+// Get the previous versions of an edited group post comment
+func (h *GroupHandler) GetPostCommentEdits(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	commentID := r.URL.Query().Get("comment_id")
+	if commentID == "" {
+		http.Error(w, "comment_id is required", http.StatusBadRequest)
+		return
+	}
+
+	userID := r.Context().Value("user_id").(string)
+	edits, err := h.GroupService.GetPostCommentEdits(commentID, userID)
+	if err != nil {
+		http.Error(w, err.Error(), commentErrorStatus(err))
+		return
+	}
+
+	json.NewEncoder(w).Encode(edits)
+}
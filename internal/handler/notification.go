@@ -3,29 +3,142 @@ package handler
 import (
 	"encoding/json"
 	"net/http"
+	"social-network/internal/model"
 	"social-network/internal/service"
+	"strconv"
+	"strings"
 )
 
 type NotificationHandler struct {
 	NotificationService *service.NotificationService
 }
 
+// GetNotifications returns a page of notifications, newest first. It accepts
+// the before, after and limit pagination parameters, a comma-separated type
+// filter and read=true|false.
 func (h *NotificationHandler) GetNotifications(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
+	page, err := pageRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var filter service.NotificationFilter
+	if types := r.URL.Query().Get("type"); types != "" {
+		filter.Types = strings.Split(types, ",")
+	}
+	if read := r.URL.Query().Get("read"); read != "" {
+		isRead, err := strconv.ParseBool(read)
+		if err != nil {
+			http.Error(w, "read must be true or false", http.StatusBadRequest)
+			return
+		}
+		filter.IsRead = &isRead
+	}
+
 	userID := r.Context().Value("user_id").(string)
-	notifications, err := h.NotificationService.GetUserNotifications(userID)
+	notifications, err := h.NotificationService.GetUserNotifications(userID, filter, page)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), notificationErrorStatus(err))
 		return
 	}
 
+	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(notifications)
 }
 
+func (h *NotificationHandler) GetUnreadCount(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := r.Context().Value("user_id").(string)
+	count, err := h.NotificationService.GetUnreadCount(userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"unread_count": count})
+}
+
+// MarkAllAsRead marks every unread notification as read, or only those of
+// the type given in the optional body
+func (h *NotificationHandler) MarkAllAsRead(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var input struct {
+		Type string `json:"type"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	userID := r.Context().Value("user_id").(string)
+	updated, err := h.NotificationService.MarkAllAsRead(userID, input.Type)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int64{"updated": updated})
+}
+
+// DeleteNotifications deletes the notification given in the body, or every
+// notification of the user when the body has "all": true
+func (h *NotificationHandler) DeleteNotifications(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var input struct {
+		NotificationID string `json:"notification_id"`
+		All            bool   `json:"all"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	userID := r.Context().Value("user_id").(string)
+	if input.All {
+		deleted, err := h.NotificationService.DeleteAllNotifications(userID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]int64{"deleted": deleted})
+		return
+	}
+
+	if input.NotificationID == "" {
+		http.Error(w, "notification_id is required", http.StatusBadRequest)
+		return
+	}
+	if err := h.NotificationService.DeleteNotification(input.NotificationID, userID); err != nil {
+		http.Error(w, err.Error(), notificationErrorStatus(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
 func (h *NotificationHandler) MarkAsRead(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -48,3 +161,91 @@ func (h *NotificationHandler) MarkAsRead(w http.ResponseWriter, r *http.Request)
 
 	w.WriteHeader(http.StatusOK)
 }
+
+// HandlePreferences returns (GET) or updates (PUT) how each notification
+// type reaches the user
+func (h *NotificationHandler) HandlePreferences(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(string)
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var preferences []model.NotificationPreference
+		if err := json.NewDecoder(r.Body).Decode(&preferences); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if err := h.NotificationService.SetPreferences(userID, preferences); err != nil {
+			http.Error(w, err.Error(), notificationErrorStatus(err))
+			return
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	preferences, err := h.NotificationService.GetPreferences(userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(preferences)
+}
+
+// HandleMutes lists (GET), mutes (POST) or unmutes (DELETE) chats
+func (h *NotificationHandler) HandleMutes(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(string)
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost, http.MethodDelete:
+		var input struct {
+			Type string `json:"type"`
+			ID   string `json:"id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if input.ID == "" {
+			http.Error(w, "id is required", http.StatusBadRequest)
+			return
+		}
+
+		var err error
+		if r.Method == http.MethodPost {
+			err = h.NotificationService.MuteConversation(userID, input.Type, input.ID)
+		} else {
+			err = h.NotificationService.UnmuteConversation(userID, input.Type, input.ID)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), notificationErrorStatus(err))
+			return
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	muted, err := h.NotificationService.GetMutedConversations(userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(muted)
+}
+
+func notificationErrorStatus(err error) int {
+	switch err {
+	case service.ErrUnknownNotificationType, service.ErrInvalidDelivery, service.ErrInvalidConversationType,
+		service.ErrInvalidCursor:
+		return http.StatusBadRequest
+	case service.ErrNotificationNotFound:
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
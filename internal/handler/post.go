@@ -2,6 +2,7 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -9,6 +10,7 @@ import (
 	"path/filepath"
 	"social-network/internal/model"
 	"social-network/internal/service"
+	"strconv"
 	"strings"
 	"time"
 
@@ -296,12 +298,12 @@ func (h *PostHandler) CreateComment(w http.ResponseWriter, r *http.Request) {
 
 		// Get content from form field
 		content := r.FormValue("content")
-		comment, err := h.PostService.CreateComment(postID, userID, content, imagePath)
+		comment, err := h.PostService.CreateComment(postID, userID, content, imagePath, r.FormValue("parent_comment_id"))
 		if err != nil {
 			if imagePath != nil {
 				os.Remove(filepath.Join(".", *imagePath))
 			}
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, err.Error(), commentErrorStatus(err))
 			return
 		}
 
@@ -311,16 +313,17 @@ func (h *PostHandler) CreateComment(w http.ResponseWriter, r *http.Request) {
 
 	// Handle JSON request (backward compatibility)
 	var input struct {
-		Content string `json:"content"`
+		Content         string `json:"content"`
+		ParentCommentID string `json:"parent_comment_id"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	comment, err := h.PostService.CreateComment(postID, userID, input.Content, nil)
+	comment, err := h.PostService.CreateComment(postID, userID, input.Content, nil, input.ParentCommentID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), commentErrorStatus(err))
 		return
 	}
 
@@ -336,16 +339,113 @@ func (h *PostHandler) GetComments(w http.ResponseWriter, r *http.Request) {
 	postID := strings.TrimPrefix(r.URL.Path, "/posts/")
 	postID = strings.TrimSuffix(postID, "/comments")
 
+	// parent_id continues a thread below a given comment
+	opts, err := commentThreadOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	opts.ParentID = r.URL.Query().Get("parent_id")
+
 	userID := r.Context().Value("user_id").(string)
-	comments, err := h.PostService.GetPostComments(postID, userID)
+	comments, err := h.PostService.GetPostComments(postID, userID, opts)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), commentErrorStatus(err))
 		return
 	}
 
 	json.NewEncoder(w).Encode(comments)
 }
 
+// commentThreadOptions reads the depth query parameter, which limits how many
+// levels of replies are returned.
+func commentThreadOptions(r *http.Request) (service.CommentThreadOptions, error) {
+	var opts service.CommentThreadOptions
+	if depth := r.URL.Query().Get("depth"); depth != "" {
+		n, err := strconv.Atoi(depth)
+		if err != nil || n <= 0 {
+			return opts, errors.New("depth must be a positive integer")
+		}
+		opts.Depth = n
+	}
+	return opts, nil
+}
+
+func (h *PostHandler) EditComment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	pathParts := strings.Split(r.URL.Path, "/")
+	if len(pathParts) < 5 {
+		http.Error(w, "Invalid URL", http.StatusBadRequest)
+		return
+	}
+	commentID := pathParts[len(pathParts)-1]
+
+	var input struct {
+		Content string `json:"content"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(input.Content) == "" {
+		http.Error(w, "Content is required", http.StatusBadRequest)
+		return
+	}
+
+	userID := r.Context().Value("user_id").(string)
+	comment, err := h.PostService.EditComment(commentID, userID, input.Content)
+	if err != nil {
+		http.Error(w, err.Error(), commentErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(comment)
+}
+
+// Get the previous versions of an edited comment
+func (h *PostHandler) GetCommentEdits(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	pathParts := strings.Split(strings.TrimSuffix(r.URL.Path, "/edits"), "/")
+	if len(pathParts) < 5 {
+		http.Error(w, "Invalid URL", http.StatusBadRequest)
+		return
+	}
+	commentID := pathParts[len(pathParts)-1]
+
+	userID := r.Context().Value("user_id").(string)
+	edits, err := h.PostService.GetCommentEdits(commentID, userID)
+	if err != nil {
+		http.Error(w, err.Error(), commentErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(edits)
+}
+
+func commentErrorStatus(err error) int {
+	switch err {
+	case service.ErrParentCommentNotFound:
+		return http.StatusBadRequest
+	case service.ErrNotCommentAuthor, service.ErrCommentEditsDenied:
+		return http.StatusForbidden
+	case service.ErrPostAccessDenied:
+		return http.StatusForbidden
+	case service.ErrCommentNotFound, service.ErrPostNotFound:
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
 func (h *PostHandler) DeleteComment(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodDelete {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -398,10 +498,21 @@ func (h *PostHandler) GetUserPosts(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Use the PostService to fetch the posts
-	posts, err := h.PostService.GetUserPosts(userID)
+	page, err := pageRequest(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Use the PostService to fetch the posts the requester may see
+	viewerID := r.Context().Value("user_id").(string)
+	posts, err := h.PostService.GetUserPosts(userID, viewerID, page)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if err == service.ErrInvalidCursor {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), status)
 		return
 	}
 
@@ -411,3 +522,42 @@ func (h *PostHandler) GetUserPosts(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 }
+
+// GetFeed returns a page of the requester's home feed. It accepts the
+// before, after and limit pagination parameters and following=true to only
+// show posts by people they follow.
+func (h *PostHandler) GetFeed(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	page, err := pageRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var opts service.FeedOptions
+	if following := r.URL.Query().Get("following"); following != "" {
+		opts.FollowingOnly, err = strconv.ParseBool(following)
+		if err != nil {
+			http.Error(w, "following must be true or false", http.StatusBadRequest)
+			return
+		}
+	}
+
+	userID := r.Context().Value("user_id").(string)
+	feed, err := h.PostService.GetFeed(userID, opts, page)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if err == service.ErrInvalidCursor {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(feed)
+}
@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"social-network/internal/service"
+)
+
+type ProfileHandler struct {
+	ProfileService *service.ProfileService
+}
+
+// GetProfile returns the profile of ?user_id= (the requester by default)
+// with the first page of posts. The before, after and limit parameters page
+// the posts like /users/posts.
+func (h *ProfileHandler) GetProfile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	viewerID := r.Context().Value("user_id").(string)
+	userID := r.URL.Query().Get("user_id")
+	if userID == "" {
+		userID = viewerID
+	}
+
+	page, err := pageRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	profile, err := h.ProfileService.GetProfile(userID, viewerID, page)
+	if err != nil {
+		http.Error(w, err.Error(), profileErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(profile)
+}
+
+func profileErrorStatus(err error) int {
+	switch err {
+	case service.ErrUserNotFound:
+		return http.StatusNotFound
+	case service.ErrInvalidCursor:
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"social-network/internal/model"
+	"social-network/internal/service"
+)
+
+type ReactionHandler struct {
+	ReactionService *service.ReactionService
+}
+
+// HandleReactions lists (GET), adds (POST) or removes (DELETE) reactions on a
+// post, comment or chat message
+func (h *ReactionHandler) HandleReactions(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(string)
+
+	var reactions []model.ReactionSummary
+	var err error
+
+	switch r.Method {
+	case http.MethodGet:
+		query := r.URL.Query()
+		if query.Get("target_type") == "" || query.Get("target_id") == "" {
+			http.Error(w, "target_type and target_id are required", http.StatusBadRequest)
+			return
+		}
+		reactions, err = h.ReactionService.GetReactions(userID, query.Get("target_type"), query.Get("target_id"))
+
+	case http.MethodPost, http.MethodDelete:
+		var input model.ReactionInput
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if input.TargetType == "" || input.TargetID == "" || input.Emoji == "" {
+			http.Error(w, "target_type, target_id and emoji are required", http.StatusBadRequest)
+			return
+		}
+
+		if r.Method == http.MethodPost {
+			reactions, err = h.ReactionService.AddReaction(userID, input)
+		} else {
+			reactions, err = h.ReactionService.RemoveReaction(userID, input)
+		}
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), reactionErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reactions)
+}
+
+func reactionErrorStatus(err error) int {
+	switch err {
+	case service.ErrInvalidReactionTarget, service.ErrInvalidEmoji:
+		return http.StatusBadRequest
+	case service.ErrReactionTargetNotFound:
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
@@ -37,9 +37,20 @@ func (h *UserHandler) GetUserByUUID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := h.UserService.GetUserByUUID(uuid)
+	// Private profiles only show their card to non-followers
+	viewerID := r.Context().Value("user_id").(string)
+	user, err := h.UserService.GetUserForViewer(uuid, viewerID)
+	if err == service.ErrPrivateProfile {
+		card, err := h.UserService.GetUserCard(uuid)
+		if err != nil {
+			http.Error(w, err.Error(), userErrorStatus(err))
+			return
+		}
+		json.NewEncoder(w).Encode(card)
+		return
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		http.Error(w, err.Error(), userErrorStatus(err))
 		return
 	}
 
@@ -84,3 +95,10 @@ func (h *UserHandler) GetProfileVisibility(w http.ResponseWriter, r *http.Reques
 
 	json.NewEncoder(w).Encode(map[string]bool{"is_public": isPublic})
 }
+
+func userErrorStatus(err error) int {
+	if err == service.ErrUserNotFound {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
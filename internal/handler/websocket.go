@@ -1,8 +1,10 @@
 package handler
 
 import (
+	"errors"
 	"log"
 	"net/http"
+	"social-network/internal/realtime"
 	"social-network/internal/service"
 
 	"github.com/gorilla/websocket"
@@ -15,14 +17,18 @@ var upgrader = websocket.Upgrader{
 }
 
 type WebSocketHandler struct {
+	hub                 *realtime.Hub
 	notificationService *service.NotificationService
 	chatService         *service.ChatService
+	presenceService     *service.PresenceService
 }
 
-func NewWebSocketHandler(notificationService *service.NotificationService, chatService *service.ChatService) *WebSocketHandler {
+func NewWebSocketHandler(hub *realtime.Hub, notificationService *service.NotificationService, chatService *service.ChatService, presenceService *service.PresenceService) *WebSocketHandler {
 	return &WebSocketHandler{
+		hub:                 hub,
 		notificationService: notificationService,
 		chatService:         chatService,
+		presenceService:     presenceService,
 	}
 }
 
@@ -32,190 +38,347 @@ func (h *WebSocketHandler) HandleConnections(w http.ResponseWriter, r *http.Requ
 		log.Println("WebSocket upgrade error:", err)
 		return
 	}
-	defer conn.Close()
 
-	// Get userID from context (set by auth middleware)
+	// Get userID and the session from context (set by auth middleware)
 	userID := r.Context().Value("user_id").(string)
+	sessionID, _ := r.Context().Value("session_id").(string)
 
 	// Register connection for notifications and messages
-	h.notificationService.RegisterConnection(userID, conn)
-	h.chatService.RegisterConnection(userID, conn)
-	defer h.notificationService.RemoveConnection(userID)
-	defer h.chatService.RemoveConnection(userID)
+	client := h.hub.Register(userID, sessionID, conn)
+	defer h.hub.Unregister(client)
 
 	log.Printf("Client connected: %s", userID)
 
 	for {
-		var msg map[string]interface{}
-		err := conn.ReadJSON(&msg)
+		data, err := client.ReadMessage()
 		if err != nil {
 			log.Println("WebSocket read error:", err)
 			break
 		}
 
+		env, err := realtime.DecodeEnvelope(data)
+		if errors.Is(err, realtime.ErrUnsupportedVersion) {
+			client.Send(realtime.ErrorFrame(env.RequestID, realtime.ErrCodeUnsupportedVersion, err.Error()))
+			continue
+		}
+		if err != nil {
+			client.Send(realtime.ErrorFrame("", realtime.ErrCodeInvalidFrame, "Frame is not valid JSON"))
+			continue
+		}
+
 		// Handle different message types
-		switch msg["type"] {
-		case "get_notifications":
-			h.handleNotifications(conn, userID)
-		case "mark_read":
-			if notifID, ok := msg["notification_id"].(string); ok {
-				h.notificationService.MarkAsRead(notifID, userID)
-			}
-		case "send_private_message":
-			h.handlePrivateMessage(conn, userID, msg)
-		case "send_group_message":
-			h.handleGroupMessage(conn, userID, msg)
-		case "get_private_history":
-			h.handleGetPrivateHistory(conn, userID, msg)
-		case "get_group_history":
-			h.handleGetGroupHistory(conn, userID, msg)
-		case "mark_messages_read":
-			h.handleMarkMessagesRead(conn, userID, msg)
-		case "get_unread_messages":
-			h.handleGetUnreadMessages(conn, userID)
+		switch env.Type {
+		case realtime.TypeGetNotifications:
+			h.handleNotifications(client, userID, env)
+		case realtime.TypeMarkRead:
+			h.handleMarkRead(client, userID, env)
+		case realtime.TypeMarkAllRead:
+			h.handleMarkAllRead(client, userID, env)
+		case realtime.TypeSendPrivateMessage:
+			h.handlePrivateMessage(client, userID, env)
+		case realtime.TypeSendGroupMessage:
+			h.handleGroupMessage(client, userID, env)
+		case realtime.TypeGetPrivateHistory:
+			h.handleGetPrivateHistory(client, userID, env)
+		case realtime.TypeGetGroupHistory:
+			h.handleGetGroupHistory(client, userID, env)
+		case realtime.TypeMarkMessagesRead:
+			h.handleMarkMessagesRead(client, userID, env)
+		case realtime.TypeGetUnreadMessages:
+			h.handleGetUnreadMessages(client, userID, env)
+		case realtime.TypeGetConversations:
+			h.handleGetConversations(client, userID, env)
+		case realtime.TypeEditMessage:
+			h.handleEditMessage(client, userID, env)
+		case realtime.TypeDeleteMessage:
+			h.handleDeleteMessage(client, userID, env)
+		case realtime.TypeTyping:
+			h.handleTyping(client, userID, env)
 		default:
-			log.Printf("Unknown message type: %v", msg["type"])
+			client.Send(realtime.ErrorFrame(env.RequestID, realtime.ErrCodeUnknownType, "Unknown message type: "+env.Type))
 		}
 	}
 
 	log.Printf("Client disconnected: %s", userID)
 }
 
-func (h *WebSocketHandler) handleNotifications(conn *websocket.Conn, userID string) {
-	notifications, err := h.notificationService.GetUserNotifications(userID)
-	if err != nil {
-		log.Printf("Error getting notifications: %v", err)
+// decodePayload decodes the request payload into v, answering with an error
+// frame if it is malformed.
+func decodePayload(client *realtime.Client, env realtime.Envelope, v interface{}) bool {
+	if err := env.DecodePayload(v); err != nil {
+		client.Send(realtime.ErrorFrame(env.RequestID, realtime.ErrCodeInvalidPayload, "Invalid payload: "+err.Error()))
+		return false
+	}
+	return true
+}
+
+func invalidPayload(client *realtime.Client, env realtime.Envelope, message string) {
+	client.Send(realtime.ErrorFrame(env.RequestID, realtime.ErrCodeInvalidPayload, message))
+}
+
+// sendServiceError reports a failed request to the client. Internal errors are
+// logged and replaced by a generic message.
+func sendServiceError(client *realtime.Client, env realtime.Envelope, err error, message string) {
+	switch {
+	case errors.Is(err, service.ErrNotGroupMember), errors.Is(err, service.ErrNotMessageSender),
+		errors.Is(err, service.ErrNotChatPartner):
+		client.Send(realtime.ErrorFrame(env.RequestID, realtime.ErrCodeForbidden, err.Error()))
+		return
+	case errors.Is(err, service.ErrInvalidCursor):
+		client.Send(realtime.ErrorFrame(env.RequestID, realtime.ErrCodeInvalidPayload, err.Error()))
+		return
+	case errors.Is(err, service.ErrMessageNotFound):
+		client.Send(realtime.ErrorFrame(env.RequestID, realtime.ErrCodeNotFound, err.Error()))
+		return
+	case errors.Is(err, service.ErrEditWindowExpired), errors.Is(err, service.ErrMessageDeleted):
+		client.Send(realtime.ErrorFrame(env.RequestID, realtime.ErrCodeConflict, err.Error()))
 		return
 	}
-	response := map[string]interface{}{
-		"type":    "notifications",
-		"content": notifications,
+	log.Printf("%s: %v", message, err)
+	client.Send(realtime.ErrorFrame(env.RequestID, realtime.ErrCodeInternal, message))
+}
+
+func historyPage(payload realtime.PagePayload) service.PageRequest {
+	return service.PageRequest{
+		Before: payload.Before,
+		After:  payload.After,
+		Limit:  payload.Limit,
 	}
-	conn.WriteJSON(response)
 }
 
-func (h *WebSocketHandler) handlePrivateMessage(conn *websocket.Conn, userID string, msg map[string]interface{}) {
-	recipientID, ok := msg["recipient_id"].(string)
-	content, okContent := msg["content"].(string)
-	if !ok || !okContent {
-		log.Println("Invalid private message payload")
+func (h *WebSocketHandler) handleNotifications(client *realtime.Client, userID string, env realtime.Envelope) {
+	var payload realtime.GetNotificationsPayload
+	if len(env.Payload) > 0 && !decodePayload(client, env, &payload) {
 		return
 	}
 
-	err := h.chatService.SendPrivateMessage(userID, recipientID, content)
+	filter := service.NotificationFilter{Types: payload.Types, IsRead: payload.IsRead}
+	notifications, err := h.notificationService.GetUserNotifications(userID, filter, historyPage(payload.PagePayload))
 	if err != nil {
-		log.Printf("Error sending private message: %v", err)
+		sendServiceError(client, env, err, "Error getting notifications")
 		return
 	}
+	client.Send(realtime.Reply(env.RequestID, realtime.TypeNotifications, notifications))
+}
 
-	response := map[string]interface{}{
-		"type":    "message_sent",
-		"success": true,
+func (h *WebSocketHandler) handleMarkRead(client *realtime.Client, userID string, env realtime.Envelope) {
+	var payload realtime.MarkReadPayload
+	if !decodePayload(client, env, &payload) {
+		return
+	}
+	if payload.NotificationID == "" {
+		invalidPayload(client, env, "notification_id is required")
+		return
 	}
-	conn.WriteJSON(response)
+
+	if err := h.notificationService.MarkAsRead(payload.NotificationID, userID); err != nil {
+		sendServiceError(client, env, err, "Error marking notification as read")
+		return
+	}
+	client.Send(realtime.Ack(env.RequestID, env.Type, ""))
 }
 
-func (h *WebSocketHandler) handleGroupMessage(conn *websocket.Conn, userID string, msg map[string]interface{}) {
-	groupID, ok := msg["group_id"].(string)
-	content, okContent := msg["content"].(string)
-	if !ok || !okContent {
-		log.Println("Invalid group message payload")
+func (h *WebSocketHandler) handleMarkAllRead(client *realtime.Client, userID string, env realtime.Envelope) {
+	var payload realtime.MarkAllReadPayload
+	if len(env.Payload) > 0 && !decodePayload(client, env, &payload) {
 		return
 	}
 
-	err := h.chatService.SendGroupMessage(groupID, userID, content)
-	if err != nil {
-		log.Printf("Error sending group message: %v", err)
+	if _, err := h.notificationService.MarkAllAsRead(userID, payload.NotificationType); err != nil {
+		sendServiceError(client, env, err, "Error marking notifications as read")
 		return
 	}
+	client.Send(realtime.Ack(env.RequestID, env.Type, ""))
+}
 
-	response := map[string]interface{}{
-		"type":    "message_sent",
-		"success": true,
+func (h *WebSocketHandler) handlePrivateMessage(client *realtime.Client, userID string, env realtime.Envelope) {
+	var payload realtime.SendPrivateMessagePayload
+	if !decodePayload(client, env, &payload) {
+		return
+	}
+	if payload.RecipientID == "" || payload.Content == "" {
+		invalidPayload(client, env, "recipient_id and content are required")
+		return
+	}
+
+	message, err := h.chatService.SendPrivateMessage(userID, payload.RecipientID, payload.Content, nil)
+	if err != nil {
+		sendServiceError(client, env, err, "Error sending private message")
+		return
 	}
-	conn.WriteJSON(response)
+	client.Send(realtime.Ack(env.RequestID, env.Type, message.ID))
 }
 
-func (h *WebSocketHandler) handleGetPrivateHistory(conn *websocket.Conn, userID string, msg map[string]interface{}) {
-	otherUserID, ok := msg["other_user_id"].(string)
-	if !ok {
-		log.Println("Invalid user ID for message history")
+func (h *WebSocketHandler) handleGroupMessage(client *realtime.Client, userID string, env realtime.Envelope) {
+	var payload realtime.SendGroupMessagePayload
+	if !decodePayload(client, env, &payload) {
+		return
+	}
+	if payload.GroupID == "" || payload.Content == "" {
+		invalidPayload(client, env, "group_id and content are required")
 		return
 	}
 
-	messages, err := h.chatService.GetPrivateMessageHistory(userID, otherUserID)
+	message, err := h.chatService.SendGroupMessage(payload.GroupID, userID, payload.Content, nil)
 	if err != nil {
-		log.Printf("Error fetching private message history: %v", err)
+		sendServiceError(client, env, err, "Error sending group message")
 		return
 	}
+	client.Send(realtime.Ack(env.RequestID, env.Type, message.ID))
+}
 
-	response := map[string]interface{}{
-		"type":    "private_message_history",
-		"content": messages,
+func (h *WebSocketHandler) handleGetPrivateHistory(client *realtime.Client, userID string, env realtime.Envelope) {
+	var payload realtime.PrivateHistoryPayload
+	if !decodePayload(client, env, &payload) {
+		return
+	}
+	if payload.OtherUserID == "" {
+		invalidPayload(client, env, "other_user_id is required")
+		return
+	}
+
+	page := historyPage(payload.PagePayload)
+
+	var history interface{}
+	var err error
+	switch payload.Format {
+	case "", historyFormatTimeline:
+		history, err = h.chatService.GetPrivateTimeline(userID, payload.OtherUserID, page)
+	case historyFormatSplit:
+		history, err = h.chatService.GetPrivateMessageHistory(userID, payload.OtherUserID, page)
+	default:
+		invalidPayload(client, env, "format must be timeline or split")
+		return
+	}
+	if err != nil {
+		sendServiceError(client, env, err, "Error fetching private message history")
+		return
 	}
-	conn.WriteJSON(response)
+	client.Send(realtime.Reply(env.RequestID, realtime.TypePrivateMessageHistory, history))
 }
 
-func (h *WebSocketHandler) handleGetGroupHistory(conn *websocket.Conn, userID string, msg map[string]interface{}) {
-	groupID, ok := msg["group_id"].(string)
-	if !ok {
-		log.Println("Invalid group ID for message history")
+func (h *WebSocketHandler) handleGetGroupHistory(client *realtime.Client, userID string, env realtime.Envelope) {
+	var payload realtime.GroupHistoryPayload
+	if !decodePayload(client, env, &payload) {
+		return
+	}
+	if payload.GroupID == "" {
+		invalidPayload(client, env, "group_id is required")
 		return
 	}
 
-	messages, err := h.chatService.GetGroupMessageHistory(groupID, userID)
+	messages, err := h.chatService.GetGroupMessageHistory(payload.GroupID, userID, historyPage(payload.PagePayload))
 	if err != nil {
-		log.Printf("Error fetching group message history: %v", err)
+		sendServiceError(client, env, err, "Error fetching group message history")
 		return
 	}
+	client.Send(realtime.Reply(env.RequestID, realtime.TypeGroupMessageHistory, messages))
+}
 
-	response := map[string]interface{}{
-		"type":    "group_message_history",
-		"content": messages,
+func (h *WebSocketHandler) handleMarkMessagesRead(client *realtime.Client, userID string, env realtime.Envelope) {
+	var payload realtime.MarkMessagesReadPayload
+	if !decodePayload(client, env, &payload) {
+		return
+	}
+	if (payload.SenderID == "") == (payload.GroupID == "") {
+		invalidPayload(client, env, "exactly one of sender_id or group_id is required")
+		return
 	}
-	conn.WriteJSON(response)
+
+	var err error
+	if payload.GroupID != "" {
+		err = h.chatService.MarkGroupRead(payload.GroupID, userID)
+	} else {
+		err = h.chatService.MarkMessagesAsRead(payload.SenderID, userID)
+	}
+	if err != nil {
+		sendServiceError(client, env, err, "Error marking messages as read")
+		return
+	}
+	client.Send(realtime.Ack(env.RequestID, env.Type, ""))
 }
 
-func (h *WebSocketHandler) handleMarkMessagesRead(conn *websocket.Conn, userID string, msg map[string]interface{}) {
-	senderID, ok := msg["sender_id"].(string)
-	if !ok {
+func (h *WebSocketHandler) handleGetUnreadMessages(client *realtime.Client, userID string, env realtime.Envelope) {
+	senderIDs, err := h.chatService.GetUnreadMessageSenders(userID)
+	if err != nil {
+		sendServiceError(client, env, err, "Failed to get unread messages")
 		return
 	}
 
-	err := h.chatService.MarkMessagesAsRead(senderID, userID)
+	groupCounts, err := h.chatService.GetGroupUnreadCounts(userID)
 	if err != nil {
-		log.Printf("Error marking messages as read: %v", err)
+		sendServiceError(client, env, err, "Failed to get unread messages")
 		return
 	}
 
+	hasUnread := len(senderIDs) > 0 || len(groupCounts) > 0
+
 	response := map[string]interface{}{
-		"type":    "messages_marked_read",
-		"success": true,
+		"has_unread": hasUnread,
+		"senders":    senderIDs,
+		"groups":     groupCounts,
 	}
-	conn.WriteJSON(response)
+	client.Send(realtime.Reply(env.RequestID, realtime.TypeUnreadMessages, response))
 }
 
-func (h *WebSocketHandler) handleGetUnreadMessages(conn *websocket.Conn, userID string) {
-	senderIDs, err := h.chatService.GetUnreadMessageSenders(userID)
+func (h *WebSocketHandler) handleGetConversations(client *realtime.Client, userID string, env realtime.Envelope) {
+	conversations, err := h.chatService.GetConversations(userID)
 	if err != nil {
-		log.Printf("Error getting unread message senders: %v", err)
-		response := map[string]interface{}{
-			"type":    "error",
-			"message": "Failed to get unread messages",
-		}
-		conn.WriteJSON(response)
+		sendServiceError(client, env, err, "Error fetching conversations")
 		return
 	}
+	client.Send(realtime.Reply(env.RequestID, realtime.TypeConversations, conversations))
+}
 
-	hasUnread := len(senderIDs) > 0
+func (h *WebSocketHandler) handleEditMessage(client *realtime.Client, userID string, env realtime.Envelope) {
+	var payload realtime.EditMessagePayload
+	if !decodePayload(client, env, &payload) {
+		return
+	}
+	if payload.MessageID == "" || payload.Content == "" {
+		invalidPayload(client, env, "message_id and content are required")
+		return
+	}
 
-	response := map[string]interface{}{
-		"type": "unread_messages",
-		"content": map[string]interface{}{
-			"has_unread": hasUnread,
-			"senders":    senderIDs,
-		},
+	message, err := h.chatService.EditMessage(payload.MessageID, userID, payload.Content)
+	if err != nil {
+		sendServiceError(client, env, err, "Error editing message")
+		return
+	}
+	client.Send(realtime.Ack(env.RequestID, env.Type, message.ID))
+}
+
+func (h *WebSocketHandler) handleDeleteMessage(client *realtime.Client, userID string, env realtime.Envelope) {
+	var payload realtime.DeleteMessagePayload
+	if !decodePayload(client, env, &payload) {
+		return
+	}
+	if payload.MessageID == "" {
+		invalidPayload(client, env, "message_id is required")
+		return
+	}
+
+	message, err := h.chatService.DeleteMessage(payload.MessageID, userID)
+	if err != nil {
+		sendServiceError(client, env, err, "Error deleting message")
+		return
 	}
+	client.Send(realtime.Ack(env.RequestID, env.Type, message.ID))
+}
 
-	conn.WriteJSON(response)
+func (h *WebSocketHandler) handleTyping(client *realtime.Client, userID string, env realtime.Envelope) {
+	var payload realtime.TypingPayload
+	if !decodePayload(client, env, &payload) {
+		return
+	}
+	if (payload.RecipientID == "") == (payload.GroupID == "") {
+		invalidPayload(client, env, "exactly one of recipient_id or group_id is required")
+		return
+	}
+
+	if err := h.presenceService.SendTyping(userID, payload.RecipientID, payload.GroupID, payload.IsTyping); err != nil {
+		sendServiceError(client, env, err, "Error sending typing indicator")
+		return
+	}
+	client.Send(realtime.Ack(env.RequestID, env.Type, ""))
 }
@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"social-network/internal/realtime"
+	"social-network/internal/service"
+	"social-network/pkg/db/sqlite"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// newTestDB returns an in-memory database with every migration applied.
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	name := strings.ReplaceAll(t.Name(), "/", "_")
+	db, err := sqlite.New("file:" + name + "?mode=memory&cache=shared&_busy_timeout=5000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.RunMigrations("../../pkg/db/migrations/sqlite"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db.DB
+}
+
+// dialAs serves h.HandleConnections for userID and connects to it.
+func dialAs(t *testing.T, h *WebSocketHandler, userID string) *websocket.Conn {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.HandleConnections(w, r.WithContext(context.WithValue(r.Context(), "user_id", userID)))
+	}))
+	t.Cleanup(server.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// request sends a raw frame and returns the reply to requestID.
+func request(t *testing.T, conn *websocket.Conn, frame, requestID string) realtime.Envelope {
+	t.Helper()
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(frame)); err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		env, err := realtime.DecodeEnvelope(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if env.RequestID == requestID {
+			return env
+		}
+	}
+}
+
+func TestMarkAllReadFrames(t *testing.T) {
+	db := newTestDB(t)
+	_, err := db.Exec(`
+        INSERT INTO users (id, email, password, first_name, last_name, date_of_birth, nickname, is_public)
+        VALUES ('alice', 'alice@example.com', 'x', 'Alice', 'Test', '2000-01-01', 'alice', true)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`
+        INSERT INTO notifications (id, user_id, type, content, reference_id, is_read)
+        VALUES ('n1', 'alice', 'new_follower', 'x', 'r', false),
+               ('n2', 'alice', 'mention', 'x', 'r', false),
+               ('n3', 'alice', 'mention', 'x', 'r', false)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hub := realtime.NewHub(realtime.DefaultConfig)
+	notifications := service.NewNotificationService(db, hub)
+	h := NewWebSocketHandler(hub, notifications, nil, nil)
+	conn := dialAs(t, h, "alice")
+
+	unread := func() int {
+		t.Helper()
+		count, err := notifications.GetUnreadCount("alice")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return count
+	}
+
+	reply := request(t, conn,
+		`{"type":"mark_all_read","request_id":"v1","version":1,"payload":{"notification_type":"mention"}}`, "v1")
+	if reply.Type != realtime.TypeAck || unread() != 1 {
+		t.Fatalf("filtered request: got %s with %d unread, want an ack and 1 unread", reply.Type, unread())
+	}
+
+	// Legacy frames carry their fields next to "type", which must not be
+	// mistaken for a notification type filter
+	reply = request(t, conn, `{"type":"mark_all_read","request_id":"legacy"}`, "legacy")
+	if reply.Type != realtime.TypeAck || unread() != 0 {
+		t.Fatalf("legacy request: got %s with %d unread, want an ack and none unread", reply.Type, unread())
+	}
+}
@@ -16,7 +16,7 @@ func NewAuthMiddleware(sm *auth.SessionManager) *AuthMiddleware {
 
 func (m *AuthMiddleware) RequireAuth(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		cookie, err := r.Cookie("session_id")
+		cookie, err := r.Cookie(auth.CookieName)
 		if err != nil {
 			http.Error(w, "No session cookie found", http.StatusUnauthorized)
 			return
@@ -28,7 +28,13 @@ func (m *AuthMiddleware) RequireAuth(next http.HandlerFunc) http.HandlerFunc {
 			return
 		}
 
+		if refreshed, ok := m.sessionManager.RefreshSession(session); ok {
+			session = refreshed
+			auth.SetCookie(w, session)
+		}
+
 		ctx := context.WithValue(r.Context(), "user_id", session.UserID)
+		ctx = context.WithValue(ctx, "session_id", session.ID)
 		next(w, r.WithContext(ctx))
 	}
 }
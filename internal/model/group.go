@@ -33,15 +33,23 @@ type GroupPost struct {
 	CreatedAt time.Time          `json:"created_at"`
 	UpdatedAt time.Time          `json:"updated_at"`
 	Comments  []GroupPostComment `json:"comments,omitempty"`
+	Reactions []ReactionSummary  `json:"reactions,omitempty"`
 }
 
 type GroupPostComment struct {
-	ID        string    `json:"id"`
-	PostID    string    `json:"post_id"`
-	UserID    string    `json:"user_id"`
-	Content   string    `json:"content"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID              string             `json:"id"`
+	PostID          string             `json:"post_id"`
+	UserID          string             `json:"user_id"`
+	ParentCommentID *string            `json:"parent_comment_id,omitempty"`
+	Content         string             `json:"content"`
+	CreatedAt       time.Time          `json:"created_at"`
+	UpdatedAt       time.Time          `json:"updated_at"`
+	Edited          bool               `json:"edited"`
+	EditedAt        *time.Time         `json:"edited_at,omitempty"`
+	Reactions       []ReactionSummary  `json:"reactions,omitempty"`
+	Depth           int                `json:"depth"`
+	ReplyCount      int                `json:"reply_count"`
+	Replies         []GroupPostComment `json:"replies"`
 }
 
 type GroupEvent struct {
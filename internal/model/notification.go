@@ -16,3 +16,24 @@ type NotificationMessage struct {
 	Type    string      `json:"type"`
 	Content interface{} `json:"content"`
 }
+
+// Notification delivery settings, from most to least intrusive.
+const (
+	DeliveryPush  = "push"   // stored and pushed over the socket
+	DeliveryInApp = "in_app" // stored only, seen on the next fetch
+	DeliveryNone  = "none"   // not created at all
+)
+
+type NotificationPreference struct {
+	Type     string `json:"type"`
+	Delivery string `json:"delivery"`
+}
+
+// MutedConversation is a chat a user gets no message notifications for.
+type MutedConversation struct {
+	Type string `json:"type"` // "private" or "group"
+	// ID is the other user's ID for private chats and the group ID for
+	// group chats.
+	ID      string    `json:"id"`
+	MutedAt time.Time `json:"muted_at"`
+}
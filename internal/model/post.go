@@ -29,13 +29,47 @@ type UpdatePostInput struct {
 }
 
 type PostComment struct {
-	ID           string    `json:"id"`
-	PostID       string    `json:"post_id"`
-	UserID       string    `json:"user_id"`
-	Content      string    `json:"content"`
-	ImagePath    *string   `json:"image_path,omitempty"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
-	UserNickname string    `json:"user_nickname,omitempty"`
-	UserAvatar   string    `json:"user_avatar,omitempty"`
+	ID              string    `json:"id"`
+	PostID          string    `json:"post_id"`
+	UserID          string    `json:"user_id"`
+	ParentCommentID *string   `json:"parent_comment_id,omitempty"`
+	Content         string    `json:"content"`
+	ImagePath       *string   `json:"image_path,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+	UserNickname    string    `json:"user_nickname,omitempty"`
+	UserAvatar      string    `json:"user_avatar,omitempty"`
+	// Deleted comments are kept as placeholders while they have replies.
+	Deleted  bool       `json:"deleted,omitempty"`
+	Edited   bool       `json:"edited"`
+	EditedAt *time.Time `json:"edited_at,omitempty"`
+}
+
+// CommentEdit is a previous version of an edited post or group post comment.
+type CommentEdit struct {
+	ID              string    `json:"id"`
+	CommentID       string    `json:"comment_id"`
+	PreviousContent string    `json:"previous_content"`
+	EditedBy        string    `json:"edited_by"`
+	EditedAt        time.Time `json:"edited_at"`
+}
+
+// FeedItem is a post or group post in a user's home feed. Type is "post" or
+// "group_post"; group posts carry their group and no privacy.
+type FeedItem struct {
+	Type         string            `json:"type"`
+	ID           string            `json:"id"`
+	UserID       string            `json:"user_id"`
+	FirstName    string            `json:"first_name"`
+	LastName     string            `json:"last_name"`
+	Avatar       string            `json:"avatar"`
+	GroupID      *string           `json:"group_id,omitempty"`
+	GroupTitle   *string           `json:"group_title,omitempty"`
+	Content      string            `json:"content"`
+	ImagePath    *string           `json:"image_path,omitempty"`
+	Privacy      *string           `json:"privacy,omitempty"`
+	CommentCount int               `json:"comment_count"`
+	Reactions    []ReactionSummary `json:"reactions,omitempty"`
+	CreatedAt    time.Time         `json:"created_at"`
+	UpdatedAt    time.Time         `json:"updated_at"`
 }
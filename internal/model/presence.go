@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+type Presence struct {
+	UserID   string     `json:"user_id"`
+	Online   bool       `json:"online"`
+	LastSeen *time.Time `json:"last_seen,omitempty"`
+}
+
+type TypingEvent struct {
+	UserID      string `json:"user_id"`
+	RecipientID string `json:"recipient_id,omitempty"`
+	GroupID     string `json:"group_id,omitempty"`
+	IsTyping    bool   `json:"is_typing"`
+}
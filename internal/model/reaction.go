@@ -0,0 +1,36 @@
+package model
+
+// Reaction target types.
+const (
+	ReactionTargetPost         = "post"
+	ReactionTargetComment      = "comment"
+	ReactionTargetGroupPost    = "group_post"
+	ReactionTargetGroupComment = "group_comment"
+	ReactionTargetMessage      = "message"
+	ReactionTargetGroupMessage = "group_message"
+)
+
+// ReactionSummary aggregates the reactions with one emoji on a target.
+type ReactionSummary struct {
+	Emoji string `json:"emoji"`
+	Count int    `json:"count"`
+	// Reacted reports whether the requesting user is among them.
+	Reacted bool `json:"reacted"`
+}
+
+type ReactionInput struct {
+	TargetType string `json:"target_type"`
+	TargetID   string `json:"target_id"`
+	Emoji      string `json:"emoji"`
+}
+
+// ReactionEvent is pushed over the socket when a reaction is added or
+// removed. Reactions is the updated summary of the target.
+type ReactionEvent struct {
+	TargetType string            `json:"target_type"`
+	TargetID   string            `json:"target_id"`
+	UserID     string            `json:"user_id"`
+	Emoji      string            `json:"emoji"`
+	Added      bool              `json:"added"`
+	Reactions  []ReactionSummary `json:"reactions"`
+}
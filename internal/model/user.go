@@ -6,7 +6,7 @@ import (
 
 type User struct {
 	ID          string    `json:"id"`
-	Email       string    `json:"email"`
+	Email       string    `json:"email,omitempty"`
 	Password    string    `json:"-"`
 	FirstName   string    `json:"first_name"`
 	LastName    string    `json:"last_name"`
@@ -19,6 +19,16 @@ type User struct {
 	UpdatedAt   time.Time `json:"updated_at"`
 }
 
+// UserCard is what anyone may see of a user, including non-followers of a
+// private profile.
+type UserCard struct {
+	ID        string  `json:"id"`
+	FirstName string  `json:"first_name"`
+	LastName  string  `json:"last_name"`
+	Avatar    *string `json:"avatar,omitempty"`
+	IsPublic  bool    `json:"is_public"`
+}
+
 type RegisterInput struct {
 	Email       string  `json:"email"`
 	Password    string  `json:"password"`
@@ -32,6 +42,7 @@ type RegisterInput struct {
 }
 
 type LoginInput struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email      string `json:"email"`
+	Password   string `json:"password"`
+	RememberMe bool   `json:"remember_me"`
 }
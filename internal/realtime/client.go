@@ -0,0 +1,158 @@
+package realtime
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const sendBufferSize = 64
+
+var (
+	ErrClientClosed   = errors.New("client connection closed")
+	ErrSendBufferFull = errors.New("client send buffer full")
+)
+
+// Client is a single WebSocket connection. All writes to the underlying
+// connection happen on the client's own writer goroutine; everything else
+// queues frames through Send.
+type Client struct {
+	UserID    string
+	SessionID string
+
+	conn      *websocket.Conn
+	config    Config
+	send      chan []byte
+	mu        sync.Mutex
+	closed    bool
+	closeCode int
+	closeText string
+	// onActivity and lastActivity are only used by the reading goroutine.
+	onActivity   func(sessionID string)
+	lastActivity time.Time
+}
+
+func newClient(userID, sessionID string, conn *websocket.Conn, config Config) *Client {
+	return &Client{
+		UserID:    userID,
+		SessionID: sessionID,
+		conn:      conn,
+		config:    config,
+		send:      make(chan []byte, sendBufferSize),
+		closeCode: websocket.CloseNormalClosure,
+	}
+}
+
+// Send queues v, encoded as JSON, for delivery to this connection.
+func (c *Client) Send(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.enqueue(data)
+}
+
+// ReadMessage reads the next frame from the connection. Every frame received
+// pushes the idle deadline forward, as does every pong.
+func (c *Client) ReadMessage() ([]byte, error) {
+	_, data, err := c.conn.ReadMessage()
+	if err != nil {
+		return nil, err
+	}
+	c.reportActivity()
+	return data, c.conn.SetReadDeadline(time.Now().Add(c.config.PongWait))
+}
+
+// reportActivity tells the hub's activity callback that the session of this
+// connection is in use, unless it was told within the activity interval.
+func (c *Client) reportActivity() {
+	if c.onActivity == nil || c.SessionID == "" {
+		return
+	}
+	now := time.Now()
+	if now.Sub(c.lastActivity) < c.config.ActivityInterval {
+		return
+	}
+	c.lastActivity = now
+	c.onActivity(c.SessionID)
+}
+
+func (c *Client) enqueue(data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return ErrClientClosed
+	}
+
+	select {
+	case c.send <- data:
+		return nil
+	default:
+		// The client is not keeping up; drop it rather than block publishers.
+		c.closeLocked(websocket.ClosePolicyViolation, "send buffer full")
+		return ErrSendBufferFull
+	}
+}
+
+func (c *Client) prepareRead() {
+	c.conn.SetReadLimit(c.config.MaxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(c.config.PongWait))
+	c.conn.SetPongHandler(func(string) error {
+		c.reportActivity()
+		return c.conn.SetReadDeadline(time.Now().Add(c.config.PongWait))
+	})
+}
+
+func (c *Client) writePump() {
+	ticker := time.NewTicker(c.config.PingInterval)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+
+	for {
+		select {
+		case data, ok := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(c.config.WriteWait))
+			if !ok {
+				c.mu.Lock()
+				code, text := c.closeCode, c.closeText
+				c.mu.Unlock()
+				c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(code, text))
+				return
+			}
+			if err := c.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				log.Printf("WebSocket write error for %s: %v", c.UserID, err)
+				c.close(websocket.CloseAbnormalClosure, "")
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(c.config.WriteWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				log.Printf("WebSocket ping error for %s: %v", c.UserID, err)
+				c.close(websocket.CloseAbnormalClosure, "")
+				return
+			}
+		}
+	}
+}
+
+func (c *Client) close(code int, text string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closeLocked(code, text)
+}
+
+func (c *Client) closeLocked(code int, text string) {
+	if !c.closed {
+		c.closed = true
+		c.closeCode = code
+		c.closeText = text
+		close(c.send)
+	}
+}
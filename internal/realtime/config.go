@@ -0,0 +1,28 @@
+package realtime
+
+import "time"
+
+// Config holds the connection timing settings of the hub.
+type Config struct {
+	// WriteWait is the time allowed to write a single frame.
+	WriteWait time.Duration
+	// PongWait is how long a connection may stay silent (no message and no
+	// pong) before it is considered dead.
+	PongWait time.Duration
+	// PingInterval is how often the server pings each client. It must be
+	// shorter than PongWait.
+	PingInterval time.Duration
+	// MaxMessageSize is the largest inbound frame accepted, in bytes.
+	MaxMessageSize int64
+	// ActivityInterval is the minimum time between two reports of session
+	// activity from the same connection.
+	ActivityInterval time.Duration
+}
+
+var DefaultConfig = Config{
+	WriteWait:        10 * time.Second,
+	PongWait:         60 * time.Second,
+	PingInterval:     54 * time.Second,
+	MaxMessageSize:   64 << 10,
+	ActivityInterval: time.Minute,
+}
@@ -0,0 +1,194 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// Hub owns every open WebSocket connection and routes outbound frames to
+// them. A user may hold several connections at once (one per tab or device);
+// frames published to a user are delivered to all of them.
+type Hub struct {
+	config           Config
+	clients          map[string]map[*Client]struct{}
+	mu               sync.RWMutex
+	writers          sync.WaitGroup
+	shutdown         bool
+	onPresenceChange func(userID string, online bool)
+	onActivity       func(sessionID string)
+}
+
+func NewHub(config Config) *Hub {
+	return &Hub{
+		config:  config,
+		clients: make(map[string]map[*Client]struct{}),
+	}
+}
+
+// Register starts the writer goroutine for conn and adds it to the
+// connections of userID, opened under sessionID. Callers must read from the
+// connection through the returned client so that idle deadlines are
+// maintained.
+func (h *Hub) Register(userID, sessionID string, conn *websocket.Conn) *Client {
+	client := newClient(userID, sessionID, conn, h.config)
+	h.mu.RLock()
+	client.onActivity = h.onActivity
+	h.mu.RUnlock()
+	client.prepareRead()
+
+	h.mu.Lock()
+	h.writers.Add(1)
+	go func() {
+		defer h.writers.Done()
+		client.writePump()
+	}()
+
+	if h.shutdown {
+		client.close(websocket.CloseGoingAway, "server shutting down")
+		h.mu.Unlock()
+		return client
+	}
+
+	cameOnline := len(h.clients[userID]) == 0
+	if cameOnline {
+		h.clients[userID] = make(map[*Client]struct{})
+	}
+	h.clients[userID][client] = struct{}{}
+	onPresenceChange := h.onPresenceChange
+	h.mu.Unlock()
+
+	if cameOnline && onPresenceChange != nil {
+		onPresenceChange(userID, true)
+	}
+	return client
+}
+
+// Unregister removes a single connection from the hub and closes it. Other
+// connections of the same user are left untouched.
+func (h *Hub) Unregister(client *Client) {
+	wentOffline := false
+	h.mu.Lock()
+	if clients, ok := h.clients[client.UserID]; ok {
+		if _, registered := clients[client]; registered {
+			delete(clients, client)
+			if len(clients) == 0 {
+				delete(h.clients, client.UserID)
+				wentOffline = true
+			}
+		}
+	}
+	onPresenceChange := h.onPresenceChange
+	h.mu.Unlock()
+
+	client.close(websocket.CloseNormalClosure, "")
+
+	if wentOffline && onPresenceChange != nil {
+		onPresenceChange(client.UserID, false)
+	}
+}
+
+// OnPresenceChange registers fn to be called when a user opens their first
+// connection (online) or closes their last one (offline).
+func (h *Hub) OnPresenceChange(fn func(userID string, online bool)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.onPresenceChange = fn
+}
+
+// OnSessionActivity registers fn to be called with the session of a
+// connection when it sends a frame or answers a ping, at most once per
+// ActivityInterval per connection, so sessions used only over WebSocket
+// stay alive.
+func (h *Hub) OnSessionActivity(fn func(sessionID string)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.onActivity = fn
+}
+
+// Publish sends v, encoded as JSON, to every connection of userID. It is a
+// no-op when the user is not connected.
+func (h *Hub) Publish(userID string, v interface{}) error {
+	clients := h.userClients(userID)
+	if len(clients) == 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, client := range clients {
+		if err := client.enqueue(data); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// CloseSessions closes the connections of userID whose session has ended,
+// as reported by ended. Connections of other sessions are left open.
+func (h *Hub) CloseSessions(userID string, ended func(sessionID string) bool) {
+	for _, client := range h.userClients(userID) {
+		if ended(client.SessionID) {
+			client.close(websocket.ClosePolicyViolation, "session ended")
+		}
+	}
+}
+
+// Shutdown sends a close frame to every connected client and waits for the
+// writers to finish, or for ctx to be done.
+func (h *Hub) Shutdown(ctx context.Context) error {
+	h.mu.Lock()
+	h.shutdown = true
+	for _, clients := range h.clients {
+		for client := range clients {
+			client.close(websocket.CloseGoingAway, "server shutting down")
+		}
+	}
+	h.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		h.writers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (h *Hub) IsConnected(userID string) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return len(h.clients[userID]) > 0
+}
+
+// ConnectionCount returns the number of open connections of userID.
+func (h *Hub) ConnectionCount(userID string) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return len(h.clients[userID])
+}
+
+func (h *Hub) userClients(userID string) []*Client {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	clients := make([]*Client, 0, len(h.clients[userID]))
+	for client := range h.clients[userID] {
+		clients = append(clients, client)
+	}
+	return clients
+}
@@ -0,0 +1,340 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// connect opens a WebSocket connection for userID registered with hub. The
+// server side reads until the connection fails and then unregisters it, as
+// the WebSocket handler does.
+func connect(t *testing.T, hub *Hub, userID string) (*Client, *websocket.Conn) {
+	t.Helper()
+	return connectSession(t, hub, userID, "")
+}
+
+// connectSession is connect for a connection opened under sessionID.
+func connectSession(t *testing.T, hub *Hub, userID, sessionID string) (*Client, *websocket.Conn) {
+	t.Helper()
+	upgrader := websocket.Upgrader{}
+	clients := make(chan *Client, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		client := hub.Register(userID, sessionID, conn)
+		clients <- client
+		defer hub.Unregister(client)
+		for {
+			if _, err := client.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return <-clients, conn
+}
+
+// receive reads the next frame from conn.
+func receive(t *testing.T, conn *websocket.Conn) Frame {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var frame Frame
+	if err := conn.ReadJSON(&frame); err != nil {
+		t.Fatal(err)
+	}
+	return frame
+}
+
+// expectSilence fails if a frame arrives on conn within a short wait.
+func expectSilence(t *testing.T, conn *websocket.Conn) {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	var frame json.RawMessage
+	if err := conn.ReadJSON(&frame); err == nil {
+		t.Errorf("unexpected frame %s", frame)
+	}
+}
+
+func TestPublishRoutesToTheUser(t *testing.T) {
+	hub := NewHub(DefaultConfig)
+	_, alice := connect(t, hub, "alice")
+	_, bob := connect(t, hub, "bob")
+
+	if err := hub.Publish("alice", NewFrame(TypeNotification, "hello")); err != nil {
+		t.Fatal(err)
+	}
+	if frame := receive(t, alice); frame.Type != TypeNotification || frame.Payload != "hello" {
+		t.Errorf("alice got %+v", frame)
+	}
+	expectSilence(t, bob)
+
+	if err := hub.Publish("carol", NewFrame(TypeNotification, "hello")); err != nil {
+		t.Errorf("publishing to a user without connections: %v", err)
+	}
+}
+
+func TestUnregisterRemovesTheConnection(t *testing.T) {
+	hub := NewHub(DefaultConfig)
+	client, conn := connect(t, hub, "alice")
+	if !hub.IsConnected("alice") {
+		t.Fatal("alice is not connected")
+	}
+
+	hub.Unregister(client)
+	if hub.IsConnected("alice") {
+		t.Error("alice still connected after unregistering")
+	}
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, _, err := conn.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+		t.Errorf("read after unregister: %v, want a normal close", err)
+	}
+	if err := client.Send(NewFrame(TypeNotification, "late")); err != ErrClientClosed {
+		t.Errorf("sending to an unregistered client: %v, want ErrClientClosed", err)
+	}
+}
+
+func TestPublishFansOutToEveryConnection(t *testing.T) {
+	hub := NewHub(DefaultConfig)
+	var presence []string
+	changes := make(chan struct{}, 10)
+	hub.OnPresenceChange(func(userID string, online bool) {
+		state := "offline"
+		if online {
+			state = "online"
+		}
+		presence = append(presence, userID+" "+state)
+		changes <- struct{}{}
+	})
+
+	laptop, laptopConn := connect(t, hub, "alice")
+	phone, phoneConn := connect(t, hub, "alice")
+	_, tabletConn := connect(t, hub, "alice")
+	if n := hub.ConnectionCount("alice"); n != 3 {
+		t.Fatalf("alice has %d connections, want 3", n)
+	}
+
+	if err := hub.Publish("alice", NewFrame(TypeNotification, "hello")); err != nil {
+		t.Fatal(err)
+	}
+	for _, conn := range []*websocket.Conn{laptopConn, phoneConn, tabletConn} {
+		if frame := receive(t, conn); frame.Payload != "hello" {
+			t.Errorf("got %+v", frame)
+		}
+	}
+
+	// Closing one tab leaves the others connected
+	hub.Unregister(laptop)
+	if err := hub.Publish("alice", NewFrame(TypeNotification, "again")); err != nil {
+		t.Fatal(err)
+	}
+	for _, conn := range []*websocket.Conn{phoneConn, tabletConn} {
+		if frame := receive(t, conn); frame.Payload != "again" {
+			t.Errorf("got %+v", frame)
+		}
+	}
+	hub.Unregister(phone)
+	if !hub.IsConnected("alice") {
+		t.Error("alice went offline with a connection left")
+	}
+
+	// Presence changes only on the first and last connection
+	tabletConn.Close()
+	select {
+	case <-changes:
+	case <-time.After(2 * time.Second):
+		t.Fatal("no presence change after the last connection closed")
+	}
+	<-changes
+	if strings.Join(presence, ", ") != "alice online, alice offline" {
+		t.Errorf("presence changes: %v", presence)
+	}
+}
+
+var fastConfig = Config{
+	WriteWait:      time.Second,
+	PongWait:       300 * time.Millisecond,
+	PingInterval:   100 * time.Millisecond,
+	MaxMessageSize: 1 << 10,
+}
+
+// waitFor polls cond until it holds or a few seconds pass.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting until %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestHeartbeatKeepsResponsiveClients(t *testing.T) {
+	hub := NewHub(fastConfig)
+	_, conn := connect(t, hub, "alice")
+
+	pings := make(chan struct{}, 100)
+	conn.SetPingHandler(func(data string) error {
+		pings <- struct{}{}
+		return conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+	})
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	// Answering pings keeps the connection open well past PongWait
+	time.Sleep(3 * fastConfig.PongWait)
+	if len(pings) < 2 {
+		t.Errorf("got %d pings, want several", len(pings))
+	}
+	if !hub.IsConnected("alice") {
+		t.Error("responsive client was dropped")
+	}
+}
+
+func TestIdleClientsAreDropped(t *testing.T) {
+	hub := NewHub(fastConfig)
+	// The client never reads, so it never answers pings
+	connect(t, hub, "alice")
+	waitFor(t, "the idle client is dropped", func() bool { return !hub.IsConnected("alice") })
+}
+
+func TestOversizedFramesCloseTheConnection(t *testing.T) {
+	hub := NewHub(fastConfig)
+	_, conn := connect(t, hub, "alice")
+	if err := conn.WriteMessage(websocket.TextMessage, make([]byte, 2<<10)); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the connection is dropped", func() bool { return !hub.IsConnected("alice") })
+}
+
+func TestShutdownClosesConnections(t *testing.T) {
+	hub := NewHub(DefaultConfig)
+	_, alice := connect(t, hub, "alice")
+	_, bob := connect(t, hub, "bob")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := hub.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, conn := range []*websocket.Conn{alice, bob} {
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		if _, _, err := conn.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+			t.Errorf("read after shutdown: %v, want a going away close", err)
+		}
+	}
+
+	// Connections arriving during shutdown are closed straight away
+	_, late := connect(t, hub, "carol")
+	late.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, _, err := late.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Errorf("read on a connection opened during shutdown: %v", err)
+	}
+	if hub.IsConnected("carol") {
+		t.Error("connection registered during shutdown")
+	}
+}
+
+func TestCloseSessionsClosesOnlyEndedSessions(t *testing.T) {
+	hub := NewHub(DefaultConfig)
+	_, laptop := connectSession(t, hub, "alice", "laptop")
+	_, phone := connectSession(t, hub, "alice", "phone")
+	_, bob := connectSession(t, hub, "bob", "phone")
+
+	hub.CloseSessions("alice", func(sessionID string) bool { return sessionID == "phone" })
+
+	phone.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, _, err := phone.ReadMessage(); !websocket.IsCloseError(err, websocket.ClosePolicyViolation) {
+		t.Errorf("read on the ended session: %v, want a policy violation close", err)
+	}
+	waitFor(t, "the ended connection is unregistered", func() bool { return hub.ConnectionCount("alice") == 1 })
+
+	if err := hub.Publish("alice", NewFrame(TypeNotification, "still here")); err != nil {
+		t.Fatal(err)
+	}
+	if frame := receive(t, laptop); frame.Type != TypeNotification {
+		t.Errorf("laptop got %q, want a notification", frame.Type)
+	}
+	if err := hub.Publish("bob", NewFrame(TypeNotification, "still here")); err != nil {
+		t.Fatal(err)
+	}
+	if frame := receive(t, bob); frame.Type != TypeNotification {
+		t.Errorf("bob got %q, want a notification", frame.Type)
+	}
+}
+
+func TestSessionActivityIsReported(t *testing.T) {
+	hub := NewHub(fastConfig)
+	activity := make(chan string, 100)
+	hub.OnSessionActivity(func(sessionID string) { activity <- sessionID })
+
+	// Answering pings is activity, without the client sending any frame
+	_, conn := connectSession(t, hub, "alice", "laptop")
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+	waitFor(t, "pongs are reported", func() bool { return len(activity) >= 2 })
+	for len(activity) > 0 {
+		if sessionID := <-activity; sessionID != "laptop" {
+			t.Fatalf("activity reported for %q, want laptop", sessionID)
+		}
+	}
+
+	// Connections without a session have nothing to report
+	_, anonymous := connect(t, hub, "bob")
+	if err := anonymous.WriteMessage(websocket.TextMessage, []byte(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	for len(activity) > 0 {
+		if sessionID := <-activity; sessionID != "laptop" {
+			t.Fatalf("activity reported for %q, want only laptop", sessionID)
+		}
+	}
+}
+
+func TestSessionActivityIsThrottled(t *testing.T) {
+	config := DefaultConfig
+	config.ActivityInterval = time.Hour
+	hub := NewHub(config)
+	activity := make(chan string, 100)
+	hub.OnSessionActivity(func(sessionID string) { activity <- sessionID })
+
+	_, conn := connectSession(t, hub, "alice", "laptop")
+	for i := 0; i < 3; i++ {
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(`{}`)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitFor(t, "the first frame is reported", func() bool { return len(activity) == 1 })
+	time.Sleep(100 * time.Millisecond)
+	if n := len(activity); n != 1 {
+		t.Errorf("%d activity reports within the interval, want 1", n)
+	}
+}
@@ -0,0 +1,236 @@
+// Package realtime manages WebSocket connections and defines the protocol
+// spoken over them.
+//
+// Every frame, in both directions, is a JSON envelope:
+//
+//	{"type": "send_private_message", "request_id": "abc", "version": 1, "payload": {...}}
+//
+// type selects the operation or event, request_id is chosen by the client and
+// echoed on every reply to that request, version is the protocol version and
+// payload carries the type-specific body.
+//
+// Frames without a version (or with version 0) are treated as legacy frames:
+// the type-specific fields sit next to "type" instead of inside "payload".
+// Server frames are always sent as version 1 envelopes.
+//
+// A request that fails is answered with an "error" frame whose payload is an
+// ErrorPayload. A request that changes state and succeeds is answered with an
+// "ack" frame whose payload is an AckPayload; for sent messages it carries
+// the ID of the persisted message. Requests that fetch data are answered with
+// a frame of the matching response type instead.
+package realtime
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+const ProtocolVersion = 1
+
+// Client request types.
+const (
+	TypeGetNotifications   = "get_notifications"
+	TypeMarkRead           = "mark_read"
+	TypeMarkAllRead        = "mark_all_read"
+	TypeSendPrivateMessage = "send_private_message"
+	TypeSendGroupMessage   = "send_group_message"
+	TypeGetPrivateHistory  = "get_private_history"
+	TypeGetGroupHistory    = "get_group_history"
+	TypeMarkMessagesRead   = "mark_messages_read"
+	TypeGetUnreadMessages  = "get_unread_messages"
+	TypeGetConversations   = "get_conversations"
+	TypeEditMessage        = "edit_message"
+	TypeDeleteMessage      = "delete_message"
+	// TypeTyping is sent by clients while composing a message and relayed by
+	// the server to the other participants with a model.TypingEvent payload.
+	TypeTyping = "typing"
+)
+
+// Server frame types.
+const (
+	TypeAck                   = "ack"
+	TypeError                 = "error"
+	TypeNotification          = "notification"
+	TypeNotifications         = "notifications"
+	TypePrivateMessage        = "private_message"
+	TypeGroupMessage          = "group_message"
+	TypePrivateMessageHistory = "private_message_history"
+	TypeGroupMessageHistory   = "group_message_history"
+	TypeUnreadMessages        = "unread_messages"
+	TypeMessagesRead          = "messages_read"
+	TypeConversations         = "conversations"
+	TypeGroupRead             = "group_read"
+	TypeMessageEdited         = "message_edited"
+	TypeMessageDeleted        = "message_deleted"
+	TypeReaction              = "reaction"
+	TypePresence              = "presence"
+	// TypeNotificationsRemoved tells the client to drop notifications that
+	// were replaced or whose subject is gone.
+	TypeNotificationsRemoved = "notifications_removed"
+)
+
+// Error codes carried in ErrorPayload.
+const (
+	ErrCodeInvalidFrame       = "invalid_frame"
+	ErrCodeUnsupportedVersion = "unsupported_version"
+	ErrCodeUnknownType        = "unknown_type"
+	ErrCodeInvalidPayload     = "invalid_payload"
+	ErrCodeForbidden          = "forbidden"
+	ErrCodeNotFound           = "not_found"
+	ErrCodeConflict           = "conflict"
+	ErrCodeInternal           = "internal_error"
+)
+
+var ErrUnsupportedVersion = errors.New("unsupported protocol version")
+
+// Envelope is an inbound frame with its payload left undecoded.
+type Envelope struct {
+	Type      string          `json:"type"`
+	RequestID string          `json:"request_id,omitempty"`
+	Version   int             `json:"version"`
+	Payload   json.RawMessage `json:"payload,omitempty"`
+}
+
+// Frame is an outbound frame.
+type Frame struct {
+	Type      string      `json:"type"`
+	RequestID string      `json:"request_id,omitempty"`
+	Version   int         `json:"version"`
+	Payload   interface{} `json:"payload,omitempty"`
+}
+
+type ErrorPayload struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type AckPayload struct {
+	// For is the type of the request being acknowledged.
+	For       string `json:"for"`
+	MessageID string `json:"message_id,omitempty"`
+}
+
+type NotificationsRemovedPayload struct {
+	NotificationIDs []string `json:"notification_ids"`
+}
+
+type SendPrivateMessagePayload struct {
+	RecipientID string `json:"recipient_id"`
+	Content     string `json:"content"`
+}
+
+type SendGroupMessagePayload struct {
+	GroupID string `json:"group_id"`
+	Content string `json:"content"`
+}
+
+type EditMessagePayload struct {
+	MessageID string `json:"message_id"`
+	Content   string `json:"content"`
+}
+
+type DeleteMessagePayload struct {
+	MessageID string `json:"message_id"`
+}
+
+// PagePayload holds the optional pagination fields of history requests.
+// Before and After take the next_cursor of a previous page.
+type PagePayload struct {
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+	Limit  int    `json:"limit,omitempty"`
+}
+
+type PrivateHistoryPayload struct {
+	OtherUserID string `json:"other_user_id"`
+	// Format is "timeline" (the default) for a single ordered list of
+	// messages, or "split" for the older sent/received shape.
+	Format string `json:"format,omitempty"`
+	PagePayload
+}
+
+type GroupHistoryPayload struct {
+	GroupID string `json:"group_id"`
+	PagePayload
+}
+
+// TypingPayload targets either a private conversation (RecipientID) or a
+// group chat (GroupID).
+type TypingPayload struct {
+	RecipientID string `json:"recipient_id,omitempty"`
+	GroupID     string `json:"group_id,omitempty"`
+	IsTyping    bool   `json:"is_typing"`
+}
+
+type MarkReadPayload struct {
+	NotificationID string `json:"notification_id"`
+}
+
+// MarkAllReadPayload marks every unread notification as read, or only those
+// of NotificationType. The field can't be called "type": legacy frames carry
+// their payload next to the frame type.
+type MarkAllReadPayload struct {
+	NotificationType string `json:"notification_type,omitempty"`
+}
+
+// GetNotificationsPayload is optional; without it the most recent
+// notifications of every type are returned.
+type GetNotificationsPayload struct {
+	Types  []string `json:"types,omitempty"`
+	IsRead *bool    `json:"is_read,omitempty"`
+	PagePayload
+}
+
+// MarkMessagesReadPayload marks either the private messages from SenderID or
+// a whole group chat (GroupID) as read.
+type MarkMessagesReadPayload struct {
+	SenderID string `json:"sender_id,omitempty"`
+	GroupID  string `json:"group_id,omitempty"`
+}
+
+// DecodeEnvelope parses an inbound frame. For legacy frames the whole frame
+// becomes the payload.
+func DecodeEnvelope(data []byte) (Envelope, error) {
+	var envelope Envelope
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return Envelope{}, err
+	}
+	if envelope.Version > ProtocolVersion {
+		return envelope, ErrUnsupportedVersion
+	}
+	if envelope.Version == 0 {
+		envelope.Payload = json.RawMessage(data)
+	}
+	return envelope, nil
+}
+
+// DecodePayload unmarshals the envelope payload into v.
+func (e Envelope) DecodePayload(v interface{}) error {
+	if len(e.Payload) == 0 {
+		return errors.New("missing payload")
+	}
+	return json.Unmarshal(e.Payload, v)
+}
+
+func NewFrame(frameType string, payload interface{}) Frame {
+	return Frame{
+		Type:    frameType,
+		Version: ProtocolVersion,
+		Payload: payload,
+	}
+}
+
+// Reply builds a response frame to the request with the given ID.
+func Reply(requestID string, frameType string, payload interface{}) Frame {
+	frame := NewFrame(frameType, payload)
+	frame.RequestID = requestID
+	return frame
+}
+
+func Ack(requestID string, requestType string, messageID string) Frame {
+	return Reply(requestID, TypeAck, AckPayload{For: requestType, MessageID: messageID})
+}
+
+func ErrorFrame(requestID string, code string, message string) Frame {
+	return Reply(requestID, TypeError, ErrorPayload{Code: code, Message: message})
+}
@@ -0,0 +1,112 @@
+package realtime
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDecodeEnvelope(t *testing.T) {
+	t.Run("versioned", func(t *testing.T) {
+		env, err := DecodeEnvelope([]byte(`{"type":"send_private_message","request_id":"r1","version":1,
+            "payload":{"recipient_id":"bob","content":"hi"}}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if env.Type != TypeSendPrivateMessage || env.RequestID != "r1" || env.Version != 1 {
+			t.Errorf("envelope = %+v", env)
+		}
+		var payload SendPrivateMessagePayload
+		if err := env.DecodePayload(&payload); err != nil {
+			t.Fatal(err)
+		}
+		if payload.RecipientID != "bob" || payload.Content != "hi" {
+			t.Errorf("payload = %+v", payload)
+		}
+	})
+
+	t.Run("legacy", func(t *testing.T) {
+		// Legacy frames have no version and their fields next to "type"
+		env, err := DecodeEnvelope([]byte(`{"type":"send_private_message","request_id":"r2",
+            "recipient_id":"bob","content":"hi"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if env.Type != TypeSendPrivateMessage || env.RequestID != "r2" || env.Version != 0 {
+			t.Errorf("envelope = %+v", env)
+		}
+		var payload SendPrivateMessagePayload
+		if err := env.DecodePayload(&payload); err != nil {
+			t.Fatal(err)
+		}
+		if payload.RecipientID != "bob" || payload.Content != "hi" {
+			t.Errorf("payload = %+v", payload)
+		}
+	})
+
+	t.Run("legacy without fields", func(t *testing.T) {
+		env, err := DecodeEnvelope([]byte(`{"type":"mark_all_read"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var payload MarkAllReadPayload
+		if err := env.DecodePayload(&payload); err != nil {
+			t.Fatal(err)
+		}
+		if payload.NotificationType != "" {
+			t.Errorf("the frame type leaked into the payload: %+v", payload)
+		}
+	})
+
+	t.Run("unsupported version", func(t *testing.T) {
+		env, err := DecodeEnvelope([]byte(`{"type":"typing","request_id":"r3","version":2}`))
+		if err != ErrUnsupportedVersion {
+			t.Fatalf("err = %v, want ErrUnsupportedVersion", err)
+		}
+		// The request ID is still known so the error can be answered
+		if env.RequestID != "r3" {
+			t.Errorf("request ID = %q", env.RequestID)
+		}
+	})
+
+	t.Run("malformed", func(t *testing.T) {
+		if _, err := DecodeEnvelope([]byte(`{"type":`)); err == nil {
+			t.Error("malformed frame decoded")
+		}
+	})
+
+	t.Run("missing payload", func(t *testing.T) {
+		env, err := DecodeEnvelope([]byte(`{"type":"delete_message","version":1}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var payload DeleteMessagePayload
+		if err := env.DecodePayload(&payload); err == nil {
+			t.Error("missing payload decoded")
+		}
+	})
+}
+
+func TestReplyFrames(t *testing.T) {
+	tests := []struct {
+		frame Frame
+		want  string
+	}{
+		{
+			Ack("r1", TypeSendGroupMessage, "m1"),
+			`{"type":"ack","request_id":"r1","version":1,"payload":{"for":"send_group_message","message_id":"m1"}}`,
+		},
+		{
+			ErrorFrame("r2", ErrCodeForbidden, "not a member"),
+			`{"type":"error","request_id":"r2","version":1,"payload":{"code":"forbidden","message":"not a member"}}`,
+		},
+	}
+	for _, tt := range tests {
+		data, err := json.Marshal(tt.frame)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != tt.want {
+			t.Errorf("got  %s\nwant %s", data, tt.want)
+		}
+	}
+}
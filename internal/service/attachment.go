@@ -0,0 +1,179 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrAttachmentType     = errors.New("file type not allowed")
+	ErrAttachmentTooLarge = errors.New("file too large")
+)
+
+// AttachmentPathPrefix is the URL path under which attachments are served.
+const AttachmentPathPrefix = "/attachments/"
+
+// Attachment is a file sent with a chat message. Path is served under
+// AttachmentPathPrefix to participants of the conversation only.
+type Attachment struct {
+	ID        string `json:"id"`
+	MessageID string `json:"message_id"`
+	Path      string `json:"path"`
+	FileName  string `json:"file_name"`
+	MimeType  string `json:"mime_type"`
+	Size      int64  `json:"size"`
+	// Width and Height are set for images.
+	Width  int `json:"width,omitempty"`
+	Height int `json:"height,omitempty"`
+}
+
+// IsImage reports whether mimeType is one of the image types accepted in chat.
+func IsImage(mimeType string) bool {
+	switch mimeType {
+	case "image/jpeg", "image/png", "image/gif", "image/webp":
+		return true
+	}
+	return false
+}
+
+// ValidateAttachment checks a file against the configured size limit and
+// type allowlist. Images are always allowed.
+func (s *ChatService) ValidateAttachment(mimeType string, size int64) error {
+	if size > s.config.MaxAttachmentSize {
+		return ErrAttachmentTooLarge
+	}
+	if IsImage(mimeType) {
+		return nil
+	}
+	for _, allowed := range s.config.AttachmentTypes {
+		if mimeType == allowed {
+			return nil
+		}
+	}
+	return ErrAttachmentType
+}
+
+func (s *ChatService) Config() ChatConfig {
+	return s.config
+}
+
+// AttachmentFile returns where the file of attachment is stored.
+func (s *ChatService) AttachmentFile(attachment *Attachment) string {
+	return filepath.Join(s.config.AttachmentDir, path.Base(attachment.Path))
+}
+
+// Get an attachment by its upload path, provided userID takes part in the
+// conversation it was sent to
+func (s *ChatService) GetAttachmentByPath(urlPath, userID string) (*Attachment, error) {
+	attachment, err := scanAttachment(s.db.QueryRow(`
+        SELECT `+attachmentColumns+`
+        FROM message_attachments
+        WHERE path = ?`,
+		urlPath,
+	))
+	if err == sql.ErrNoRows {
+		return nil, ErrMessageNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	msg, err := s.getMessage(attachment.MessageID)
+	if err != nil {
+		return nil, err
+	}
+	participant, err := s.isParticipant(msg, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !participant {
+		return nil, ErrMessageNotFound
+	}
+	return attachment, nil
+}
+
+const attachmentColumns = `id, message_id, path, file_name, mime_type, size, width, height`
+
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+func saveAttachment(db execer, attachment *Attachment, msg *Message) error {
+	attachment.ID = uuid.New().String()
+	attachment.MessageID = msg.ID
+	_, err := db.Exec(`
+        INSERT INTO message_attachments (`+attachmentColumns+`, message_type, created_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		attachment.ID, attachment.MessageID, attachment.Path, attachment.FileName,
+		attachment.MimeType, attachment.Size, attachment.Width, attachment.Height,
+		msg.Type, time.Now(),
+	)
+	return err
+}
+
+// loadAttachments fills in the attachments of messages with a single query.
+func (s *ChatService) loadAttachments(messages []Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	index := make(map[string]int, len(messages))
+	args := make([]interface{}, len(messages))
+	for i, msg := range messages {
+		index[msg.ID] = i
+		args[i] = msg.ID
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(messages)), ", ")
+
+	rows, err := s.db.Query(`
+        SELECT `+attachmentColumns+`
+        FROM message_attachments
+        WHERE message_id IN (`+placeholders+`)`,
+		args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		attachment, err := scanAttachment(rows)
+		if err != nil {
+			return err
+		}
+		messages[index[attachment.MessageID]].Attachment = attachment
+	}
+	return rows.Err()
+}
+
+func (s *ChatService) removeAttachmentFile(attachment *Attachment) {
+	if err := os.Remove(s.AttachmentFile(attachment)); err != nil {
+		log.Printf("Failed to delete attachment file: %v", err)
+	}
+}
+
+func scanAttachment(row rowScanner) (*Attachment, error) {
+	var attachment Attachment
+	var width, height sql.NullInt64
+	err := row.Scan(
+		&attachment.ID, &attachment.MessageID, &attachment.Path, &attachment.FileName,
+		&attachment.MimeType, &attachment.Size, &width, &height,
+	)
+	if err != nil {
+		return nil, err
+	}
+	attachment.Width = int(width.Int64)
+	attachment.Height = int(height.Int64)
+	return &attachment, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"testing"
+)
+
+func TestValidateAttachment(t *testing.T) {
+	s := newTestChatService(t)
+	max := s.Config().MaxAttachmentSize
+
+	tests := []struct {
+		mimeType string
+		size     int64
+		want     error
+	}{
+		{"image/png", 1024, nil},
+		{"image/webp", max, nil},
+		{"application/pdf", 1024, nil},
+		{"text/plain", 1024, nil},
+		{"text/html", 1024, ErrAttachmentType},
+		{"application/octet-stream", 1024, ErrAttachmentType},
+		{"image/png", max + 1, ErrAttachmentTooLarge},
+		{"application/pdf", max + 1, ErrAttachmentTooLarge},
+	}
+	for _, tt := range tests {
+		if err := s.ValidateAttachment(tt.mimeType, tt.size); err != tt.want {
+			t.Errorf("ValidateAttachment(%q, %d) = %v, want %v", tt.mimeType, tt.size, err, tt.want)
+		}
+	}
+}
+
+func TestAttachmentsAreServedToParticipantsOnly(t *testing.T) {
+	s := newTestChatService(t)
+	for _, id := range []string{"alice", "bob", "carol"} {
+		seedUser(t, s.db, id, true)
+	}
+	seedGroup(t, s.db, "hikers", "alice", "bob")
+
+	private := &Attachment{Path: AttachmentPathPrefix + "private.pdf", FileName: "notes.pdf", MimeType: "application/pdf", Size: 10}
+	if _, err := s.SendPrivateMessage("alice", "bob", "", private); err != nil {
+		t.Fatal(err)
+	}
+	group := &Attachment{Path: AttachmentPathPrefix + "group.png", FileName: "map.png", MimeType: "image/png", Size: 10}
+	if _, err := s.SendGroupMessage("hikers", "alice", "", group); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path, userID string
+		want         error
+	}{
+		{private.Path, "alice", nil},
+		{private.Path, "bob", nil},
+		{private.Path, "carol", ErrMessageNotFound},
+		{group.Path, "bob", nil},
+		{group.Path, "carol", ErrMessageNotFound},
+		{AttachmentPathPrefix + "missing.png", "alice", ErrMessageNotFound},
+	}
+	for _, tt := range tests {
+		attachment, err := s.GetAttachmentByPath(tt.path, tt.userID)
+		if err != tt.want {
+			t.Errorf("GetAttachmentByPath(%q, %q) err = %v, want %v", tt.path, tt.userID, err, tt.want)
+			continue
+		}
+		if err == nil && attachment.Path != tt.path {
+			t.Errorf("GetAttachmentByPath(%q, %q) = %s", tt.path, tt.userID, attachment.Path)
+		}
+	}
+}
+
+func TestAttachmentFilesStayInTheAttachmentDir(t *testing.T) {
+	s := newTestChatService(t)
+	s.config.AttachmentDir = "/srv/attachments"
+
+	for path, want := range map[string]string{
+		AttachmentPathPrefix + "a.png":            "/srv/attachments/a.png",
+		AttachmentPathPrefix + "../../etc/passwd": "/srv/attachments/passwd",
+		"/uploads/chat/b.pdf":                     "/srv/attachments/b.pdf",
+	} {
+		if got := s.AttachmentFile(&Attachment{Path: path}); got != want {
+			t.Errorf("AttachmentFile(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
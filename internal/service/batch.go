@@ -0,0 +1,30 @@
+package service
+
+import "strings"
+
+// maxBatchIDs bounds the IDs bound in a single IN (...) list, keeping
+// queries well below SQLite's limit on bound variables however long the
+// listing they serve.
+const maxBatchIDs = 500
+
+// inBatches calls fn for consecutive runs of at most maxBatchIDs of ids, with
+// the placeholders and arguments of an IN list holding them.
+func inBatches(ids []string, fn func(placeholders string, args []interface{}) error) error {
+	for start := 0; start < len(ids); start += maxBatchIDs {
+		placeholders, args := inList(ids[start:min(start+maxBatchIDs, len(ids))])
+		if err := fn(placeholders, args); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// inList returns the placeholders and arguments of an IN list holding ids.
+// Callers bound the number of ids themselves, as with a page of results.
+func inList(ids []string) (string, []interface{}) {
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", "), args
+}
@@ -2,20 +2,47 @@ package service
 
 import (
 	"database/sql"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"social-network/internal/model"
 	"social-network/internal/notification"
+	"social-network/internal/realtime"
+	"sort"
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/gorilla/websocket"
 )
 
+// ChatConfig holds the tunable limits of the chat service.
+type ChatConfig struct {
+	// EditWindow is how long after sending a sender may still edit or delete
+	// a message.
+	EditWindow time.Duration
+	// MaxAttachmentSize is the largest file accepted as an attachment, in
+	// bytes.
+	MaxAttachmentSize int64
+	// AttachmentTypes lists the MIME types accepted as attachments besides
+	// images.
+	AttachmentTypes []string
+	// AttachmentDir is where attachment files are stored. It must not be
+	// served publicly: attachments are only served to participants of their
+	// conversation, under AttachmentPathPrefix.
+	AttachmentDir string
+}
+
+var DefaultChatConfig = ChatConfig{
+	EditWindow:        15 * time.Minute,
+	MaxAttachmentSize: 10 << 20,
+	AttachmentTypes:   []string{"application/pdf", "text/plain", "application/zip"},
+	AttachmentDir:     "./attachments",
+}
+
 type ChatService struct {
 	db                  *sql.DB
-	connections         map[string]*websocket.Conn
+	hub                 *realtime.Hub
 	notificationService notification.Service
+	config              ChatConfig
 }
 
 type Message struct {
@@ -26,19 +53,64 @@ type Message struct {
 	Content     string    `json:"content"`
 	CreatedAt   time.Time `json:"created_at"`
 	IsRead      bool      `json:"is_read,omitempty"`
-	Type        string    `json:"type"` // "private" or "group"
+	// ReadAt is when the recipient read a private message.
+	ReadAt   *time.Time `json:"read_at,omitempty"`
+	EditedAt *time.Time `json:"edited_at,omitempty"`
+	// DeletedAt is set on messages deleted for everyone; their content is
+	// cleared.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	Type      string     `json:"type"` // "private" or "group"
+	// Direction is "sent" or "received" from the requesting user's point of
+	// view. It is only set in private message timelines.
+	Direction string `json:"direction,omitempty"`
+	// SeenBy lists the members who have read a group message.
+	SeenBy     []string                `json:"seen_by,omitempty"`
+	Attachment *Attachment             `json:"attachment,omitempty"`
+	Reactions  []model.ReactionSummary `json:"reactions,omitempty"`
 }
 
-func NewChatService(db *sql.DB, notificationService notification.Service) *ChatService {
+// ReadReceipt tells a sender that their messages up to ReadAt were read.
+type ReadReceipt struct {
+	ReaderID string    `json:"reader_id"`
+	ReadAt   time.Time `json:"read_at"`
+}
+
+// GroupReadReceipt tells group members how far another member has read.
+type GroupReadReceipt struct {
+	GroupID           string    `json:"group_id"`
+	ReaderID          string    `json:"reader_id"`
+	LastReadMessageID string    `json:"last_read_message_id"`
+	ReadAt            time.Time `json:"read_at"`
+}
+
+// MessagePage is one page of a conversation in chronological order.
+// NextCursor is empty when there are no further messages in the paging
+// direction.
+type MessagePage struct {
+	Messages   []Message `json:"messages"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+}
+
+// PrivateHistory is one page of a private conversation split by direction.
+type PrivateHistory struct {
+	Sent       []Message `json:"sent"`
+	Received   []Message `json:"received"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+}
+
+var ErrNotGroupMember = errors.New("not a member of this group")
+
+func NewChatService(db *sql.DB, hub *realtime.Hub, notificationService notification.Service, config ChatConfig) *ChatService {
 	return &ChatService{
 		db:                  db,
-		connections:         make(map[string]*websocket.Conn),
+		hub:                 hub,
 		notificationService: notificationService,
+		config:              config,
 	}
 }
 
 // Send private message
-func (s *ChatService) SendPrivateMessage(senderID, recipientID, content string) error {
+func (s *ChatService) SendPrivateMessage(senderID, recipientID, content string, attachment *Attachment) (*Message, error) {
 	message := Message{
 		ID:          uuid.New().String(),
 		SenderID:    senderID,
@@ -49,20 +121,40 @@ func (s *ChatService) SendPrivateMessage(senderID, recipientID, content string)
 		Type:        "private",
 	}
 
-	_, err := s.db.Exec(`
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
         INSERT INTO messages (id, sender_id, recipient_id, content, created_at, is_read)
         VALUES (?, ?, ?, ?, ?, ?)`,
 		message.ID, message.SenderID, message.RecipientID, message.Content,
 		message.CreatedAt, message.IsRead,
 	)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// Send real-time if recipient is connected
-	if conn, ok := s.connections[recipientID]; ok {
-		messageJSON, _ := json.Marshal(message)
-		conn.WriteMessage(websocket.TextMessage, messageJSON)
+	if attachment != nil {
+		if err := saveAttachment(tx, attachment, &message); err != nil {
+			return nil, err
+		}
+		message.Attachment = attachment
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	// Send real-time to every open connection of both participants, so the
+	// sender's other tabs stay in sync too
+	frame := realtime.NewFrame(realtime.TypePrivateMessage, message)
+	for _, userID := range []string{recipientID, senderID} {
+		if err := s.hub.Publish(userID, frame); err != nil {
+			log.Printf("Error sending WebSocket message to %s: %v", userID, err)
+		}
 	}
 
 	// Create notification for recipient
@@ -72,7 +164,7 @@ func (s *ChatService) SendPrivateMessage(senderID, recipientID, content string)
 		err = s.notificationService.CreateNotification(
 			recipientID,
 			"private_message",
-			fmt.Sprintf("New message from %s: %s", senderName, content),
+			fmt.Sprintf("New message from %s: %s", senderName, messagePreview(&message)),
 			message.ID,
 		)
 		if err != nil {
@@ -80,15 +172,15 @@ func (s *ChatService) SendPrivateMessage(senderID, recipientID, content string)
 		}
 	}
 
-	return nil
+	return &message, nil
 }
 
 // Send group message
-func (s *ChatService) SendGroupMessage(groupID string, senderID string, content string) error {
+func (s *ChatService) SendGroupMessage(groupID string, senderID string, content string, attachment *Attachment) (*Message, error) {
 	// Start transaction
 	tx, err := s.db.Begin()
 	if err != nil {
-		return fmt.Errorf("error starting transaction: %v", err)
+		return nil, fmt.Errorf("error starting transaction: %v", err)
 	}
 	defer tx.Rollback()
 
@@ -98,8 +190,11 @@ func (s *ChatService) SendGroupMessage(groupID string, senderID string, content
         SELECT status FROM group_members 
         WHERE group_id = ? AND user_id = ? AND status = 'accepted'`,
 		groupID, senderID).Scan(&status)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotGroupMember
+	}
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	message := Message{
@@ -118,19 +213,26 @@ func (s *ChatService) SendGroupMessage(groupID string, senderID string, content
 		message.ID, message.GroupID, message.SenderID, message.Content, message.CreatedAt,
 	)
 	if err != nil {
-		return fmt.Errorf("error inserting message: %v", err)
+		return nil, fmt.Errorf("error inserting message: %v", err)
+	}
+
+	if attachment != nil {
+		if err := saveAttachment(tx, attachment, &message); err != nil {
+			return nil, fmt.Errorf("error saving attachment: %v", err)
+		}
+		message.Attachment = attachment
 	}
 
 	// Get group info and sender name
 	var groupTitle, senderName string
 	err = tx.QueryRow("SELECT first_name FROM users WHERE id = ?", senderID).Scan(&senderName)
 	if err != nil {
-		return fmt.Errorf("error getting sender name: %v", err)
+		return nil, fmt.Errorf("error getting sender name: %v", err)
 	}
 
 	err = tx.QueryRow("SELECT title FROM groups WHERE id = ?", groupID).Scan(&groupTitle)
 	if err != nil {
-		return fmt.Errorf("error getting group title: %v", err)
+		return nil, fmt.Errorf("error getting group title: %v", err)
 	}
 
 	// Get all group members
@@ -139,153 +241,418 @@ func (s *ChatService) SendGroupMessage(groupID string, senderID string, content
         WHERE group_id = ? AND status = 'accepted' AND user_id != ?`,
 		groupID, senderID)
 	if err != nil {
-		return fmt.Errorf("error getting group members: %v", err)
+		return nil, fmt.Errorf("error getting group members: %v", err)
 	}
 	defer rows.Close()
 
-	// Send to all connected members and create notifications
-	messageJSON, _ := json.Marshal(message)
+	var memberIDs []string
 	for rows.Next() {
 		var memberID string
 		if err := rows.Scan(&memberID); err != nil {
 			log.Printf("Error scanning member ID: %v", err)
 			continue
 		}
+		memberIDs = append(memberIDs, memberID)
+	}
+	rows.Close()
 
-		// Send WebSocket message if connected
-		if conn, ok := s.connections[memberID]; ok {
-			if err := conn.WriteMessage(websocket.TextMessage, messageJSON); err != nil {
-				log.Printf("Error sending WebSocket message to %s: %v", memberID, err)
-			}
+	// Commit transaction
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing transaction: %v", err)
+	}
+
+	// Send to all connected members and update their notification for the
+	// group, which honours each member's preferences and muted chats
+	frame := realtime.NewFrame(realtime.TypeGroupMessage, message)
+	for _, memberID := range memberIDs {
+		if err := s.hub.Publish(memberID, frame); err != nil {
+			log.Printf("Error sending WebSocket message to %s: %v", memberID, err)
 		}
 
-		// Create notification within the same transaction
-		notificationID := uuid.New().String()
-		_, err = tx.Exec(`
-            INSERT INTO notifications (id, user_id, type, content, reference_id, is_read, created_at)
-            VALUES (?, ?, ?, ?, ?, ?, ?)`,
-			notificationID,
-			memberID,
-			"group_message",
-			fmt.Sprintf("New message from %s in %s: %s", senderName, groupTitle, content),
-			message.ID,
-			false,
-			time.Now(),
-		)
-		if err != nil {
+		if err := s.notifyGroupMessage(memberID, groupTitle, senderName, &message); err != nil {
 			log.Printf("Error creating notification for member %s: %v", memberID, err)
-		} else {
-			log.Printf("Successfully created notification for member %s", memberID)
 		}
 	}
 
-	// Commit transaction
-	if err = tx.Commit(); err != nil {
-		return fmt.Errorf("error committing transaction: %v", err)
+	// Keep the sender's other connections in sync
+	if err := s.hub.Publish(senderID, frame); err != nil {
+		log.Printf("Error sending WebSocket message to %s: %v", senderID, err)
 	}
 
-	return nil
+	return &message, nil
 }
 
-// Get private chat history
-func (s *ChatService) GetPrivateMessageHistory(userID1, userID2 string) (map[string][]Message, error) {
-	rows, err := s.db.Query(`
-        SELECT id, sender_id, recipient_id, content, created_at, is_read
+// notifyGroupMessage keeps at most one unread group_message notification per
+// group for a member: their earlier unread ones are replaced by one counting
+// every message of the group they haven't read yet.
+func (s *ChatService) notifyGroupMessage(memberID, groupTitle, senderName string, message *Message) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	removed, err := deleteNotifications(tx, `
+        user_id = ? AND type = 'group_message' AND is_read = FALSE
+        AND reference_id IN (SELECT id FROM group_messages WHERE group_id = ?)`,
+		memberID, message.GroupID)
+	if err != nil {
+		return err
+	}
+
+	var unread int
+	err = tx.QueryRow(`
+        SELECT COUNT(*) FROM group_messages m
+        LEFT JOIN group_read_markers rm ON rm.group_id = m.group_id AND rm.user_id = ?
+        WHERE m.group_id = ? AND m.sender_id != ?
+        AND (rm.last_read_at IS NULL OR m.created_at > rm.last_read_at)`,
+		memberID, message.GroupID, memberID).Scan(&unread)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	publishNotificationsRemoved(s.hub, removed)
+
+	content := fmt.Sprintf("New message from %s in %s: %s", senderName, groupTitle, messagePreview(message))
+	if unread > 1 {
+		content = fmt.Sprintf("%d new messages in %s, latest from %s: %s", unread, groupTitle, senderName, messagePreview(message))
+	}
+	return s.notificationService.CreateNotification(memberID, "group_message", content, message.ID)
+}
+
+// Get private chat history as a single chronological timeline
+func (s *ChatService) GetPrivateTimeline(userID1, userID2 string, page PageRequest) (*MessagePage, error) {
+	query, args, descending, err := page.cursorQuery(`
+        SELECT id, sender_id, recipient_id, content, created_at, is_read, read_at,
+            edited_at, deleted_at
         FROM messages
-        WHERE (sender_id = ? AND recipient_id = ?)
-        OR (sender_id = ? AND recipient_id = ?)
-        ORDER BY created_at ASC`,
-		userID1, userID2, userID2, userID1,
+        WHERE ((sender_id = ? AND recipient_id = ?)
+        OR (sender_id = ? AND recipient_id = ?))`,
+		[]interface{}{userID1, userID2, userID2, userID1},
 	)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
-	sentMessages := []Message{}
-	receivedMessages := []Message{}
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
+	messages := []Message{}
 	for rows.Next() {
 		var msg Message
+		var readAt, editedAt, deletedAt sql.NullTime
 		msg.Type = "private"
 		err := rows.Scan(
 			&msg.ID, &msg.SenderID, &msg.RecipientID,
-			&msg.Content, &msg.CreatedAt, &msg.IsRead,
+			&msg.Content, &msg.CreatedAt, &msg.IsRead, &readAt,
+			&editedAt, &deletedAt,
 		)
 		if err != nil {
 			return nil, err
 		}
+		msg.ReadAt = nullTime(readAt)
+		msg.EditedAt = nullTime(editedAt)
+		msg.DeletedAt = nullTime(deletedAt)
 
+		msg.Direction = "received"
 		if msg.SenderID == userID1 {
-			sentMessages = append(sentMessages, msg)
+			msg.Direction = "sent"
+		}
+		messages = append(messages, msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	messages, nextCursor := paginate(page, messages, descending, oldestFirst, messageKey)
+	if err := s.loadAttachments(messages); err != nil {
+		return nil, err
+	}
+	if err := s.loadReactions(model.ReactionTargetMessage, messages, userID1); err != nil {
+		return nil, err
+	}
+	return &MessagePage{Messages: messages, NextCursor: nextCursor}, nil
+}
+
+// Get private chat history split into sent and received messages
+func (s *ChatService) GetPrivateMessageHistory(userID1, userID2 string, page PageRequest) (*PrivateHistory, error) {
+	timeline, err := s.GetPrivateTimeline(userID1, userID2, page)
+	if err != nil {
+		return nil, err
+	}
+
+	history := &PrivateHistory{
+		Sent:       []Message{},
+		Received:   []Message{},
+		NextCursor: timeline.NextCursor,
+	}
+	for _, msg := range timeline.Messages {
+		direction := msg.Direction
+		msg.Direction = ""
+		if direction == "sent" {
+			history.Sent = append(history.Sent, msg)
 		} else {
-			receivedMessages = append(receivedMessages, msg)
+			history.Received = append(history.Received, msg)
 		}
 	}
 
-	return map[string][]Message{
-		"sent":     sentMessages,
-		"received": receivedMessages,
-	}, nil
+	return history, nil
 }
 
 // Get group chat history
-func (s *ChatService) GetGroupMessageHistory(groupID, userID string) ([]Message, error) {
+func (s *ChatService) GetGroupMessageHistory(groupID, userID string, page PageRequest) (*MessagePage, error) {
 	// Verify user is group member
 	var status string
 	err := s.db.QueryRow(`
         SELECT status FROM group_members 
         WHERE group_id = ? AND user_id = ? AND status = 'accepted'`,
 		groupID, userID).Scan(&status)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotGroupMember
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	rows, err := s.db.Query(`
-        SELECT id, sender_id, content, created_at
+	query, args, descending, err := page.cursorQuery(`
+        SELECT id, sender_id, content, created_at, edited_at, deleted_at
         FROM group_messages
-        WHERE group_id = ?
-        ORDER BY created_at ASC`,
-		groupID,
+        WHERE group_id = ?`,
+		[]interface{}{groupID},
 	)
 	if err != nil {
 		return nil, err
 	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
 	defer rows.Close()
 
-	var messages []Message
+	messages := []Message{}
 	for rows.Next() {
 		var msg Message
+		var editedAt, deletedAt sql.NullTime
 		msg.Type = "group"
 		msg.GroupID = groupID
-		err := rows.Scan(&msg.ID, &msg.SenderID, &msg.Content, &msg.CreatedAt)
+		err := rows.Scan(&msg.ID, &msg.SenderID, &msg.Content, &msg.CreatedAt, &editedAt, &deletedAt)
 		if err != nil {
 			return nil, err
 		}
+		msg.EditedAt = nullTime(editedAt)
+		msg.DeletedAt = nullTime(deletedAt)
 		messages = append(messages, msg)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	messages, nextCursor := paginate(page, messages, descending, oldestFirst, messageKey)
+	if err := s.loadAttachments(messages); err != nil {
+		return nil, err
+	}
+	if err := s.loadReactions(model.ReactionTargetGroupMessage, messages, userID); err != nil {
+		return nil, err
+	}
+
+	markers, err := s.groupReadMarkers(groupID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range messages {
+		for memberID, lastReadAt := range markers {
+			if memberID != messages[i].SenderID && !lastReadAt.Before(messages[i].CreatedAt) {
+				messages[i].SeenBy = append(messages[i].SeenBy, memberID)
+			}
+		}
+		sort.Strings(messages[i].SeenBy)
+	}
 
-	return messages, nil
+	return &MessagePage{Messages: messages, NextCursor: nextCursor}, nil
+}
+
+// loadReactions fills in the reaction summaries of messages.
+func (s *ChatService) loadReactions(targetType string, messages []Message, viewerID string) error {
+	ids := make([]string, len(messages))
+	for i, msg := range messages {
+		ids[i] = msg.ID
+	}
+
+	summaries, err := loadReactionSummaries(s.db, targetType, ids, viewerID)
+	if err != nil {
+		return err
+	}
+	for i := range messages {
+		messages[i].Reactions = summaries[messages[i].ID]
+	}
+	return nil
+}
+
+// groupReadMarkers returns how far each member of a group has read.
+func (s *ChatService) groupReadMarkers(groupID string) (map[string]time.Time, error) {
+	rows, err := s.db.Query(`
+        SELECT user_id, last_read_at
+        FROM group_read_markers
+        WHERE group_id = ?`,
+		groupID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	markers := make(map[string]time.Time)
+	for rows.Next() {
+		var userID string
+		var lastReadAt time.Time
+		if err := rows.Scan(&userID, &lastReadAt); err != nil {
+			return nil, err
+		}
+		markers[userID] = lastReadAt
+	}
+	return markers, rows.Err()
 }
 
 // Mark private messages as read
 func (s *ChatService) MarkMessagesAsRead(senderID, recipientID string) error {
-	_, err := s.db.Exec(`
+	readAt := time.Now()
+	result, err := s.db.Exec(`
         UPDATE messages
-        SET is_read = TRUE
+        SET is_read = TRUE, read_at = ?
         WHERE sender_id = ? AND recipient_id = ? AND is_read = FALSE`,
-		senderID, recipientID,
+		readAt, senderID, recipientID,
 	)
-	return err
+	if err != nil {
+		return err
+	}
+
+	// Let the sender know their messages were read
+	if updated, err := result.RowsAffected(); err == nil && updated > 0 {
+		receipt := ReadReceipt{ReaderID: recipientID, ReadAt: readAt}
+		if err := s.hub.Publish(senderID, realtime.NewFrame(realtime.TypeMessagesRead, receipt)); err != nil {
+			log.Printf("Error sending read receipt to %s: %v", senderID, err)
+		}
+	}
+	return nil
 }
 
-// Connection management
-func (s *ChatService) RegisterConnection(userID string, conn *websocket.Conn) {
-	s.connections[userID] = conn
+// Mark every message of a group chat as read for a member
+func (s *ChatService) MarkGroupRead(groupID, userID string) error {
+	var status string
+	err := s.db.QueryRow(`
+        SELECT status FROM group_members
+        WHERE group_id = ? AND user_id = ? AND status = 'accepted'`,
+		groupID, userID).Scan(&status)
+	if err == sql.ErrNoRows {
+		return ErrNotGroupMember
+	}
+	if err != nil {
+		return err
+	}
+
+	// The marker copies the created_at of the latest message so later
+	// comparisons against group_messages use the same stored value. It never
+	// moves backwards.
+	result, err := s.db.Exec(`
+        INSERT INTO group_read_markers (group_id, user_id, last_read_message_id, last_read_at, updated_at)
+        SELECT group_id, ?, id, created_at, CURRENT_TIMESTAMP
+        FROM group_messages
+        WHERE group_id = ?
+        ORDER BY created_at DESC, id DESC
+        LIMIT 1
+        ON CONFLICT (group_id, user_id) DO UPDATE SET
+            last_read_message_id = excluded.last_read_message_id,
+            last_read_at = excluded.last_read_at,
+            updated_at = excluded.updated_at
+        WHERE excluded.last_read_at > group_read_markers.last_read_at`,
+		userID, groupID,
+	)
+	if err != nil {
+		return err
+	}
+
+	// Group message notifications are covered by the marker now
+	_, err = s.db.Exec(`
+        UPDATE notifications
+        SET is_read = TRUE
+        WHERE user_id = ? AND type = 'group_message' AND is_read = FALSE
+        AND reference_id IN (SELECT id FROM group_messages WHERE group_id = ?)`,
+		userID, groupID,
+	)
+	if err != nil {
+		return err
+	}
+
+	if updated, err := result.RowsAffected(); err != nil || updated == 0 {
+		return nil
+	}
+
+	receipt := GroupReadReceipt{GroupID: groupID, ReaderID: userID, ReadAt: time.Now()}
+	err = s.db.QueryRow(`
+        SELECT last_read_message_id FROM group_read_markers
+        WHERE group_id = ? AND user_id = ?`,
+		groupID, userID).Scan(&receipt.LastReadMessageID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := s.db.Query(`
+        SELECT user_id FROM group_members
+        WHERE group_id = ? AND status = 'accepted' AND user_id != ?`,
+		groupID, userID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	frame := realtime.NewFrame(realtime.TypeGroupRead, receipt)
+	for rows.Next() {
+		var memberID string
+		if err := rows.Scan(&memberID); err != nil {
+			return err
+		}
+		if err := s.hub.Publish(memberID, frame); err != nil {
+			log.Printf("Error sending group read receipt to %s: %v", memberID, err)
+		}
+	}
+	return rows.Err()
 }
 
-func (s *ChatService) RemoveConnection(userID string) {
-	delete(s.connections, userID)
+// Get the number of unread messages in each group chat of a user. Groups
+// without unread messages are left out.
+func (s *ChatService) GetGroupUnreadCounts(userID string) (map[string]int, error) {
+	rows, err := s.db.Query(`
+        SELECT gm.group_id, COUNT(m.id)
+        FROM group_members gm
+        JOIN group_messages m ON m.group_id = gm.group_id AND m.sender_id != gm.user_id
+        LEFT JOIN group_read_markers r ON r.group_id = gm.group_id AND r.user_id = gm.user_id
+        WHERE gm.user_id = ? AND gm.status = 'accepted'
+        AND (r.last_read_at IS NULL OR m.created_at > r.last_read_at)
+        GROUP BY gm.group_id`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var groupID string
+		var count int
+		if err := rows.Scan(&groupID, &count); err != nil {
+			return nil, err
+		}
+		counts[groupID] = count
+	}
+	return counts, rows.Err()
 }
 
 func (s *ChatService) GetUnreadMessageSenders(userID string) ([]string, error) {
@@ -314,3 +681,11 @@ func (s *ChatService) GetUnreadMessageSenders(userID string) ([]string, error) {
 
 	return senderIDs, nil
 }
+
+// messagePreview is the text shown for a message in notifications.
+func messagePreview(msg *Message) string {
+	if msg.Content == "" && msg.Attachment != nil {
+		return "sent " + msg.Attachment.FileName
+	}
+	return msg.Content
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"social-network/internal/realtime"
+	"strings"
+	"testing"
+)
+
+func newTestChatService(t *testing.T) *ChatService {
+	t.Helper()
+	db := newTestDB(t)
+	hub := realtime.NewHub(realtime.DefaultConfig)
+	return NewChatService(db, hub, NewNotificationService(db, hub), DefaultChatConfig)
+}
+
+// groupNotifications returns the content of userID's unread group_message
+// notifications.
+func groupNotifications(t *testing.T, s *ChatService, userID string) []string {
+	t.Helper()
+	rows, err := s.db.Query(`
+        SELECT content FROM notifications
+        WHERE user_id = ? AND type = 'group_message' AND is_read = FALSE`, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var contents []string
+	for rows.Next() {
+		var content string
+		if err := rows.Scan(&content); err != nil {
+			t.Fatal(err)
+		}
+		contents = append(contents, content)
+	}
+	return contents
+}
+
+func TestGroupMessagesShareOneNotificationPerGroup(t *testing.T) {
+	s := newTestChatService(t)
+	for _, id := range []string{"alice", "bob", "carol"} {
+		seedUser(t, s.db, id, true)
+	}
+	seedGroup(t, s.db, "hikers", "alice", "bob", "carol")
+	seedGroup(t, s.db, "readers", "alice", "bob")
+
+	for _, content := range []string{"one", "two", "three"} {
+		if _, err := s.SendGroupMessage("hikers", "alice", content, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := s.SendGroupMessage("readers", "alice", "hello", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	got := groupNotifications(t, s, "bob")
+	if len(got) != 2 {
+		t.Fatalf("bob has %d group notifications, want one per group: %q", len(got), got)
+	}
+	got = groupNotifications(t, s, "carol")
+	if len(got) != 1 || !strings.HasPrefix(got[0], "3 new messages in hikers") {
+		t.Fatalf("carol's notifications = %q, want one counting 3 messages", got)
+	}
+	if got := groupNotifications(t, s, "alice"); len(got) != 0 {
+		t.Fatalf("the sender got notifications: %q", got)
+	}
+
+	// Reading the chat starts the count over
+	if err := s.MarkGroupRead("hikers", "carol"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.SendGroupMessage("hikers", "bob", "four", nil); err != nil {
+		t.Fatal(err)
+	}
+	got = groupNotifications(t, s, "carol")
+	if len(got) != 1 || !strings.HasPrefix(got[0], "New message from bob in hikers") {
+		t.Fatalf("carol's notifications after reading = %q", got)
+	}
+}
+
+func TestPrivateTimelineIsOneOrderedList(t *testing.T) {
+	s := newTestChatService(t)
+	seedUser(t, s.db, "alice", true)
+	seedUser(t, s.db, "bob", true)
+	seedUser(t, s.db, "carol", true)
+	seedMessages(t, s, 5)
+	if _, err := s.SendPrivateMessage("carol", "alice", "elsewhere", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	timeline, err := s.GetPrivateTimeline("alice", "bob", PageRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := messageIDs(timeline.Messages); got != "m1 m2 m3 m4 m5" {
+		t.Fatalf("timeline = %s", got)
+	}
+	for _, msg := range timeline.Messages {
+		want := "received"
+		if msg.SenderID == "alice" {
+			want = "sent"
+		}
+		if msg.Direction != want || msg.Type != "private" {
+			t.Errorf("%s: direction %q, type %q", msg.ID, msg.Direction, msg.Type)
+		}
+	}
+
+	history, err := s.GetPrivateMessageHistory("alice", "bob", PageRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if messageIDs(history.Sent) != "m1 m3 m5" || messageIDs(history.Received) != "m2 m4" {
+		t.Errorf("split history: sent %s, received %s", messageIDs(history.Sent), messageIDs(history.Received))
+	}
+	for _, msg := range append(history.Sent, history.Received...) {
+		if msg.Direction != "" {
+			t.Errorf("%s keeps its direction in the split format", msg.ID)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"social-network/internal/model"
+	"social-network/internal/notification"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrNotCommentAuthor   = errors.New("only the author can edit this comment")
+	ErrCommentEditsDenied = errors.New("only the comment author and post owner can see earlier versions")
+)
+
+// EditComment changes the content of a post comment. Only its author may
+// edit it; the previous content is kept in the comment's edit history.
+func (s *PostService) EditComment(commentID string, userID string, content string) (*model.PostComment, error) {
+	comment, err := s.GetComment(commentID)
+	if err != nil {
+		return nil, err
+	}
+	if comment.Deleted {
+		return nil, ErrCommentNotFound
+	}
+	if comment.UserID != userID {
+		return nil, ErrNotCommentAuthor
+	}
+	// Authors who can no longer see the post can't change what's under it
+	if err := newPostVisibility(userID).check(s.db, comment.PostID); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	err = editComment(s.db, "post", comment.ID, content, userID, now)
+	if err != nil {
+		return nil, err
+	}
+	notifyEditMentions(s.db, s.notificationService, comment.ID, userID, content, "comment",
+		comment.PostID, s.canViewPost(comment.PostID))
+
+	comment.Content = content
+	comment.UpdatedAt = now
+	comment.Edited = true
+	comment.EditedAt = &now
+	return comment, nil
+}
+
+// GetCommentEdits returns the previous versions of a post comment, oldest
+// first, to its author and to the owner of the post.
+func (s *PostService) GetCommentEdits(commentID string, userID string) ([]model.CommentEdit, error) {
+	var authorID, postOwnerID string
+	err := s.db.QueryRow(`
+        SELECT c.user_id, p.user_id
+        FROM post_comments c
+        JOIN posts p ON p.id = c.post_id
+        WHERE c.id = ? AND c.deleted_at IS NULL`,
+		commentID,
+	).Scan(&authorID, &postOwnerID)
+	if err == sql.ErrNoRows {
+		return nil, ErrCommentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if userID != authorID && userID != postOwnerID {
+		return nil, ErrCommentEditsDenied
+	}
+
+	return loadCommentEdits(s.db, commentID)
+}
+
+// EditPostComment changes the content of a group post comment. Only its
+// author may edit it, and only while a member of the group.
+func (s *GroupService) EditPostComment(commentID string, userID string, content string) (*model.GroupPostComment, error) {
+	var comment model.GroupPostComment
+	var groupID string
+	err := s.db.QueryRow(`
+        SELECT c.id, c.post_id, c.user_id, c.parent_comment_id, c.content, c.created_at, gp.group_id
+        FROM group_post_comments c
+        JOIN group_posts gp ON gp.id = c.post_id
+        WHERE c.id = ?`,
+		commentID,
+	).Scan(&comment.ID, &comment.PostID, &comment.UserID, &comment.ParentCommentID,
+		&comment.Content, &comment.CreatedAt, &groupID)
+	if err == sql.ErrNoRows {
+		return nil, ErrCommentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if comment.UserID != userID {
+		return nil, ErrNotCommentAuthor
+	}
+	if err := s.verifyMembership(groupID, userID); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	err = editComment(s.db, "group", comment.ID, content, userID, now)
+	if err != nil {
+		return nil, err
+	}
+	notifyEditMentions(s.db, s.notificationService, comment.ID, userID, content, "group comment",
+		comment.PostID, s.isMember(groupID))
+
+	comment.Content = content
+	comment.UpdatedAt = now
+	comment.Edited = true
+	comment.EditedAt = &now
+	return &comment, nil
+}
+
+// GetPostCommentEdits returns the previous versions of a group post comment,
+// oldest first, to its author and to the author of the post.
+func (s *GroupService) GetPostCommentEdits(commentID string, userID string) ([]model.CommentEdit, error) {
+	var authorID, postOwnerID string
+	err := s.db.QueryRow(`
+        SELECT c.user_id, gp.user_id
+        FROM group_post_comments c
+        JOIN group_posts gp ON gp.id = c.post_id
+        WHERE c.id = ?`,
+		commentID,
+	).Scan(&authorID, &postOwnerID)
+	if err == sql.ErrNoRows {
+		return nil, ErrCommentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if userID != authorID && userID != postOwnerID {
+		return nil, ErrCommentEditsDenied
+	}
+
+	return loadCommentEdits(s.db, commentID)
+}
+
+// editComment records the current content of a comment in comment_edits and
+// replaces it. commentType is "post" or "group". A post comment deleted since
+// the caller loaded it is reported as ErrCommentNotFound.
+func editComment(db *sql.DB, commentType, commentID, content, userID string, now time.Time) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	table, live := "post_comments", " AND deleted_at IS NULL"
+	if commentType == "group" {
+		table, live = "group_post_comments", ""
+	}
+
+	// The previous content is read in the transaction so that concurrent
+	// edits each record the version they replaced
+	result, err := tx.Exec(`
+        INSERT INTO comment_edits (id, comment_id, comment_type, previous_content, edited_by, edited_at)
+        SELECT ?, id, ?, content, ?, ?
+        FROM `+table+`
+        WHERE id = ?`+live,
+		uuid.New().String(), commentType, userID, now, commentID,
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrCommentNotFound
+	}
+
+	result, err = tx.Exec(`
+        UPDATE `+table+`
+        SET content = ?, updated_at = ?, edited_at = ?
+        WHERE id = ?`+live,
+		content, now, now, commentID,
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrCommentNotFound
+	}
+
+	return tx.Commit()
+}
+
+// notifyEditMentions notifies the users mentioned in an edited comment who
+// weren't mentioned in any earlier version of it, so editing a comment
+// doesn't notify anyone twice.
+func notifyEditMentions(db *sql.DB, notificationService notification.Service, commentID, authorID, content, kind, referenceID string, canSee func(userID string) bool) {
+	edits, err := loadCommentEdits(db, commentID)
+	if err != nil {
+		log.Printf("Error loading comment edits for mentions: %v", err)
+		return
+	}
+	notified := map[string]bool{authorID: true}
+	for _, edit := range edits {
+		userIDs, err := mentionedUsers(db, edit.PreviousContent)
+		if err != nil {
+			log.Printf("Error resolving mentions: %v", err)
+			return
+		}
+		for _, userID := range userIDs {
+			notified[userID] = true
+		}
+	}
+	notifyMentions(db, notificationService, authorID, content, kind, referenceID, canSee, notified)
+}
+
+func loadCommentEdits(db *sql.DB, commentID string) ([]model.CommentEdit, error) {
+	rows, err := db.Query(`
+        SELECT id, comment_id, previous_content, edited_by, edited_at
+        FROM comment_edits
+        WHERE comment_id = ?
+        ORDER BY edited_at ASC, rowid ASC`,
+		commentID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	edits := []model.CommentEdit{}
+	for rows.Next() {
+		var edit model.CommentEdit
+		err := rows.Scan(&edit.ID, &edit.CommentID, &edit.PreviousContent, &edit.EditedBy, &edit.EditedAt)
+		if err != nil {
+			return nil, err
+		}
+		edits = append(edits, edit)
+	}
+	return edits, rows.Err()
+}
@@ -0,0 +1,200 @@
+package service
+
+import (
+	"reflect"
+	"social-network/internal/model"
+	"social-network/internal/realtime"
+	"testing"
+	"time"
+)
+
+// newTestPostService returns a post service over a database holding alice's
+// public post "post", with bob and carol as further users.
+func newTestPostService(t *testing.T) *PostService {
+	t.Helper()
+	db := newTestDB(t)
+	for _, id := range []string{"alice", "bob", "carol"} {
+		seedUser(t, db, id, true)
+	}
+	_, err := db.Exec(`INSERT INTO posts (id, user_id, content, privacy) VALUES ('post', 'alice', 'x', 'public')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewPostService(db, NewNotificationService(db, realtime.NewHub(realtime.DefaultConfig)))
+}
+
+func previousVersions(edits []model.CommentEdit) []string {
+	versions := make([]string, len(edits))
+	for i, edit := range edits {
+		versions[i] = edit.PreviousContent
+	}
+	return versions
+}
+
+func TestCommentEditHistory(t *testing.T) {
+	s := newTestPostService(t)
+	comment, err := s.CreateComment("post", "bob", "first", nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.EditComment(comment.ID, "carol", "hijacked"); err != ErrNotCommentAuthor {
+		t.Fatalf("edit by another user: err = %v, want ErrNotCommentAuthor", err)
+	}
+	for _, content := range []string{"second", "third"} {
+		edited, err := s.EditComment(comment.ID, "bob", content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if edited.Content != content || !edited.Edited || edited.EditedAt == nil {
+			t.Fatalf("edited comment = %+v, want %q marked as edited", edited, content)
+		}
+	}
+
+	// The author and the post owner see every earlier version, oldest first
+	for _, userID := range []string{"bob", "alice"} {
+		edits, err := s.GetCommentEdits(comment.ID, userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := previousVersions(edits), []string{"first", "second"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s sees versions %q, want %q", userID, got, want)
+		}
+	}
+	if _, err := s.GetCommentEdits(comment.ID, "carol"); err != ErrCommentEditsDenied {
+		t.Errorf("history for another user: err = %v, want ErrCommentEditsDenied", err)
+	}
+
+	current, err := s.GetComment(comment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current.Content != "third" {
+		t.Errorf("stored content = %q, want third", current.Content)
+	}
+}
+
+func TestDeletedCommentsCannotBeEdited(t *testing.T) {
+	s := newTestPostService(t)
+	comment, err := s.CreateComment("post", "bob", "first", nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The reply keeps the deleted comment as a placeholder
+	if _, err := s.CreateComment("post", "carol", "reply", nil, comment.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteComment(comment.ID, "bob"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.EditComment(comment.ID, "bob", "back"); err != ErrCommentNotFound {
+		t.Errorf("EditComment: err = %v, want ErrCommentNotFound", err)
+	}
+	// A deletion between loading the comment and editing it
+	if err := editComment(s.db, "post", comment.ID, "back", "bob", time.Now()); err != ErrCommentNotFound {
+		t.Errorf("editComment: err = %v, want ErrCommentNotFound", err)
+	}
+
+	var content string
+	var edits int
+	err = s.db.QueryRow(`
+        SELECT content, (SELECT COUNT(*) FROM comment_edits WHERE comment_id = c.id)
+        FROM post_comments c WHERE id = ?`, comment.ID).Scan(&content, &edits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content != "" || edits != 0 {
+		t.Errorf("placeholder has content %q and %d edits, want neither", content, edits)
+	}
+}
+
+func TestGroupCommentEditHistory(t *testing.T) {
+	db := newTestDB(t)
+	for _, id := range []string{"alice", "bob", "carol"} {
+		seedUser(t, db, id, true)
+	}
+	seedGroup(t, db, "hikers", "alice", "bob", "carol")
+	_, err := db.Exec(`INSERT INTO group_posts (id, group_id, user_id, content) VALUES ('post', 'hikers', 'alice', 'x')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewGroupService(db, NewNotificationService(db, realtime.NewHub(realtime.DefaultConfig)))
+
+	comment, err := s.CreatePostComment("post", "bob", "first", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.EditPostComment(comment.ID, "carol", "hijacked"); err != ErrNotCommentAuthor {
+		t.Fatalf("edit by another member: err = %v, want ErrNotCommentAuthor", err)
+	}
+	for _, content := range []string{"second", "third"} {
+		if _, err := s.EditPostComment(comment.ID, "bob", content); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	edits, err := s.GetPostCommentEdits(comment.ID, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := previousVersions(edits), []string{"first", "second"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("versions = %q, want %q", got, want)
+	}
+	if _, err := s.GetPostCommentEdits(comment.ID, "carol"); err != ErrCommentEditsDenied {
+		t.Errorf("history for another member: err = %v, want ErrCommentEditsDenied", err)
+	}
+}
+
+func TestDeletePostRemovesCommentEdits(t *testing.T) {
+	s := newTestPostService(t)
+	comment, err := s.CreateComment("post", "bob", "first", nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.EditComment(comment.ID, "bob", "second"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.DeletePost("post", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	var edits int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM comment_edits`).Scan(&edits); err != nil {
+		t.Fatal(err)
+	}
+	if edits != 0 {
+		t.Errorf("%d comment edits left after deleting the post", edits)
+	}
+}
+
+func TestPreviewReplyCountsSkipDeletedReplies(t *testing.T) {
+	s := newTestPostService(t)
+	comment, err := s.CreateComment("post", "bob", "first", nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	removed, err := s.CreateComment("post", "carol", "reply", nil, comment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CreateComment("post", "bob", "reply to the reply", nil, removed.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CreateComment("post", "alice", "another reply", nil, comment.ID); err != nil {
+		t.Fatal(err)
+	}
+	// carol's reply has a reply of its own, so it stays as a placeholder
+	if err := s.DeleteComment(removed.ID, "carol"); err != nil {
+		t.Fatal(err)
+	}
+
+	posts, err := s.GetPublicPosts("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	preview := posts[0]["comments"].([]map[string]interface{})
+	if len(preview) != 1 || preview[0]["reply_count"] != 1 {
+		t.Errorf("preview = %v, want one comment with a single reply", preview)
+	}
+}
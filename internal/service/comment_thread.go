@@ -0,0 +1,118 @@
+package service
+
+import (
+	"errors"
+	"social-network/internal/model"
+)
+
+// Comment trees are returned DefaultCommentDepth levels deep unless asked
+// otherwise. Comments at the last level still carry their reply count so
+// clients can load the rest of the thread with CommentThreadOptions.ParentID.
+const (
+	DefaultCommentDepth = 3
+	MaxCommentDepth     = 10
+)
+
+// removedCommentContent stands in for a deleted comment that is kept because
+// it still has replies.
+const removedCommentContent = "comment removed"
+
+var (
+	ErrCommentNotFound       = errors.New("comment not found")
+	ErrParentCommentNotFound = errors.New("parent comment not found")
+)
+
+// CommentThreadOptions selects the part of a comment tree to return.
+type CommentThreadOptions struct {
+	// ParentID returns the replies of a comment instead of the top level.
+	ParentID string
+	// Depth is the number of levels to return, counting the first.
+	Depth int
+}
+
+func (o CommentThreadOptions) levels() int {
+	if o.Depth <= 0 {
+		return DefaultCommentDepth
+	}
+	if o.Depth > MaxCommentDepth {
+		return MaxCommentDepth
+	}
+	return o.Depth
+}
+
+// commentTree nests the comments of a post under their parents. Every comment
+// gets its depth (0 for top-level comments), its number of direct replies and,
+// within the requested levels, the replies themselves.
+func commentTree(comments []map[string]interface{}, opts CommentThreadOptions) ([]map[string]interface{}, error) {
+	parents := make(map[string]string, len(comments))
+	children := make(map[string][]map[string]interface{})
+	for _, comment := range comments {
+		parentID, _ := comment["parent_comment_id"].(string)
+		parents[comment["id"].(string)] = parentID
+		children[parentID] = append(children[parentID], comment)
+	}
+
+	depth := 0
+	if opts.ParentID != "" {
+		if _, ok := parents[opts.ParentID]; !ok {
+			return nil, ErrCommentNotFound
+		}
+		for id := opts.ParentID; id != ""; id = parents[id] {
+			depth++
+		}
+	}
+
+	var build func(parentID string, depth, levels int) []map[string]interface{}
+	build = func(parentID string, depth, levels int) []map[string]interface{} {
+		nodes := children[parentID]
+		for _, comment := range nodes {
+			id := comment["id"].(string)
+			comment["depth"] = depth
+			comment["reply_count"] = len(children[id])
+			comment["replies"] = []map[string]interface{}{}
+			if levels > 1 && len(children[id]) > 0 {
+				comment["replies"] = build(id, depth+1, levels-1)
+			}
+		}
+		return nodes
+	}
+
+	tree := build(opts.ParentID, depth, opts.levels())
+	if tree == nil {
+		tree = []map[string]interface{}{}
+	}
+	return tree, nil
+}
+
+// groupCommentTree nests the comments of a group post like commentTree. Group
+// posts are listed with all their threads, so only opts.Depth applies.
+func groupCommentTree(comments []model.GroupPostComment, opts CommentThreadOptions) []model.GroupPostComment {
+	children := make(map[string][]model.GroupPostComment)
+	for _, comment := range comments {
+		parentID := ""
+		if comment.ParentCommentID != nil {
+			parentID = *comment.ParentCommentID
+		}
+		children[parentID] = append(children[parentID], comment)
+	}
+
+	var build func(parentID string, depth, levels int) []model.GroupPostComment
+	build = func(parentID string, depth, levels int) []model.GroupPostComment {
+		nodes := children[parentID]
+		for i := range nodes {
+			nodes[i].Depth = depth
+			nodes[i].ReplyCount = len(children[nodes[i].ID])
+			nodes[i].Replies = []model.GroupPostComment{}
+			if levels > 1 && len(children[nodes[i].ID]) > 0 {
+				nodes[i].Replies = build(nodes[i].ID, depth+1, levels-1)
+			}
+		}
+		return nodes
+	}
+
+	tree := build("", 0, opts.levels())
+	if tree == nil {
+		tree = []model.GroupPostComment{}
+	}
+	return tree
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"social-network/internal/model"
+	"testing"
+)
+
+// commentChain returns a thread of n comments, each replying to the previous
+// one, for both comment representations.
+func commentChain(n int) ([]map[string]interface{}, []model.GroupPostComment) {
+	var comments []map[string]interface{}
+	var groupComments []model.GroupPostComment
+	for i := 0; i < n; i++ {
+		id := fmt.Sprintf("c%d", i)
+		comment := map[string]interface{}{"id": id}
+		groupComment := model.GroupPostComment{ID: id}
+		if i > 0 {
+			parentID := fmt.Sprintf("c%d", i-1)
+			comment["parent_comment_id"] = parentID
+			groupComment.ParentCommentID = &parentID
+		}
+		comments = append(comments, comment)
+		groupComments = append(groupComments, groupComment)
+	}
+	return comments, groupComments
+}
+
+// levelsOf decodes a serialised tree and returns how many levels hold
+// comments and the JSON of the replies below the last one.
+func levelsOf(t *testing.T, tree interface{}) (int, string) {
+	t.Helper()
+	data, err := json.Marshal(tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var nodes []map[string]json.RawMessage
+	if err := json.Unmarshal(data, &nodes); err != nil {
+		t.Fatal(err)
+	}
+	levels := 0
+	for {
+		levels++
+		replies := nodes[0]["replies"]
+		if err := json.Unmarshal(replies, &nodes); err != nil {
+			t.Fatal(err)
+		}
+		if len(nodes) == 0 {
+			return levels, string(replies)
+		}
+	}
+}
+
+func TestCommentTreesHonourDepth(t *testing.T) {
+	for _, depth := range []int{0, 1, 2, 5, MaxCommentDepth + 5} {
+		want := CommentThreadOptions{Depth: depth}.levels()
+		comments, groupComments := commentChain(MaxCommentDepth + 2)
+
+		tree, err := commentTree(comments, CommentThreadOptions{Depth: depth})
+		if err != nil {
+			t.Fatal(err)
+		}
+		levels, replies := levelsOf(t, tree)
+		if levels != want || replies != "[]" {
+			t.Errorf("depth %d: post comments have %d levels ending in %s, want %d ending in []",
+				depth, levels, replies, want)
+		}
+
+		groupTree := groupCommentTree(groupComments, CommentThreadOptions{Depth: depth})
+		levels, replies = levelsOf(t, groupTree)
+		if levels != want || replies != "[]" {
+			t.Errorf("depth %d: group comments have %d levels ending in %s, want %d ending in []",
+				depth, levels, replies, want)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package service
+
+import (
+	"database/sql"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Conversation is an inbox entry: a private chat with another user or a
+// group chat the user belongs to.
+type Conversation struct {
+	Type string `json:"type"` // "private" or "group"
+	// ID is the other user's ID for private chats and the group ID for
+	// group chats.
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Avatar      string   `json:"avatar,omitempty"`
+	LastMessage *Message `json:"last_message,omitempty"`
+	// Preview is the text shown for the last message, with a placeholder
+	// for deleted and attachment-only messages.
+	Preview        string    `json:"preview,omitempty"`
+	LastActivityAt time.Time `json:"last_activity_at"`
+	UnreadCount    int       `json:"unread_count"`
+	Muted          bool      `json:"muted"`
+}
+
+// Get every conversation of a user, most recently active first
+func (s *ChatService) GetConversations(userID string) ([]Conversation, error) {
+	conversations, err := s.privateConversations(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	groups, err := s.groupConversations(userID)
+	if err != nil {
+		return nil, err
+	}
+	conversations = append(conversations, groups...)
+
+	if err := s.markMuted(userID, conversations); err != nil {
+		return nil, err
+	}
+	if err := s.loadLastAttachments(conversations); err != nil {
+		return nil, err
+	}
+	for i := range conversations {
+		conversations[i].Preview = conversationPreview(conversations[i].LastMessage)
+	}
+
+	sort.SliceStable(conversations, func(i, j int) bool {
+		return conversations[i].LastActivityAt.After(conversations[j].LastActivityAt)
+	})
+	return conversations, nil
+}
+
+func (s *ChatService) privateConversations(userID string) ([]Conversation, error) {
+	rows, err := s.db.Query(`
+        WITH ranked AS (
+            SELECT id, sender_id, recipient_id, content, created_at, is_read, read_at, edited_at, deleted_at,
+                CASE WHEN sender_id = ? THEN recipient_id ELSE sender_id END AS peer_id,
+                ROW_NUMBER() OVER (
+                    PARTITION BY CASE WHEN sender_id = ? THEN recipient_id ELSE sender_id END
+                    ORDER BY created_at DESC, id DESC
+                ) AS position
+            FROM messages
+            WHERE sender_id = ? OR recipient_id = ?
+        )
+        SELECT r.id, r.sender_id, r.recipient_id, r.content, r.created_at, r.is_read, r.read_at,
+            r.edited_at, r.deleted_at, u.id, u.first_name, u.last_name, u.nickname, u.avatar,
+            (SELECT COUNT(*) FROM messages
+             WHERE sender_id = r.peer_id AND recipient_id = ? AND is_read = FALSE)
+        FROM ranked r
+        JOIN users u ON u.id = r.peer_id
+        WHERE r.position = 1`,
+		userID, userID, userID, userID, userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	conversations := []Conversation{}
+	for rows.Next() {
+		var msg Message
+		var readAt, editedAt, deletedAt sql.NullTime
+		var firstName, lastName string
+		var nickname, avatar sql.NullString
+		conversation := Conversation{Type: "private"}
+
+		err := rows.Scan(
+			&msg.ID, &msg.SenderID, &msg.RecipientID, &msg.Content, &msg.CreatedAt, &msg.IsRead, &readAt,
+			&editedAt, &deletedAt, &conversation.ID, &firstName, &lastName, &nickname, &avatar,
+			&conversation.UnreadCount,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		msg.Type = "private"
+		msg.ReadAt = nullTime(readAt)
+		msg.EditedAt = nullTime(editedAt)
+		msg.DeletedAt = nullTime(deletedAt)
+		msg.Direction = "received"
+		if msg.SenderID == userID {
+			msg.Direction = "sent"
+		}
+
+		conversation.Name = displayName(firstName, lastName, nickname.String)
+		conversation.Avatar = avatar.String
+		conversation.LastMessage = &msg
+		conversation.LastActivityAt = msg.CreatedAt
+		conversations = append(conversations, conversation)
+	}
+	return conversations, rows.Err()
+}
+
+func (s *ChatService) groupConversations(userID string) ([]Conversation, error) {
+	rows, err := s.db.Query(`
+        WITH ranked AS (
+            SELECT id, group_id, sender_id, content, created_at, edited_at, deleted_at,
+                ROW_NUMBER() OVER (PARTITION BY group_id ORDER BY created_at DESC, id DESC) AS position
+            FROM group_messages
+        )
+        SELECT g.id, g.title, gm.created_at,
+            r.id, r.sender_id, r.content, r.created_at, r.edited_at, r.deleted_at,
+            (SELECT COUNT(*) FROM group_messages m
+             WHERE m.group_id = g.id AND m.sender_id != gm.user_id
+             AND (rm.last_read_at IS NULL OR m.created_at > rm.last_read_at))
+        FROM group_members gm
+        JOIN groups g ON g.id = gm.group_id
+        LEFT JOIN ranked r ON r.group_id = g.id AND r.position = 1
+        LEFT JOIN group_read_markers rm ON rm.group_id = gm.group_id AND rm.user_id = gm.user_id
+        WHERE gm.user_id = ? AND gm.status = 'accepted'`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	conversations := []Conversation{}
+	for rows.Next() {
+		var joinedAt time.Time
+		var messageID, senderID, content sql.NullString
+		var sentAt, editedAt, deletedAt sql.NullTime
+		conversation := Conversation{Type: "group"}
+
+		err := rows.Scan(
+			&conversation.ID, &conversation.Name, &joinedAt,
+			&messageID, &senderID, &content, &sentAt, &editedAt, &deletedAt,
+			&conversation.UnreadCount,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		conversation.LastActivityAt = joinedAt
+		if messageID.Valid {
+			conversation.LastMessage = &Message{
+				ID:        messageID.String,
+				SenderID:  senderID.String,
+				GroupID:   conversation.ID,
+				Content:   content.String,
+				CreatedAt: sentAt.Time,
+				EditedAt:  nullTime(editedAt),
+				DeletedAt: nullTime(deletedAt),
+				Type:      "group",
+			}
+			conversation.LastActivityAt = sentAt.Time
+		}
+		conversations = append(conversations, conversation)
+	}
+	return conversations, rows.Err()
+}
+
+func (s *ChatService) markMuted(userID string, conversations []Conversation) error {
+	rows, err := s.db.Query(`
+        SELECT conversation_type, conversation_id FROM muted_conversations
+        WHERE user_id = ?`,
+		userID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	muted := make(map[string]bool)
+	for rows.Next() {
+		var conversationType, conversationID string
+		if err := rows.Scan(&conversationType, &conversationID); err != nil {
+			return err
+		}
+		muted[conversationType+":"+conversationID] = true
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for i := range conversations {
+		conversations[i].Muted = muted[conversations[i].Type+":"+conversations[i].ID]
+	}
+	return nil
+}
+
+// loadLastAttachments fills in the attachments of the last messages of
+// conversations with a single query.
+func (s *ChatService) loadLastAttachments(conversations []Conversation) error {
+	var messages []Message
+	for _, conversation := range conversations {
+		if conversation.LastMessage != nil {
+			messages = append(messages, *conversation.LastMessage)
+		}
+	}
+	if err := s.loadAttachments(messages); err != nil {
+		return err
+	}
+
+	i := 0
+	for _, conversation := range conversations {
+		if conversation.LastMessage != nil {
+			conversation.LastMessage.Attachment = messages[i].Attachment
+			i++
+		}
+	}
+	return nil
+}
+
+// conversationPreview is the text shown for the last message of a
+// conversation in the inbox.
+func conversationPreview(msg *Message) string {
+	switch {
+	case msg == nil:
+		return ""
+	case msg.DeletedAt != nil:
+		return "Message deleted"
+	case msg.Content == "" && msg.Attachment != nil:
+		if IsImage(msg.Attachment.MimeType) {
+			return "Photo"
+		}
+		return "Attachment: " + msg.Attachment.FileName
+	}
+	return msg.Content
+}
+
+func displayName(firstName, lastName, nickname string) string {
+	if nickname != "" {
+		return nickname
+	}
+	return strings.TrimSpace(firstName + " " + lastName)
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGetConversations(t *testing.T) {
+	s := newTestChatService(t)
+	for _, id := range []string{"alice", "bob", "carol"} {
+		seedUser(t, s.db, id, true)
+	}
+	seedMessages(t, s, 5)
+	seedGroup(t, s.db, "hikers", "bob", "alice")
+	seedGroup(t, s.db, "readers", "alice")
+
+	for _, content := range []string{"one", "two"} {
+		if _, err := s.SendGroupMessage("hikers", "bob", content, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.MarkGroupRead("hikers", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond)
+	if _, err := s.SendGroupMessage("hikers", "bob", "three", nil); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond)
+	if _, err := s.SendPrivateMessage("carol", "alice", "hey", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	conversations, err := s.GetConversations("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	byID := make(map[string]Conversation)
+	var order []string
+	for _, c := range conversations {
+		byID[c.ID] = c
+		if c.LastMessage != nil {
+			order = append(order, c.ID)
+		}
+	}
+	if len(conversations) != 4 {
+		t.Fatalf("got %d conversations, want 4", len(conversations))
+	}
+	if got := order; len(got) != 3 || got[0] != "carol" || got[1] != "hikers" || got[2] != "bob" {
+		t.Errorf("conversations with messages in order %v, want most recent first", got)
+	}
+
+	bob := byID["bob"]
+	if bob.Type != "private" || bob.Name != "bob" || bob.LastMessage.ID != "m5" ||
+		bob.LastMessage.Direction != "sent" || bob.UnreadCount != 2 {
+		t.Errorf("bob conversation = %+v, last message %+v", bob, bob.LastMessage)
+	}
+	if carol := byID["carol"]; carol.UnreadCount != 1 || carol.LastMessage.Direction != "received" {
+		t.Errorf("carol conversation = %+v", carol)
+	}
+	hikers := byID["hikers"]
+	if hikers.Type != "group" || hikers.Name != "hikers" || hikers.LastMessage.Content != "three" ||
+		hikers.UnreadCount != 1 {
+		t.Errorf("hikers conversation = %+v, last message %+v", hikers, hikers.LastMessage)
+	}
+	if readers := byID["readers"]; readers.LastMessage != nil || readers.UnreadCount != 0 {
+		t.Errorf("readers conversation = %+v", readers)
+	}
+
+	// Reading the private chat clears its unread count
+	if err := s.MarkMessagesAsRead("bob", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	conversations, err = s.GetConversations("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range conversations {
+		if c.ID == "bob" && c.UnreadCount != 0 {
+			t.Errorf("bob conversation has %d unread after reading", c.UnreadCount)
+		}
+	}
+}
+
+func TestConversationPreviewPlaceholders(t *testing.T) {
+	s := newTestChatService(t)
+	for _, id := range []string{"alice", "bob", "carol", "dave"} {
+		seedUser(t, s.db, id, true)
+	}
+	seedGroup(t, s.db, "hikers", "alice", "bob")
+
+	if _, err := s.SendPrivateMessage("bob", "alice", "hello", nil); err != nil {
+		t.Fatal(err)
+	}
+	photo := &Attachment{Path: AttachmentPathPrefix + "photo.png", FileName: "photo.png", MimeType: "image/png", Size: 10}
+	if _, err := s.SendPrivateMessage("carol", "alice", "", photo); err != nil {
+		t.Fatal(err)
+	}
+	notes := &Attachment{Path: AttachmentPathPrefix + "notes.pdf", FileName: "notes.pdf", MimeType: "application/pdf", Size: 10}
+	if _, err := s.SendGroupMessage("hikers", "bob", "", notes); err != nil {
+		t.Fatal(err)
+	}
+	deleted, err := s.SendPrivateMessage("dave", "alice", "oops", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.DeleteMessage(deleted.ID, "dave"); err != nil {
+		t.Fatal(err)
+	}
+
+	conversations, err := s.GetConversations("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	previews := make(map[string]string)
+	for _, c := range conversations {
+		previews[c.ID] = c.Preview
+	}
+	want := map[string]string{
+		"bob":    "hello",
+		"carol":  "Photo",
+		"hikers": "Attachment: notes.pdf",
+		"dave":   "Message deleted",
+	}
+	if !reflect.DeepEqual(previews, want) {
+		t.Errorf("previews = %v, want %v", previews, want)
+	}
+	for _, c := range conversations {
+		if c.ID == "hikers" && (c.LastMessage.Attachment == nil || c.LastMessage.Attachment.FileName != "notes.pdf") {
+			t.Errorf("hikers last message attachment = %+v", c.LastMessage.Attachment)
+		}
+	}
+}
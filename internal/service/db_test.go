@@ -0,0 +1,74 @@
+package service
+
+import (
+	"database/sql"
+	"fmt"
+	"social-network/pkg/db/sqlite"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+var testDBCount atomic.Int64
+
+// newTestDB returns an in-memory database with every migration applied. It
+// uses a named shared cache so all pooled connections see the same database.
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+	dsn := fmt.Sprintf("file:%s_%d?mode=memory&cache=shared&_busy_timeout=5000",
+		name, testDBCount.Add(1))
+	db, err := sqlite.New(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.RunMigrations("../../pkg/db/migrations/sqlite"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db.DB
+}
+
+// seedUser inserts a user with a nickname equal to its ID.
+func seedUser(t *testing.T, db *sql.DB, id string, public bool) {
+	t.Helper()
+	_, err := db.Exec(`
+        INSERT INTO users (id, email, password, first_name, last_name, date_of_birth, nickname, is_public)
+        VALUES (?, ?, 'x', ?, 'Test', '2000-01-01', ?, ?)`,
+		id, id+"@example.com", id, id, public)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// seedFollow records an accepted follow of followingID by followerID.
+func seedFollow(t *testing.T, db *sql.DB, followerID, followingID string) {
+	t.Helper()
+	_, err := db.Exec(`
+        INSERT INTO follow_requests (id, follower_id, following_id, status)
+        VALUES (?, ?, ?, 'accepted')`,
+		followerID+"->"+followingID, followerID, followingID)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// seedGroup creates a group owned by the first member, with every member
+// accepted.
+func seedGroup(t *testing.T, db *sql.DB, id string, memberIDs ...string) {
+	t.Helper()
+	_, err := db.Exec(`INSERT INTO groups (id, creator_id, title) VALUES (?, ?, ?)`,
+		id, memberIDs[0], id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, memberID := range memberIDs {
+		_, err := db.Exec(`
+            INSERT INTO group_members (group_id, user_id, status) VALUES (?, ?, 'accepted')`,
+			id, memberID)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"database/sql"
+	"social-network/internal/model"
+)
+
+// FeedOptions narrows the home feed. With FollowingOnly set the feed only
+// holds posts by people the user follows, without their own or group posts.
+type FeedOptions struct {
+	FollowingOnly bool
+}
+
+// FeedPage is one page of the home feed, newest first. Pages are keyed on
+// (created_at, id), so posts created while paging don't shift later pages.
+type FeedPage struct {
+	Items      []model.FeedItem `json:"items"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+}
+
+// GetFeed returns the user's home feed: their own posts, the posts they may
+// see from people they follow and the posts of the groups they belong to.
+func (s *PostService) GetFeed(userID string, opts FeedOptions, page PageRequest) (*FeedPage, error) {
+	visible, visibleArgs := newPostVisibility(userID).condition("f")
+	following := `EXISTS (
+                SELECT 1 FROM follow_requests fr
+                WHERE fr.follower_id = ? AND fr.following_id = f.user_id AND fr.status = 'accepted'
+            )`
+
+	query := `
+        SELECT f.* FROM feed_items f
+        WHERE `
+	var args []interface{}
+	if opts.FollowingOnly {
+		query += `f.type = 'post' AND ` + following + ` AND ` + visible
+		args = append(args, userID)
+		args = append(args, visibleArgs...)
+	} else {
+		query += `(
+            (f.type = 'post' AND (f.user_id = ? OR ` + following + `) AND ` + visible + `)
+            OR (f.type = 'group_post' AND f.group_id IN (
+                SELECT group_id FROM group_members WHERE user_id = ? AND status = 'accepted'
+            ))
+        )`
+		args = append(args, userID, userID)
+		args = append(args, visibleArgs...)
+		args = append(args, userID)
+	}
+
+	query, args, descending, err := page.cursorQuery(query, args)
+	if err != nil {
+		return nil, err
+	}
+
+	// Authors and groups are joined onto the page rather than into it, so
+	// the cursor condition only sees feed_items columns
+	order := "DESC"
+	if !descending {
+		order = "ASC"
+	}
+	query = `
+        SELECT f.type, f.id, f.user_id, f.group_id, g.title, f.content, f.image_path, f.privacy,
+               f.created_at, f.updated_at,
+               COALESCE(u.first_name, ''), COALESCE(u.last_name, ''), COALESCE(u.avatar, ''),
+               CASE f.type
+                   WHEN 'post' THEN (SELECT COUNT(*) FROM post_comments c WHERE c.post_id = f.id AND c.deleted_at IS NULL)
+                   ELSE (SELECT COUNT(*) FROM group_post_comments c WHERE c.post_id = f.id)
+               END
+        FROM (` + query + `) f
+        LEFT JOIN users u ON u.id = f.user_id
+        LEFT JOIN groups g ON g.id = f.group_id
+        ORDER BY f.created_at ` + order + `, f.id ` + order
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []model.FeedItem{}
+	for rows.Next() {
+		var item model.FeedItem
+		var groupID, groupTitle, privacy sql.NullString
+		err := rows.Scan(
+			&item.Type, &item.ID, &item.UserID, &groupID, &groupTitle, &item.Content,
+			&item.ImagePath, &privacy, &item.CreatedAt, &item.UpdatedAt,
+			&item.FirstName, &item.LastName, &item.Avatar, &item.CommentCount,
+		)
+		if err != nil {
+			return nil, err
+		}
+		item.GroupID = nullString(groupID)
+		item.GroupTitle = nullString(groupTitle)
+		item.Privacy = nullString(privacy)
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	result := &FeedPage{}
+	result.Items, result.NextCursor = paginate(page, items, descending, newestFirst, feedItemKey)
+
+	if err := s.attachFeedReactions(result.Items, userID); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *PostService) attachFeedReactions(items []model.FeedItem, viewerID string) error {
+	idsByType := make(map[string][]string)
+	for _, item := range items {
+		idsByType[item.Type] = append(idsByType[item.Type], item.ID)
+	}
+
+	for targetType, ids := range idsByType {
+		summaries, err := loadReactionSummaries(s.db, targetType, ids, viewerID)
+		if err != nil {
+			return err
+		}
+		for i := range items {
+			if items[i].Type == targetType {
+				items[i].Reactions = summaries[items[i].ID]
+			}
+		}
+	}
+	return nil
+}
+
+func nullString(s sql.NullString) *string {
+	if !s.Valid {
+		return nil
+	}
+	return &s.String
+}
@@ -0,0 +1,157 @@
+package service
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// seedFeed sets up alice's feed: she follows bob and the private carol but
+// not dave, and belongs to hikers but not cyclists. The items are created a
+// minute apart in the order listed.
+func seedFeed(t *testing.T) *sql.DB {
+	t.Helper()
+	db := newTestDB(t)
+	seedUser(t, db, "alice", true)
+	seedUser(t, db, "bob", true)
+	seedUser(t, db, "carol", false)
+	seedUser(t, db, "dave", true)
+	seedUser(t, db, "eve", true)
+	seedFollow(t, db, "alice", "bob")
+	seedFollow(t, db, "alice", "carol")
+	seedGroup(t, db, "hikers", "eve", "alice")
+	seedGroup(t, db, "cyclists", "eve")
+
+	items := []struct {
+		id, userID, privacy, groupID string
+	}{
+		{"own-private", "alice", "private", ""},
+		{"bob-public", "bob", "public", ""},
+		{"hikers-1", "eve", "", "hikers"},
+		{"carol-private", "carol", "private", ""},
+		{"dave-public", "dave", "public", ""},
+		{"cyclists-1", "eve", "", "cyclists"},
+		{"bob-almost-others", "bob", "almost_private", ""},
+		{"bob-almost-alice", "bob", "almost_private", ""},
+		{"hikers-2", "eve", "", "hikers"},
+		{"carol-public", "carol", "public", ""},
+	}
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i, item := range items {
+		created := start.Add(time.Duration(i) * time.Minute)
+		var err error
+		if item.groupID != "" {
+			_, err = db.Exec(`
+                INSERT INTO group_posts (id, group_id, user_id, content, created_at, updated_at)
+                VALUES (?, ?, ?, 'x', ?, ?)`,
+				item.id, item.groupID, item.userID, created, created)
+		} else {
+			_, err = db.Exec(`
+                INSERT INTO posts (id, user_id, content, privacy, created_at, updated_at)
+                VALUES (?, ?, 'x', ?, ?, ?)`,
+				item.id, item.userID, item.privacy, created, created)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err := db.Exec(`
+        INSERT INTO post_viewers (post_id, user_id)
+        VALUES ('bob-almost-alice', 'alice'), ('bob-almost-others', 'dave')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// feedPages walks alice's feed from page on, returning the item IDs of each
+// page.
+func feedPages(t *testing.T, s *PostService, opts FeedOptions, page PageRequest) [][]string {
+	t.Helper()
+	var pages [][]string
+	for {
+		result, err := s.GetFeed("alice", opts, page)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, item := range result.Items {
+			ids = append(ids, item.ID)
+		}
+		pages = append(pages, ids)
+		if result.NextCursor == "" {
+			return pages
+		}
+		page.Before = result.NextCursor
+	}
+}
+
+func TestFeedMixesPostsAndGroupPostsNewestFirst(t *testing.T) {
+	s := NewPostService(seedFeed(t), nil)
+
+	pages := feedPages(t, s, FeedOptions{}, PageRequest{Limit: 3})
+	want := [][]string{
+		{"carol-public", "hikers-2", "bob-almost-alice"},
+		{"carol-private", "hikers-1", "bob-public"},
+		{"own-private"},
+	}
+	if !reflect.DeepEqual(pages, want) {
+		t.Errorf("pages = %v, want %v", pages, want)
+	}
+
+	result, err := s.GetFeed("alice", FeedOptions{}, PageRequest{Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	group := result.Items[1]
+	if group.Type != "group_post" || group.GroupTitle == nil || *group.GroupTitle != "hikers" || group.Privacy != nil {
+		t.Errorf("group item = %+v, want a hikers group post without privacy", group)
+	}
+	if post := result.Items[0]; post.Type != "post" || post.Privacy == nil || *post.Privacy != "public" {
+		t.Errorf("post item = %+v, want a public post", post)
+	}
+}
+
+func TestFeedFollowingOnly(t *testing.T) {
+	s := NewPostService(seedFeed(t), nil)
+
+	pages := feedPages(t, s, FeedOptions{FollowingOnly: true}, PageRequest{})
+	want := [][]string{{"carol-public", "bob-almost-alice", "carol-private", "bob-public"}}
+	if !reflect.DeepEqual(pages, want) {
+		t.Errorf("pages = %v, want %v", pages, want)
+	}
+}
+
+// TestFeedPagesPastDeletedItems deletes the post or group post a cursor
+// was taken from before the next page is loaded.
+func TestFeedPagesPastDeletedItems(t *testing.T) {
+	db := seedFeed(t)
+	s := NewPostService(db, nil)
+
+	first, err := s.GetFeed("alice", FeedOptions{}, PageRequest{Limit: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeletePost("bob-almost-alice", "bob"); err != nil {
+		t.Fatal(err)
+	}
+	pages := feedPages(t, s, FeedOptions{}, PageRequest{Before: first.NextCursor, Limit: 2})
+	want := [][]string{{"carol-private", "hikers-1"}, {"bob-public", "own-private"}}
+	if !reflect.DeepEqual(pages, want) {
+		t.Fatalf("pages after a deleted post = %v, want %v", pages, want)
+	}
+
+	second, err := s.GetFeed("alice", FeedOptions{}, PageRequest{Before: first.NextCursor, Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`DELETE FROM group_posts WHERE id = 'hikers-1'`); err != nil {
+		t.Fatal(err)
+	}
+	pages = feedPages(t, s, FeedOptions{}, PageRequest{Before: second.NextCursor, Limit: 2})
+	want = [][]string{{"bob-public", "own-private"}}
+	if !reflect.DeepEqual(pages, want) {
+		t.Errorf("pages after a deleted group post = %v, want %v", pages, want)
+	}
+}
@@ -73,8 +73,15 @@ func (s *FollowerService) SendFollowRequest(followerID, followingID string) (*mo
 		return nil, err
 	}
 
-	// Create notification for private profile follow requests
-	if !isPublic {
+	// Public profiles are followed right away, private ones get a request
+	if isPublic {
+		s.notificationService.CreateNotification(
+			followingID,
+			"new_follower",
+			fmt.Sprintf("%s started following you", userFullName(s.db, followerID)),
+			followerID,
+		)
+	} else {
 		var firstName, lastName string
 		err = s.db.QueryRow("SELECT first_name, last_name FROM users WHERE id = ?", followerID).
 			Scan(&firstName, &lastName)
@@ -92,9 +99,9 @@ func (s *FollowerService) SendFollowRequest(followerID, followingID string) (*mo
 }
 
 func (s *FollowerService) RespondToRequest(requestID string, userID string, accept bool) error {
-	var followingID string
-	err := s.db.QueryRow("SELECT following_id FROM follow_requests WHERE id = ?", requestID).
-		Scan(&followingID)
+	var followerID, followingID string
+	err := s.db.QueryRow("SELECT follower_id, following_id FROM follow_requests WHERE id = ?", requestID).
+		Scan(&followerID, &followingID)
 	if err != nil {
 		return err
 	}
@@ -113,11 +120,32 @@ func (s *FollowerService) RespondToRequest(requestID string, userID string, acce
         SET status = ?, updated_at = ? 
         WHERE id = ?`,
 		status, time.Now(), requestID)
+	if err != nil {
+		return err
+	}
 
-	return err
+	if accept {
+		s.notificationService.CreateNotification(
+			followerID,
+			"follow_accepted",
+			fmt.Sprintf("%s accepted your follow request", userFullName(s.db, userID)),
+			userID,
+		)
+	}
+	return nil
 }
 
-func (s *FollowerService) GetFollowers(userID string) ([]map[string]string, error) {
+// GetFollowers lists the followers of userID. The followers of a private
+// profile are only listed to the owner and their followers.
+func (s *FollowerService) GetFollowers(userID string, viewerID string) ([]map[string]string, error) {
+	canView, err := canViewProfile(s.db, userID, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	if !canView {
+		return nil, ErrPrivateProfile
+	}
+
 	rows, err := s.db.Query(`
         SELECT u.id, 
                COALESCE(u.nickname, '') AS nickname, 
@@ -150,10 +178,20 @@ func (s *FollowerService) GetFollowers(userID string) ([]map[string]string, erro
 	return followers, nil
 }
 
-func (s *FollowerService) GetFollowing(userID string) ([]map[string]string, error) {
+// GetFollowing lists the users userID follows, with the same restriction as
+// GetFollowers.
+func (s *FollowerService) GetFollowing(userID string, viewerID string) ([]map[string]string, error) {
+	canView, err := canViewProfile(s.db, userID, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	if !canView {
+		return nil, ErrPrivateProfile
+	}
+
 	rows, err := s.db.Query(`
         SELECT u.id, 
-               u.nickname, 
+               COALESCE(u.nickname, '') AS nickname, 
                u.first_name, 
                u.last_name,
                COALESCE(u.avatar, '') as avatar
@@ -229,9 +229,11 @@ func (s *GroupService) CreateGroupPost(groupID string, userID string, content st
 	if err != nil {
 		return nil, err
 	}
+	notifyMentions(s.db, s.notificationService, userID, content, "group post", post.ID,
+		s.isMember(groupID), map[string]bool{})
 	return post, nil
 }
-func (s *GroupService) GetGroupPosts(groupID string, userID string) ([]model.GroupPost, error) {
+func (s *GroupService) GetGroupPosts(groupID string, userID string, opts CommentThreadOptions) ([]model.GroupPost, error) {
 	if err := s.verifyMembership(groupID, userID); err != nil {
 		return nil, err
 	}
@@ -259,6 +261,12 @@ func (s *GroupService) GetGroupPosts(groupID string, userID string) ([]model.Gro
 		post.Comments = comments
 		posts = append(posts, post)
 	}
+	if err := s.loadGroupPostReactions(posts, userID); err != nil {
+		return nil, err
+	}
+	for i := range posts {
+		posts[i].Comments = groupCommentTree(posts[i].Comments, opts)
+	}
 	return posts, nil
 }
 func (s *GroupService) GetGroupJoinRequests(groupID string, userID string) ([]struct {
@@ -455,13 +463,13 @@ func (s *GroupService) GetGroupMembers(groupID string, userID string) ([]struct
 	UserID    string `json:"user_id"`
 	FirstName string `json:"first_name"`
 	LastName  string `json:"last_name"`
-	Email     string `json:"email"`
+	Nickname  string `json:"nickname"`
 }, error) {
 	if err := s.verifyMembership(groupID, userID); err != nil {
 		return nil, err
 	}
 	rows, err := s.db.Query(`
-        SELECT u.id, u.first_name, u.last_name, u.email
+        SELECT u.id, u.first_name, u.last_name, COALESCE(u.nickname, '')
         FROM users u
         JOIN group_members gm ON u.id = gm.user_id
         WHERE gm.group_id = ? AND gm.status = 'accepted'
@@ -475,16 +483,16 @@ func (s *GroupService) GetGroupMembers(groupID string, userID string) ([]struct
 		UserID    string `json:"user_id"`
 		FirstName string `json:"first_name"`
 		LastName  string `json:"last_name"`
-		Email     string `json:"email"`
+		Nickname  string `json:"nickname"`
 	}
 	for rows.Next() {
 		var member struct {
 			UserID    string `json:"user_id"`
 			FirstName string `json:"first_name"`
 			LastName  string `json:"last_name"`
-			Email     string `json:"email"`
+			Nickname  string `json:"nickname"`
 		}
-		if err := rows.Scan(&member.UserID, &member.FirstName, &member.LastName, &member.Email); err != nil {
+		if err := rows.Scan(&member.UserID, &member.FirstName, &member.LastName, &member.Nickname); err != nil {
 			return nil, err
 		}
 		members = append(members, member)
@@ -502,6 +510,13 @@ func (s *GroupService) verifyMembership(groupID string, userID string) error {
 	}
 	return nil
 }
+
+// isMember reports whether a user belongs to a group, for mentions.
+func (s *GroupService) isMember(groupID string) func(userID string) bool {
+	return func(userID string) bool {
+		return s.verifyMembership(groupID, userID) == nil
+	}
+}
 func (s *GroupService) GetEventResponses(eventID string, userID string) (map[string][]string, error) {
 	var groupID string
 	err := s.db.QueryRow(`
@@ -558,7 +573,7 @@ func (s *GroupService) getEventResponses(eventID string) (map[string]string, err
 }
 func (s *GroupService) getPostComments(postID string) ([]model.GroupPostComment, error) {
 	rows, err := s.db.Query(`
-        SELECT id, post_id, user_id, content, created_at, updated_at
+        SELECT id, post_id, user_id, parent_comment_id, content, created_at, updated_at, edited_at
         FROM group_post_comments
         WHERE post_id = ?
         ORDER BY created_at ASC`, postID)
@@ -569,11 +584,14 @@ func (s *GroupService) getPostComments(postID string) ([]model.GroupPostComment,
 	var comments []model.GroupPostComment
 	for rows.Next() {
 		var comment model.GroupPostComment
+		var editedAt sql.NullTime
 		if err := rows.Scan(
-			&comment.ID, &comment.PostID, &comment.UserID,
-			&comment.Content, &comment.CreatedAt, &comment.UpdatedAt); err != nil {
+			&comment.ID, &comment.PostID, &comment.UserID, &comment.ParentCommentID,
+			&comment.Content, &comment.CreatedAt, &comment.UpdatedAt, &editedAt); err != nil {
 			return nil, err
 		}
+		comment.EditedAt = nullTime(editedAt)
+		comment.Edited = editedAt.Valid
 		comments = append(comments, comment)
 	}
 	return comments, nil
@@ -604,7 +622,20 @@ func (s *GroupService) RespondToJoinRequest(groupID string, userID string, respo
         SET status = ?, updated_at = ? 
         WHERE group_id = ? AND user_id = ?`,
 		newStatus, time.Now(), groupID, userID)
-	return err
+	if err != nil {
+		return err
+	}
+	if accept {
+		var groupTitle string
+		s.db.QueryRow("SELECT title FROM groups WHERE id = ?", groupID).Scan(&groupTitle)
+		s.notificationService.CreateNotification(
+			userID,
+			"group_request_accepted",
+			fmt.Sprintf("Your request to join %s was accepted", groupTitle),
+			groupID,
+		)
+	}
+	return nil
 }
 
 func (s *GroupService) GetUserGroups(userID string) (struct {
@@ -672,12 +703,14 @@ func (s *GroupService) GetUserGroups(userID string) (struct {
 	return result, nil
 }
 
-func (s *GroupService) CreatePostComment(postID string, userID string, content string) (*model.GroupPostComment, error) {
+// CreatePostComment comments on a group post, or replies to one of its
+// comments when parentCommentID is set.
+func (s *GroupService) CreatePostComment(postID string, userID string, content string, parentCommentID string) (*model.GroupPostComment, error) {
 	// First verify the post exists and user has access
-	var groupID string
+	var groupID, postAuthorID string
 	err := s.db.QueryRow(`
-        SELECT group_id FROM group_posts 
-        WHERE id = ?`, postID).Scan(&groupID)
+        SELECT group_id, user_id FROM group_posts 
+        WHERE id = ?`, postID).Scan(&groupID, &postAuthorID)
 	if err != nil {
 		return nil, err
 	}
@@ -695,14 +728,81 @@ func (s *GroupService) CreatePostComment(postID string, userID string, content s
 		UpdatedAt: time.Now(),
 	}
 
+	var parentAuthorID string
+	if parentCommentID != "" {
+		var parentPostID string
+		err := s.db.QueryRow(`
+            SELECT post_id, user_id FROM group_post_comments WHERE id = ?`,
+			parentCommentID).Scan(&parentPostID, &parentAuthorID)
+		if err == sql.ErrNoRows || (err == nil && parentPostID != postID) {
+			return nil, ErrParentCommentNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		comment.ParentCommentID = &parentCommentID
+	}
+
 	_, err = s.db.Exec(`
-        INSERT INTO group_post_comments (id, post_id, user_id, content, created_at, updated_at)
-        VALUES (?, ?, ?, ?, ?, ?)`,
-		comment.ID, comment.PostID, comment.UserID, comment.Content,
+        INSERT INTO group_post_comments (id, post_id, user_id, parent_comment_id, content, created_at, updated_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		comment.ID, comment.PostID, comment.UserID, comment.ParentCommentID, comment.Content,
 		comment.CreatedAt, comment.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
 
+	// Each user hears about a comment once, the most specific way
+	notified := map[string]bool{userID: true}
+	if parentAuthorID != "" && !notified[parentAuthorID] {
+		s.notificationService.CreateNotification(
+			parentAuthorID,
+			"comment_reply",
+			fmt.Sprintf("%s replied to your comment", userFullName(s.db, userID)),
+			postID,
+		)
+		notified[parentAuthorID] = true
+	}
+	if !notified[postAuthorID] {
+		s.notificationService.CreateNotification(
+			postAuthorID,
+			"post_comment",
+			fmt.Sprintf("%s commented on your group post", userFullName(s.db, userID)),
+			postID,
+		)
+		notified[postAuthorID] = true
+	}
+	notifyMentions(s.db, s.notificationService, userID, content, "group comment", postID,
+		s.isMember(groupID), notified)
+
 	return comment, nil
 }
+
+// loadGroupPostReactions fills in the reaction summaries of group posts and
+// their comments.
+func (s *GroupService) loadGroupPostReactions(posts []model.GroupPost, viewerID string) error {
+	var postIDs, commentIDs []string
+	for _, post := range posts {
+		postIDs = append(postIDs, post.ID)
+		for _, comment := range post.Comments {
+			commentIDs = append(commentIDs, comment.ID)
+		}
+	}
+
+	postReactions, err := loadReactionSummaries(s.db, model.ReactionTargetGroupPost, postIDs, viewerID)
+	if err != nil {
+		return err
+	}
+	commentReactions, err := loadReactionSummaries(s.db, model.ReactionTargetGroupComment, commentIDs, viewerID)
+	if err != nil {
+		return err
+	}
+
+	for i := range posts {
+		posts[i].Reactions = postReactions[posts[i].ID]
+		for j := range posts[i].Comments {
+			posts[i].Comments[j].Reactions = commentReactions[posts[i].Comments[j].ID]
+		}
+	}
+	return nil
+}
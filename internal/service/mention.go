@@ -0,0 +1,85 @@
+package service
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"regexp"
+	"social-network/internal/notification"
+	"strings"
+)
+
+// mentionPattern matches @nickname mentions that don't start in the middle of
+// a word or an email address.
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@([\w.-]+)`)
+
+// mentionedUsers returns the IDs of the users whose nickname is mentioned in
+// content. Nicknames are matched case-insensitively.
+func mentionedUsers(db *sql.DB, content string) ([]string, error) {
+	var args []interface{}
+	seen := make(map[string]bool)
+	for _, match := range mentionPattern.FindAllStringSubmatch(content, -1) {
+		// Trailing punctuation ends the sentence, not the nickname
+		nickname := strings.ToLower(strings.TrimRight(match[1], ".-"))
+		if nickname != "" && !seen[nickname] {
+			seen[nickname] = true
+			args = append(args, nickname)
+		}
+	}
+	if len(args) == 0 {
+		return nil, nil
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(args)), ", ")
+
+	rows, err := db.Query(`
+        SELECT id FROM users
+        WHERE LOWER(nickname) IN (`+placeholders+`)`,
+		args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var userIDs []string
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, userID)
+	}
+	return userIDs, rows.Err()
+}
+
+// notifyMentions sends a mention notification to every user mentioned in
+// content who can see it, skipping the users in notified, which always
+// includes the author.
+func notifyMentions(db *sql.DB, notificationService notification.Service, authorID, content, kind, referenceID string, canSee func(userID string) bool, notified map[string]bool) {
+	userIDs, err := mentionedUsers(db, content)
+	if err != nil {
+		log.Printf("Error resolving mentions: %v", err)
+		return
+	}
+
+	for _, userID := range userIDs {
+		if userID == authorID || notified[userID] || !canSee(userID) {
+			continue
+		}
+		err := notificationService.CreateNotification(
+			userID,
+			"mention",
+			fmt.Sprintf("%s mentioned you in a %s", userFullName(db, authorID), kind),
+			referenceID,
+		)
+		if err != nil {
+			log.Printf("Error creating mention notification: %v", err)
+		}
+	}
+}
+
+func userFullName(db *sql.DB, userID string) string {
+	var firstName, lastName string
+	db.QueryRow("SELECT first_name, last_name FROM users WHERE id = ?", userID).
+		Scan(&firstName, &lastName)
+	return strings.TrimSpace(firstName + " " + lastName)
+}
@@ -0,0 +1,341 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"social-network/internal/model"
+	"social-network/internal/realtime"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrMessageNotFound   = errors.New("message not found")
+	ErrNotMessageSender  = errors.New("only the sender can change this message")
+	ErrEditWindowExpired = errors.New("message can no longer be changed")
+	ErrMessageDeleted    = errors.New("message has been deleted")
+)
+
+// MessageEdit is a previous version of an edited message.
+type MessageEdit struct {
+	ID              string    `json:"id"`
+	MessageID       string    `json:"message_id"`
+	PreviousContent string    `json:"previous_content"`
+	EditedBy        string    `json:"edited_by"`
+	EditedAt        time.Time `json:"edited_at"`
+}
+
+// Edit a message. Only the sender may edit, and only within the edit window.
+func (s *ChatService) EditMessage(messageID, userID, content string) (*Message, error) {
+	msg, err := s.getMessage(messageID)
+	if err != nil {
+		return nil, err
+	}
+	if msg.SenderID != userID {
+		return nil, ErrNotMessageSender
+	}
+	if msg.DeletedAt != nil {
+		return nil, ErrMessageDeleted
+	}
+	if time.Since(msg.CreatedAt) > s.config.EditWindow {
+		return nil, ErrEditWindowExpired
+	}
+
+	now := time.Now()
+	if err := s.saveMessageEdit(msg, userID, content, now); err != nil {
+		return nil, err
+	}
+
+	msg.Content = content
+	msg.EditedAt = &now
+	s.publishToParticipants(msg, realtime.TypeMessageEdited)
+	return msg, nil
+}
+
+// saveMessageEdit records the current content of msg in message_edits and
+// replaces it. A message deleted since the caller loaded it is reported as
+// ErrMessageDeleted.
+func (s *ChatService) saveMessageEdit(msg *Message, userID, content string, now time.Time) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// The previous content is read in the transaction so that concurrent
+	// edits each record the version they replaced
+	result, err := tx.Exec(`
+        INSERT INTO message_edits (id, message_id, message_type, previous_content, edited_by, edited_at)
+        SELECT ?, id, ?, content, ?, ?
+        FROM `+messageTable(msg)+`
+        WHERE id = ? AND deleted_at IS NULL`,
+		uuid.New().String(), msg.Type, userID, now, msg.ID,
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrMessageDeleted
+	}
+
+	result, err = tx.Exec(`
+        UPDATE `+messageTable(msg)+`
+        SET content = ?, edited_at = ?
+        WHERE id = ? AND deleted_at IS NULL`,
+		content, now, msg.ID,
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrMessageDeleted
+	}
+
+	return tx.Commit()
+}
+
+// Delete a message for everyone. The sender may delete within the edit
+// window; the creator of a group may delete any message of that group. The
+// row is kept as a tombstone so history pagination stays stable.
+func (s *ChatService) DeleteMessage(messageID, userID string) (*Message, error) {
+	msg, err := s.getMessage(messageID)
+	if err != nil {
+		return nil, err
+	}
+	if msg.DeletedAt != nil {
+		return nil, ErrMessageDeleted
+	}
+
+	allowed := msg.SenderID == userID && time.Since(msg.CreatedAt) <= s.config.EditWindow
+	if !allowed && msg.Type == "group" {
+		var creatorID string
+		err := s.db.QueryRow("SELECT creator_id FROM groups WHERE id = ?", msg.GroupID).Scan(&creatorID)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+		allowed = creatorID == userID
+	}
+	if !allowed {
+		if msg.SenderID == userID {
+			return nil, ErrEditWindowExpired
+		}
+		return nil, ErrNotMessageSender
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	result, err := tx.Exec(`
+        UPDATE `+messageTable(msg)+`
+        SET content = '', deleted_at = ?
+        WHERE id = ? AND deleted_at IS NULL`,
+		now, msg.ID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 0 {
+		return nil, ErrMessageDeleted
+	}
+
+	// Earlier versions would still reveal the deleted content
+	if _, err := tx.Exec(`DELETE FROM message_edits WHERE message_id = ?`, msg.ID); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`DELETE FROM message_attachments WHERE message_id = ?`, msg.ID); err != nil {
+		return nil, err
+	}
+
+	// Message notifications quote the content, and reactions have nothing
+	// left to point at
+	removed, err := deleteNotifications(tx, `
+        type IN ('private_message', 'group_message') AND reference_id = ?`, msg.ID)
+	if err != nil {
+		return nil, err
+	}
+	reactionTarget := model.ReactionTargetMessage
+	if msg.Type == "group" {
+		reactionTarget = model.ReactionTargetGroupMessage
+	}
+	if _, err := tx.Exec(`
+        DELETE FROM reactions WHERE target_type = ? AND target_id = ?`,
+		reactionTarget, msg.ID,
+	); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	publishNotificationsRemoved(s.hub, removed)
+
+	if msg.Attachment != nil {
+		s.removeAttachmentFile(msg.Attachment)
+		msg.Attachment = nil
+	}
+	msg.Content = ""
+	msg.DeletedAt = &now
+	s.publishToParticipants(msg, realtime.TypeMessageDeleted)
+	return msg, nil
+}
+
+// Get the previous versions of a message, oldest first. Only participants of
+// the conversation may see them.
+func (s *ChatService) GetMessageEdits(messageID, userID string) ([]MessageEdit, error) {
+	msg, err := s.getMessage(messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	participant, err := s.isParticipant(msg, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !participant {
+		return nil, ErrMessageNotFound
+	}
+
+	rows, err := s.db.Query(`
+        SELECT id, message_id, previous_content, edited_by, edited_at
+        FROM message_edits
+        WHERE message_id = ?
+        ORDER BY edited_at ASC`,
+		messageID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	edits := []MessageEdit{}
+	for rows.Next() {
+		var edit MessageEdit
+		err := rows.Scan(&edit.ID, &edit.MessageID, &edit.PreviousContent, &edit.EditedBy, &edit.EditedAt)
+		if err != nil {
+			return nil, err
+		}
+		edits = append(edits, edit)
+	}
+	return edits, rows.Err()
+}
+
+// getMessage loads a private or group message by ID.
+func (s *ChatService) getMessage(messageID string) (*Message, error) {
+	var msg Message
+	var editedAt, deletedAt sql.NullTime
+
+	err := s.db.QueryRow(`
+        SELECT id, sender_id, recipient_id, content, created_at, is_read, edited_at, deleted_at
+        FROM messages
+        WHERE id = ?`,
+		messageID,
+	).Scan(&msg.ID, &msg.SenderID, &msg.RecipientID, &msg.Content, &msg.CreatedAt, &msg.IsRead, &editedAt, &deletedAt)
+	if err == nil {
+		msg.Type = "private"
+	} else if err != sql.ErrNoRows {
+		return nil, err
+	} else {
+		err = s.db.QueryRow(`
+            SELECT id, sender_id, group_id, content, created_at, edited_at, deleted_at
+            FROM group_messages
+            WHERE id = ?`,
+			messageID,
+		).Scan(&msg.ID, &msg.SenderID, &msg.GroupID, &msg.Content, &msg.CreatedAt, &editedAt, &deletedAt)
+		if err == sql.ErrNoRows {
+			return nil, ErrMessageNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		msg.Type = "group"
+	}
+
+	msg.EditedAt = nullTime(editedAt)
+	msg.DeletedAt = nullTime(deletedAt)
+
+	messages := []Message{msg}
+	if err := s.loadAttachments(messages); err != nil {
+		return nil, err
+	}
+	return &messages[0], nil
+}
+
+func (s *ChatService) isParticipant(msg *Message, userID string) (bool, error) {
+	if msg.Type == "private" {
+		return msg.SenderID == userID || msg.RecipientID == userID, nil
+	}
+
+	var status string
+	err := s.db.QueryRow(`
+        SELECT status FROM group_members
+        WHERE group_id = ? AND user_id = ? AND status = 'accepted'`,
+		msg.GroupID, userID).Scan(&status)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// publishToParticipants sends a frame carrying msg to every connection of
+// every participant of its conversation.
+func (s *ChatService) publishToParticipants(msg *Message, frameType string) {
+	frame := realtime.NewFrame(frameType, msg)
+
+	recipients := []string{msg.SenderID, msg.RecipientID}
+	if msg.Type == "group" {
+		rows, err := s.db.Query(`
+            SELECT user_id FROM group_members
+            WHERE group_id = ? AND status = 'accepted'`,
+			msg.GroupID)
+		if err != nil {
+			log.Printf("Error getting group members: %v", err)
+			return
+		}
+		defer rows.Close()
+
+		recipients = nil
+		for rows.Next() {
+			var memberID string
+			if err := rows.Scan(&memberID); err != nil {
+				log.Printf("Error scanning member ID: %v", err)
+				continue
+			}
+			recipients = append(recipients, memberID)
+		}
+	}
+
+	for _, userID := range recipients {
+		if err := s.hub.Publish(userID, frame); err != nil {
+			log.Printf("Error sending WebSocket message to %s: %v", userID, err)
+		}
+	}
+}
+
+func messageTable(msg *Message) string {
+	if msg.Type == "group" {
+		return "group_messages"
+	}
+	return "messages"
+}
+
+func nullTime(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"social-network/internal/model"
+	"testing"
+	"time"
+)
+
+func TestDeleteMessageRemovesNotificationsAndReactions(t *testing.T) {
+	s := newTestChatService(t)
+	for _, id := range []string{"alice", "bob", "carol"} {
+		seedUser(t, s.db, id, true)
+	}
+	seedGroup(t, s.db, "hikers", "alice", "bob", "carol")
+
+	private, err := s.SendPrivateMessage("alice", "bob", "secret plan", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	group, err := s.SendGroupMessage("hikers", "alice", "group secret", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.db.Exec(`
+        INSERT INTO reactions (id, target_type, target_id, user_id, emoji, created_at)
+        VALUES ('r1', ?, ?, 'bob', '👍', CURRENT_TIMESTAMP),
+               ('r2', ?, ?, 'carol', '👍', CURRENT_TIMESTAMP)`,
+		model.ReactionTargetMessage, private.ID, model.ReactionTargetGroupMessage, group.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, msg := range []*Message{private, group} {
+		var before int
+		err := s.db.QueryRow(`SELECT COUNT(*) FROM notifications WHERE reference_id = ?`, msg.ID).Scan(&before)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if before == 0 {
+			t.Fatalf("%s message created no notifications", msg.Type)
+		}
+
+		if _, err := s.DeleteMessage(msg.ID, "alice"); err != nil {
+			t.Fatal(err)
+		}
+		var notifications, reactions int
+		err = s.db.QueryRow(`SELECT COUNT(*) FROM notifications WHERE reference_id = ?`, msg.ID).Scan(&notifications)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = s.db.QueryRow(`SELECT COUNT(*) FROM reactions WHERE target_id = ?`, msg.ID).Scan(&reactions)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if notifications != 0 || reactions != 0 {
+			t.Errorf("%s message: %d notifications and %d reactions left after delete",
+				msg.Type, notifications, reactions)
+		}
+	}
+}
+
+func TestDeletedMessagesCannotBeEdited(t *testing.T) {
+	s := newTestChatService(t)
+	seedUser(t, s.db, "alice", true)
+	seedUser(t, s.db, "bob", true)
+	seedGroup(t, s.db, "hikers", "alice", "bob")
+
+	private, err := s.SendPrivateMessage("alice", "bob", "first", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	group, err := s.SendGroupMessage("hikers", "alice", "first", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, msg := range []*Message{private, group} {
+		loaded, err := s.getMessage(msg.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.DeleteMessage(msg.ID, "alice"); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := s.EditMessage(msg.ID, "alice", "back"); err != ErrMessageDeleted {
+			t.Errorf("%s EditMessage: err = %v, want ErrMessageDeleted", msg.Type, err)
+		}
+		// A deletion between loading the message and editing it
+		if err := s.saveMessageEdit(loaded, "alice", "back", time.Now()); err != ErrMessageDeleted {
+			t.Errorf("%s saveMessageEdit: err = %v, want ErrMessageDeleted", msg.Type, err)
+		}
+		if _, err := s.DeleteMessage(msg.ID, "alice"); err != ErrMessageDeleted {
+			t.Errorf("%s second DeleteMessage: err = %v, want ErrMessageDeleted", msg.Type, err)
+		}
+
+		var content string
+		var edits int
+		err = s.db.QueryRow(`
+            SELECT content, (SELECT COUNT(*) FROM message_edits WHERE message_id = m.id)
+            FROM `+messageTable(msg)+` m WHERE id = ?`, msg.ID).Scan(&content, &edits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if content != "" || edits != 0 {
+			t.Errorf("deleted %s message has content %q and %d edits, want neither", msg.Type, content, edits)
+		}
+	}
+}
+
+func TestConversationPreviewsShowEditsAndDeletions(t *testing.T) {
+	s := newTestChatService(t)
+	seedUser(t, s.db, "alice", true)
+	seedUser(t, s.db, "bob", true)
+	seedGroup(t, s.db, "hikers", "alice", "bob")
+
+	private, err := s.SendPrivateMessage("alice", "bob", "first", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	group, err := s.SendGroupMessage("hikers", "alice", "first", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.EditMessage(private.ID, "alice", "second"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.DeleteMessage(group.ID, "alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	conversations, err := s.GetConversations("bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range conversations {
+		last := c.LastMessage
+		switch c.ID {
+		case "alice":
+			if last.Content != "second" || last.EditedAt == nil || last.DeletedAt != nil {
+				t.Errorf("private preview = %+v, want the edited content", last)
+			}
+		case "hikers":
+			if last.Content != "" || last.DeletedAt == nil {
+				t.Errorf("group preview = %+v, want a deleted message", last)
+			}
+		}
+	}
+}
@@ -2,26 +2,48 @@ package service
 
 import (
 	"database/sql"
-	"encoding/json"
+	"errors"
+	"log"
+	"social-network/internal/model"
+	"social-network/internal/realtime"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/gorilla/websocket"
 )
 
+var ErrNotificationNotFound = errors.New("notification not found")
+
 type NotificationService struct {
-	db          *sql.DB
-	connections map[string]*websocket.Conn
+	db  *sql.DB
+	hub *realtime.Hub
 }
 
-func NewNotificationService(db *sql.DB) *NotificationService {
+func NewNotificationService(db *sql.DB, hub *realtime.Hub) *NotificationService {
 	return &NotificationService{
-		db:          db,
-		connections: make(map[string]*websocket.Conn),
+		db:  db,
+		hub: hub,
 	}
 }
 
+// CreateNotification stores a notification and pushes it to the user's open
+// connections, as far as the user's preferences and muted conversations allow.
 func (s *NotificationService) CreateNotification(userID string, notificationType string, content string, referenceID string) error {
+	delivery, err := s.delivery(userID, notificationType)
+	if err != nil {
+		return err
+	}
+	if delivery == model.DeliveryNone {
+		return nil
+	}
+	muted, err := s.muted(userID, notificationType, referenceID)
+	if err != nil {
+		return err
+	}
+	if muted {
+		return nil
+	}
+
 	notification := struct {
 		ID          string    `json:"id"`
 		UserID      string    `json:"user_id"`
@@ -40,7 +62,7 @@ func (s *NotificationService) CreateNotification(userID string, notificationType
 		CreatedAt:   time.Now(),
 	}
 
-	_, err := s.db.Exec(`
+	_, err = s.db.Exec(`
         INSERT INTO notifications (id, user_id, type, content, reference_id, is_read, created_at)
         VALUES (?, ?, ?, ?, ?, ?, ?)`,
 		notification.ID,
@@ -56,29 +78,66 @@ func (s *NotificationService) CreateNotification(userID string, notificationType
 	}
 
 	// Send real-time notification if user is connected
-	if conn, ok := s.connections[userID]; ok {
-		notificationJson, _ := json.Marshal(notification)
-		conn.WriteMessage(websocket.TextMessage, notificationJson)
+	if delivery == model.DeliveryPush {
+		if err := s.hub.Publish(userID, realtime.NewFrame(realtime.TypeNotification, notification)); err != nil {
+			log.Printf("Error sending notification to %s: %v", userID, err)
+		}
 	}
 
 	return nil
 }
 
-func (s *NotificationService) GetUserNotifications(userID string) ([]map[string]interface{}, error) {
-	rows, err := s.db.Query(`
+// NotificationFilter narrows a notification listing. Zero values match
+// every notification.
+type NotificationFilter struct {
+	Types  []string
+	IsRead *bool
+}
+
+// NotificationPage is a page of notifications, newest first. NextCursor pages
+// towards older notifications, or towards newer ones when the page was
+// requested with After.
+type NotificationPage struct {
+	Notifications []map[string]interface{} `json:"notifications"`
+	NextCursor    string                   `json:"next_cursor,omitempty"`
+}
+
+func (s *NotificationService) GetUserNotifications(userID string, filter NotificationFilter, page PageRequest) (*NotificationPage, error) {
+	query := `
         SELECT n.id, n.type, n.content, n.reference_id, n.is_read, n.created_at,
-               COALESCE(gm.status, 'unknown') as invitation_status
+               COALESCE((
+                   SELECT gm.status FROM group_members gm
+                   WHERE gm.group_id = n.reference_id AND gm.user_id = n.user_id
+               ), 'unknown') as invitation_status
         FROM notifications n
-        LEFT JOIN group_members gm ON n.reference_id = gm.group_id AND gm.user_id = n.user_id
-        WHERE n.user_id = ?
-        ORDER BY n.created_at DESC`,
-		userID)
+        WHERE n.user_id = ?`
+	args := []interface{}{userID}
+
+	if len(filter.Types) > 0 {
+		query += `
+        AND n.type IN (` + strings.TrimSuffix(strings.Repeat("?, ", len(filter.Types)), ", ") + `)`
+		for _, notificationType := range filter.Types {
+			args = append(args, notificationType)
+		}
+	}
+	if filter.IsRead != nil {
+		query += `
+        AND n.is_read = ?`
+		args = append(args, *filter.IsRead)
+	}
+
+	query, args, descending, err := page.cursorQuery(query, args)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var notifications []map[string]interface{}
+	notifications := []map[string]interface{}{}
 	for rows.Next() {
 		var id, notificationType, content, referenceID, invitationStatus string
 		var isRead bool
@@ -104,8 +163,24 @@ func (s *NotificationService) GetUserNotifications(userID string) ([]map[string]
 
 		notifications = append(notifications, notification)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	result := &NotificationPage{}
+	result.Notifications, result.NextCursor = paginate(page, notifications, descending, newestFirst, rowKey("created_at"))
+	return result, nil
+}
 
-	return notifications, nil
+// Count the unread notifications of a user. Cheap enough to poll.
+func (s *NotificationService) GetUnreadCount(userID string) (int, error) {
+	var count int
+	err := s.db.QueryRow(`
+        SELECT COUNT(*) FROM notifications
+        WHERE user_id = ? AND is_read = false`,
+		userID,
+	).Scan(&count)
+	return count, err
 }
 
 func (s *NotificationService) MarkAsRead(notificationID string, userID string) error {
@@ -117,10 +192,90 @@ func (s *NotificationService) MarkAsRead(notificationID string, userID string) e
 	return err
 }
 
-func (s *NotificationService) RegisterConnection(userID string, conn *websocket.Conn) {
-	s.connections[userID] = conn
+// Mark every unread notification of a user as read, or only those of one
+// type. It returns how many were marked.
+func (s *NotificationService) MarkAllAsRead(userID string, notificationType string) (int64, error) {
+	query := `
+        UPDATE notifications
+        SET is_read = true
+        WHERE user_id = ? AND is_read = false`
+	args := []interface{}{userID}
+	if notificationType != "" {
+		query += ` AND type = ?`
+		args = append(args, notificationType)
+	}
+
+	result, err := s.db.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
 
-func (s *NotificationService) RemoveConnection(userID string) {
-	delete(s.connections, userID)
+func (s *NotificationService) DeleteNotification(notificationID string, userID string) error {
+	result, err := s.db.Exec(`
+        DELETE FROM notifications
+        WHERE id = ? AND user_id = ?`,
+		notificationID, userID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrNotificationNotFound
+	}
+	return err
+}
+
+// Delete every notification of a user. It returns how many were deleted.
+func (s *NotificationService) DeleteAllNotifications(userID string) (int64, error) {
+	result, err := s.db.Exec(`DELETE FROM notifications WHERE user_id = ?`, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// deleteNotifications deletes the notifications matching where, a condition
+// on the notifications table, and returns their IDs by recipient so the
+// removal can be published once tx commits.
+func deleteNotifications(tx *sql.Tx, where string, args ...interface{}) (map[string][]string, error) {
+	rows, err := tx.Query(`SELECT id, user_id FROM notifications WHERE `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	removed := make(map[string][]string)
+	for rows.Next() {
+		var id, userID string
+		if err := rows.Scan(&id, &userID); err != nil {
+			return nil, err
+		}
+		removed[userID] = append(removed[userID], id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if len(removed) == 0 {
+		return removed, nil
+	}
+	if _, err := tx.Exec(`DELETE FROM notifications WHERE `+where, args...); err != nil {
+		return nil, err
+	}
+	return removed, nil
+}
+
+// publishNotificationsRemoved tells the recipients' open connections to drop
+// the notifications returned by deleteNotifications.
+func publishNotificationsRemoved(hub *realtime.Hub, removed map[string][]string) {
+	for userID, ids := range removed {
+		frame := realtime.NewFrame(realtime.TypeNotificationsRemoved, realtime.NotificationsRemovedPayload{
+			NotificationIDs: ids,
+		})
+		if err := hub.Publish(userID, frame); err != nil {
+			log.Printf("Error publishing removed notifications to %s: %v", userID, err)
+		}
+	}
 }
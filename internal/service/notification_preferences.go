@@ -0,0 +1,206 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"social-network/internal/model"
+	"time"
+)
+
+var (
+	ErrUnknownNotificationType = errors.New("unknown notification type")
+	ErrInvalidDelivery         = errors.New("delivery must be push, in_app or none")
+	ErrInvalidConversationType = errors.New("conversation type must be private or group")
+)
+
+// NotificationTypes lists every notification type a user can configure.
+var NotificationTypes = []string{
+	"follow_request",
+	"follow_accepted",
+	"new_follower",
+	"group_invite",
+	"group_join_request",
+	"group_request_accepted",
+	"group_event",
+	"private_message",
+	"group_message",
+	"post_comment",
+	"comment_reply",
+	"mention",
+}
+
+// Get the delivery setting of every notification type. Types the user never
+// configured are pushed.
+func (s *NotificationService) GetPreferences(userID string) ([]model.NotificationPreference, error) {
+	rows, err := s.db.Query(`
+        SELECT type, delivery FROM notification_preferences WHERE user_id = ?`,
+		userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	configured := make(map[string]string)
+	for rows.Next() {
+		var notificationType, delivery string
+		if err := rows.Scan(&notificationType, &delivery); err != nil {
+			return nil, err
+		}
+		configured[notificationType] = delivery
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	preferences := make([]model.NotificationPreference, 0, len(NotificationTypes))
+	for _, notificationType := range NotificationTypes {
+		delivery := model.DeliveryPush
+		if d, ok := configured[notificationType]; ok {
+			delivery = d
+		}
+		preferences = append(preferences, model.NotificationPreference{
+			Type:     notificationType,
+			Delivery: delivery,
+		})
+	}
+	return preferences, nil
+}
+
+// Update the delivery setting of one or more notification types. Nothing is
+// saved unless every preference is valid.
+func (s *NotificationService) SetPreferences(userID string, preferences []model.NotificationPreference) error {
+	for _, preference := range preferences {
+		if !knownNotificationType(preference.Type) {
+			return ErrUnknownNotificationType
+		}
+		switch preference.Delivery {
+		case model.DeliveryPush, model.DeliveryInApp, model.DeliveryNone:
+		default:
+			return ErrInvalidDelivery
+		}
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, preference := range preferences {
+		_, err := tx.Exec(`
+            INSERT INTO notification_preferences (user_id, type, delivery, updated_at)
+            VALUES (?, ?, ?, ?)
+            ON CONFLICT (user_id, type) DO UPDATE SET
+                delivery = excluded.delivery,
+                updated_at = excluded.updated_at`,
+			userID, preference.Type, preference.Delivery, time.Now(),
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Stop message notifications from a private chat (id is the other user) or a
+// group chat (id is the group). Messages are still delivered.
+func (s *NotificationService) MuteConversation(userID, conversationType, conversationID string) error {
+	if conversationType != "private" && conversationType != "group" {
+		return ErrInvalidConversationType
+	}
+	_, err := s.db.Exec(`
+        INSERT OR IGNORE INTO muted_conversations (user_id, conversation_type, conversation_id, created_at)
+        VALUES (?, ?, ?, ?)`,
+		userID, conversationType, conversationID, time.Now(),
+	)
+	return err
+}
+
+func (s *NotificationService) UnmuteConversation(userID, conversationType, conversationID string) error {
+	if conversationType != "private" && conversationType != "group" {
+		return ErrInvalidConversationType
+	}
+	_, err := s.db.Exec(`
+        DELETE FROM muted_conversations
+        WHERE user_id = ? AND conversation_type = ? AND conversation_id = ?`,
+		userID, conversationType, conversationID,
+	)
+	return err
+}
+
+func (s *NotificationService) GetMutedConversations(userID string) ([]model.MutedConversation, error) {
+	rows, err := s.db.Query(`
+        SELECT conversation_type, conversation_id, created_at
+        FROM muted_conversations
+        WHERE user_id = ?
+        ORDER BY created_at DESC`,
+		userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	muted := []model.MutedConversation{}
+	for rows.Next() {
+		var conversation model.MutedConversation
+		if err := rows.Scan(&conversation.Type, &conversation.ID, &conversation.MutedAt); err != nil {
+			return nil, err
+		}
+		muted = append(muted, conversation)
+	}
+	return muted, rows.Err()
+}
+
+// delivery returns how a notification of the given type reaches the user.
+func (s *NotificationService) delivery(userID, notificationType string) (string, error) {
+	var delivery string
+	err := s.db.QueryRow(`
+        SELECT delivery FROM notification_preferences
+        WHERE user_id = ? AND type = ?`,
+		userID, notificationType,
+	).Scan(&delivery)
+	if err == sql.ErrNoRows {
+		return model.DeliveryPush, nil
+	}
+	return delivery, err
+}
+
+// muted reports whether a message notification belongs to a conversation the
+// user muted. Message notifications reference the message, so the
+// conversation is looked up from it.
+func (s *NotificationService) muted(userID, notificationType, referenceID string) (bool, error) {
+	var query string
+	switch notificationType {
+	case "private_message":
+		query = `
+            SELECT EXISTS (
+                SELECT 1 FROM messages m
+                JOIN muted_conversations mc ON mc.conversation_type = 'private'
+                    AND mc.conversation_id = m.sender_id
+                WHERE m.id = ? AND mc.user_id = ?
+            )`
+	case "group_message":
+		query = `
+            SELECT EXISTS (
+                SELECT 1 FROM group_messages m
+                JOIN muted_conversations mc ON mc.conversation_type = 'group'
+                    AND mc.conversation_id = m.group_id
+                WHERE m.id = ? AND mc.user_id = ?
+            )`
+	default:
+		return false, nil
+	}
+
+	var muted bool
+	err := s.db.QueryRow(query, referenceID, userID).Scan(&muted)
+	return muted, err
+}
+
+func knownNotificationType(notificationType string) bool {
+	for _, t := range NotificationTypes {
+		if t == notificationType {
+			return true
+		}
+	}
+	return false
+}
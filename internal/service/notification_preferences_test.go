@@ -0,0 +1,193 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"social-network/internal/model"
+	"social-network/internal/realtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// connectUser opens a WebSocket connection for userID registered with hub.
+func connectUser(t *testing.T, hub *realtime.Hub, userID string) *websocket.Conn {
+	t.Helper()
+	upgrader := websocket.Upgrader{}
+	registered := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		client := hub.Register(userID, "", conn)
+		close(registered)
+		defer hub.Unregister(client)
+		for {
+			if _, err := client.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	<-registered
+	return conn
+}
+
+// pushedTypes returns the types of the notification frames received on conn
+// within a short wait.
+func pushedTypes(t *testing.T, conn *websocket.Conn) []string {
+	t.Helper()
+	var types []string
+	for {
+		conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+		var frame struct {
+			Type    string
+			Payload model.Notification
+		}
+		if err := conn.ReadJSON(&frame); err != nil {
+			return types
+		}
+		if frame.Type == realtime.TypeNotification {
+			types = append(types, frame.Payload.Type)
+		}
+	}
+}
+
+func storedTypes(t *testing.T, s *NotificationService, userID string) map[string]int {
+	t.Helper()
+	page, err := s.GetUserNotifications(userID, NotificationFilter{}, PageRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	types := make(map[string]int)
+	for _, n := range page.Notifications {
+		types[n["type"].(string)]++
+	}
+	return types
+}
+
+func TestNotificationPreferences(t *testing.T) {
+	db := newTestDB(t)
+	seedUser(t, db, "alice", true)
+	hub := realtime.NewHub(realtime.DefaultConfig)
+	s := NewNotificationService(db, hub)
+	conn := connectUser(t, hub, "alice")
+
+	preferences, err := s.GetPreferences("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(preferences) != len(NotificationTypes) {
+		t.Fatalf("got %d preferences, want one per type", len(preferences))
+	}
+	for _, p := range preferences {
+		if p.Delivery != model.DeliveryPush {
+			t.Errorf("%s defaults to %s", p.Type, p.Delivery)
+		}
+	}
+
+	// An invalid preference leaves every other one unsaved
+	err = s.SetPreferences("alice", []model.NotificationPreference{
+		{Type: "mention", Delivery: model.DeliveryNone},
+		{Type: "mention", Delivery: "sometimes"},
+	})
+	if err != ErrInvalidDelivery {
+		t.Errorf("invalid delivery: %v", err)
+	}
+	err = s.SetPreferences("alice", []model.NotificationPreference{{Type: "poke", Delivery: model.DeliveryNone}})
+	if err != ErrUnknownNotificationType {
+		t.Errorf("unknown type: %v", err)
+	}
+
+	err = s.SetPreferences("alice", []model.NotificationPreference{
+		{Type: "new_follower", Delivery: model.DeliveryInApp},
+		{Type: "mention", Delivery: model.DeliveryNone},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, notificationType := range []string{"post_comment", "new_follower", "mention"} {
+		if err := s.CreateNotification("alice", notificationType, "x", "ref"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stored := storedTypes(t, s, "alice")
+	if stored["post_comment"] != 1 || stored["new_follower"] != 1 || stored["mention"] != 0 {
+		t.Errorf("stored notifications = %v, want push and in_app ones only", stored)
+	}
+	if pushed := pushedTypes(t, conn); len(pushed) != 1 || pushed[0] != "post_comment" {
+		t.Errorf("pushed notifications = %v, want only the push one", pushed)
+	}
+}
+
+func TestMutedConversations(t *testing.T) {
+	s := newTestChatService(t)
+	notifications := s.notificationService.(*NotificationService)
+	for _, id := range []string{"alice", "bob", "carol"} {
+		seedUser(t, s.db, id, true)
+	}
+	seedGroup(t, s.db, "hikers", "bob", "alice")
+
+	if err := notifications.MuteConversation("alice", "channel", "bob"); err != ErrInvalidConversationType {
+		t.Errorf("muting an unknown conversation type: %v", err)
+	}
+	if err := notifications.MuteConversation("alice", "private", "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if err := notifications.MuteConversation("alice", "group", "hikers"); err != nil {
+		t.Fatal(err)
+	}
+	muted, err := notifications.GetMutedConversations("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(muted) != 2 {
+		t.Errorf("muted conversations = %+v", muted)
+	}
+
+	// Muted chats still deliver messages, without notifications
+	if _, err := s.SendPrivateMessage("bob", "alice", "hi", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.SendGroupMessage("hikers", "bob", "hi all", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.SendPrivateMessage("carol", "alice", "hi", nil); err != nil {
+		t.Fatal(err)
+	}
+	stored := storedTypes(t, notifications, "alice")
+	if stored["private_message"] != 1 || stored["group_message"] != 0 {
+		t.Errorf("notifications while muted = %v, want only carol's message", stored)
+	}
+
+	conversations, err := s.GetConversations("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range conversations {
+		if c.Muted != (c.ID != "carol") {
+			t.Errorf("%s conversation muted = %v", c.ID, c.Muted)
+		}
+	}
+
+	if err := notifications.UnmuteConversation("alice", "private", "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.SendPrivateMessage("bob", "alice", "again", nil); err != nil {
+		t.Fatal(err)
+	}
+	if stored := storedTypes(t, notifications, "alice"); stored["private_message"] != 2 {
+		t.Errorf("notifications after unmuting = %v", stored)
+	}
+}
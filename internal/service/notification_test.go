@@ -0,0 +1,142 @@
+package service
+
+import (
+	"fmt"
+	"reflect"
+	"social-network/internal/realtime"
+	"testing"
+	"time"
+)
+
+// seedNotifications inserts notifications n1 (oldest) to nN for userID,
+// alternating between mention and new_follower, with the even ones read.
+func seedNotifications(t *testing.T, s *NotificationService, userID string, n int) {
+	t.Helper()
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i := 1; i <= n; i++ {
+		notificationType := "mention"
+		if i%2 == 0 {
+			notificationType = "new_follower"
+		}
+		_, err := s.db.Exec(`
+            INSERT INTO notifications (id, user_id, type, content, reference_id, is_read, created_at)
+            VALUES (?, ?, ?, 'x', 'ref', ?, ?)`,
+			fmt.Sprintf("n%d", i), userID, notificationType, i%2 == 0, start.Add(time.Duration(i)*time.Minute))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func newTestNotificationService(t *testing.T) *NotificationService {
+	t.Helper()
+	db := newTestDB(t)
+	seedUser(t, db, "alice", true)
+	seedUser(t, db, "bob", true)
+	return NewNotificationService(db, realtime.NewHub(realtime.DefaultConfig))
+}
+
+// notificationPages walks every page of alice's notifications from page on.
+func notificationPages(t *testing.T, s *NotificationService, filter NotificationFilter, page PageRequest) [][]string {
+	t.Helper()
+	var pages [][]string
+	for {
+		result, err := s.GetUserNotifications("alice", filter, page)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, n := range result.Notifications {
+			ids = append(ids, n["id"].(string))
+		}
+		pages = append(pages, ids)
+		if result.NextCursor == "" {
+			return pages
+		}
+		if page.After != "" {
+			page.After = result.NextCursor
+		} else {
+			page.Before = result.NextCursor
+		}
+	}
+}
+
+func TestNotificationsPageNewestFirst(t *testing.T) {
+	s := newTestNotificationService(t)
+	seedNotifications(t, s, "alice", 5)
+
+	pages := notificationPages(t, s, NotificationFilter{}, PageRequest{Limit: 2})
+	want := [][]string{{"n5", "n4"}, {"n3", "n2"}, {"n1"}}
+	if !reflect.DeepEqual(pages, want) {
+		t.Errorf("pages = %v, want %v", pages, want)
+	}
+
+	pages = notificationPages(t, s, NotificationFilter{}, PageRequest{After: encodeCursor(pageKey{time.Date(2024, 1, 1, 12, 1, 0, 0, time.UTC), "n1"}), Limit: 2})
+	want = [][]string{{"n3", "n2"}, {"n5", "n4"}}
+	if !reflect.DeepEqual(pages, want) {
+		t.Errorf("pages after n1 = %v, want %v", pages, want)
+	}
+}
+
+func TestNotificationFilters(t *testing.T) {
+	s := newTestNotificationService(t)
+	seedNotifications(t, s, "alice", 6)
+
+	unread := false
+	tests := []struct {
+		filter NotificationFilter
+		want   []string
+	}{
+		{NotificationFilter{Types: []string{"new_follower"}}, []string{"n6", "n4", "n2"}},
+		{NotificationFilter{IsRead: &unread}, []string{"n5", "n3", "n1"}},
+		{NotificationFilter{Types: []string{"mention", "new_follower"}, IsRead: &unread}, []string{"n5", "n3", "n1"}},
+		{NotificationFilter{Types: []string{"group_invite"}}, nil},
+	}
+	for _, tt := range tests {
+		pages := notificationPages(t, s, tt.filter, PageRequest{})
+		if !reflect.DeepEqual(pages[0], tt.want) {
+			t.Errorf("filter %+v = %v, want %v", tt.filter, pages[0], tt.want)
+		}
+	}
+}
+
+func TestBulkNotificationManagement(t *testing.T) {
+	s := newTestNotificationService(t)
+	seedNotifications(t, s, "alice", 6)
+	if err := s.CreateNotification("bob", "mention", "x", "ref"); err != nil {
+		t.Fatal(err)
+	}
+
+	if count, err := s.GetUnreadCount("alice"); err != nil || count != 3 {
+		t.Fatalf("unread = %d, %v; want 3", count, err)
+	}
+
+	marked, err := s.MarkAllAsRead("alice", "new_follower")
+	if err != nil || marked != 0 {
+		t.Errorf("marking read new_follower notifications: %d, %v; want none to mark", marked, err)
+	}
+	marked, err = s.MarkAllAsRead("alice", "")
+	if err != nil || marked != 3 {
+		t.Errorf("marking all read: %d, %v; want 3", marked, err)
+	}
+	if count, _ := s.GetUnreadCount("bob"); count != 1 {
+		t.Errorf("bob has %d unread after alice marked hers, want 1", count)
+	}
+
+	if err := s.DeleteNotification("n1", "bob"); err != ErrNotificationNotFound {
+		t.Errorf("deleting another user's notification: %v", err)
+	}
+	if err := s.DeleteNotification("n1", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	deleted, err := s.DeleteAllNotifications("alice")
+	if err != nil || deleted != 5 {
+		t.Errorf("deleting all: %d, %v; want 5", deleted, err)
+	}
+	if pages := notificationPages(t, s, NotificationFilter{}, PageRequest{}); len(pages[0]) != 0 {
+		t.Errorf("notifications left: %v", pages)
+	}
+	if count, _ := s.GetUnreadCount("bob"); count != 1 {
+		t.Errorf("bob's notifications were deleted too")
+	}
+}
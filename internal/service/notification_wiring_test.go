@@ -0,0 +1,170 @@
+package service
+
+import (
+	"database/sql"
+	"social-network/internal/realtime"
+	"testing"
+)
+
+// notificationWorld holds public users alice, bob, carol and dave and the
+// private erin, alice's public post "post", and the hikers group run by alice
+// with bob and carol as members and a post by alice.
+type notificationWorld struct {
+	db            *sql.DB
+	notifications *NotificationService
+	posts         *PostService
+	groups        *GroupService
+	followers     *FollowerService
+}
+
+func newNotificationWorld(t *testing.T) *notificationWorld {
+	t.Helper()
+	db := newTestDB(t)
+	for _, id := range []string{"alice", "bob", "carol", "dave"} {
+		seedUser(t, db, id, true)
+	}
+	seedUser(t, db, "erin", false)
+	seedGroup(t, db, "hikers", "alice", "bob", "carol")
+	_, err := db.Exec(`
+        INSERT INTO posts (id, user_id, content, privacy) VALUES ('post', 'alice', 'x', 'public');
+        INSERT INTO group_posts (id, group_id, user_id, content) VALUES ('group-post', 'hikers', 'alice', 'x');`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	notifications := NewNotificationService(db, realtime.NewHub(realtime.DefaultConfig))
+	return &notificationWorld{
+		db:            db,
+		notifications: notifications,
+		posts:         NewPostService(db, notifications),
+		groups:        NewGroupService(db, notifications),
+		followers:     NewFollowerService(db, notifications),
+	}
+}
+
+// expectNotified checks that the action sent exactly one notification of
+// type typ to recipient, and none of that type to actor.
+func (w *notificationWorld) expectNotified(t *testing.T, typ, recipient, actor string, action func() error) {
+	t.Helper()
+	before := storedTypes(t, w.notifications, recipient)[typ]
+	if err := action(); err != nil {
+		t.Fatal(err)
+	}
+	if got := storedTypes(t, w.notifications, recipient)[typ] - before; got != 1 {
+		t.Errorf("%s got %d new %s notifications, want 1", recipient, got, typ)
+	}
+	if got := storedTypes(t, w.notifications, actor)[typ]; got != 0 {
+		t.Errorf("actor %s got %d %s notifications, want none", actor, got, typ)
+	}
+}
+
+func TestFollowNotifications(t *testing.T) {
+	w := newNotificationWorld(t)
+
+	w.expectNotified(t, "new_follower", "alice", "bob", func() error {
+		_, err := w.followers.SendFollowRequest("bob", "alice")
+		return err
+	})
+
+	request, err := w.followers.SendFollowRequest("bob", "erin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.expectNotified(t, "follow_accepted", "bob", "erin", func() error {
+		return w.followers.RespondToRequest(request.ID, "erin", true)
+	})
+	if got := storedTypes(t, w.notifications, "erin")["new_follower"]; got != 0 {
+		t.Errorf("accepting a request sent erin %d new_follower notifications", got)
+	}
+}
+
+func TestGroupRequestAcceptedNotification(t *testing.T) {
+	w := newNotificationWorld(t)
+
+	if err := w.groups.RequestToJoinGroup("hikers", "dave"); err != nil {
+		t.Fatal(err)
+	}
+	w.expectNotified(t, "group_request_accepted", "dave", "alice", func() error {
+		return w.groups.RespondToJoinRequest("hikers", "dave", "alice", true)
+	})
+}
+
+func TestCommentNotifications(t *testing.T) {
+	w := newNotificationWorld(t)
+
+	var comment string
+	w.expectNotified(t, "post_comment", "alice", "bob", func() error {
+		c, err := w.posts.CreateComment("post", "bob", "nice", nil, "")
+		if c != nil {
+			comment = c.ID
+		}
+		return err
+	})
+	w.expectNotified(t, "comment_reply", "bob", "carol", func() error {
+		_, err := w.posts.CreateComment("post", "carol", "agreed", nil, comment)
+		return err
+	})
+
+	var groupComment string
+	w.expectNotified(t, "post_comment", "alice", "bob", func() error {
+		c, err := w.groups.CreatePostComment("group-post", "bob", "nice", "")
+		if c != nil {
+			groupComment = c.ID
+		}
+		return err
+	})
+	w.expectNotified(t, "comment_reply", "bob", "carol", func() error {
+		_, err := w.groups.CreatePostComment("group-post", "carol", "agreed", groupComment)
+		return err
+	})
+}
+
+func TestMentionNotifications(t *testing.T) {
+	w := newNotificationWorld(t)
+
+	var comment string
+	w.expectNotified(t, "mention", "carol", "bob", func() error {
+		c, err := w.posts.CreateComment("post", "bob", "@carol @bob look", nil, "")
+		if c != nil {
+			comment = c.ID
+		}
+		return err
+	})
+
+	// Editing notifies newly mentioned users only, even after a mention is
+	// dropped and added back
+	w.expectNotified(t, "mention", "dave", "bob", func() error {
+		_, err := w.posts.EditComment(comment, "bob", "@carol @dave look")
+		return err
+	})
+	for _, content := range []string{"look", "@carol @dave look again"} {
+		if _, err := w.posts.EditComment(comment, "bob", content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, userID := range []string{"carol", "dave"} {
+		if got := storedTypes(t, w.notifications, userID)["mention"]; got != 1 {
+			t.Errorf("%s got %d mention notifications, want 1", userID, got)
+		}
+	}
+
+	var groupComment string
+	w.expectNotified(t, "mention", "carol", "bob", func() error {
+		c, err := w.groups.CreatePostComment("group-post", "bob", "@carol look", "")
+		if c != nil {
+			groupComment = c.ID
+		}
+		return err
+	})
+	// dave isn't a member, so the comment isn't visible to dave
+	_, err := w.groups.EditPostComment(groupComment, "bob", "@carol @dave @alice look")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := storedTypes(t, w.notifications, "dave")["mention"]; got != 1 {
+		t.Errorf("non-member dave got %d mention notifications, want only the earlier 1", got)
+	}
+	if got := storedTypes(t, w.notifications, "alice")["mention"]; got != 1 {
+		t.Errorf("alice got %d mention notifications from the edit, want 1", got)
+	}
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"encoding/base64"
+	"errors"
+	"social-network/internal/model"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultPageLimit = 50
+	MaxPageLimit     = 100
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// PageRequest selects a window of a chronologically ordered list. Without a
+// cursor the most recent items are returned. Before pages towards older items
+// and After towards newer ones; they cannot be combined.
+type PageRequest struct {
+	Before string
+	After  string
+	Limit  int
+}
+
+func (p PageRequest) limit() int {
+	if p.Limit <= 0 {
+		return DefaultPageLimit
+	}
+	if p.Limit > MaxPageLimit {
+		return MaxPageLimit
+	}
+	return p.Limit
+}
+
+// cursorQuery appends the cursor condition, ordering and limit for the page
+// to a query over a table with created_at and id columns. The cursor holds
+// both, so the page continues from its position even after the item it was
+// taken from has been deleted. One row more than the limit is requested so
+// the caller can tell whether another page exists. It also reports whether
+// rows come back newest first.
+func (p PageRequest) cursorQuery(query string, args []interface{}) (string, []interface{}, bool, error) {
+	if p.Before != "" && p.After != "" {
+		return "", nil, false, ErrInvalidCursor
+	}
+
+	descending := p.After == ""
+	cursor, op := p.Before, "<"
+	if !descending {
+		cursor, op = p.After, ">"
+	}
+
+	if cursor != "" {
+		key, err := decodeCursor(cursor)
+		if err != nil {
+			return "", nil, false, err
+		}
+
+		query += `
+        AND (created_at, id) ` + op + ` (?, ?)`
+		args = append(args, key.CreatedAt, key.ID)
+	}
+
+	if descending {
+		query += `
+        ORDER BY created_at DESC, id DESC`
+	} else {
+		query += `
+        ORDER BY created_at ASC, id ASC`
+	}
+	query += `
+        LIMIT ?`
+	args = append(args, p.limit()+1)
+
+	return query, args, descending, nil
+}
+
+// pageOrder is the order in which a page is returned.
+type pageOrder int
+
+const (
+	oldestFirst pageOrder = iota // chronological, as chat histories are shown
+	newestFirst                  // as feeds and other listings are shown
+)
+
+// paginate trims items fetched by cursorQuery to the page limit, puts them in
+// order and returns the cursor of the next page, if any. The next page
+// continues from the last item fetched, whichever way the page went.
+func paginate[T any](p PageRequest, items []T, descending bool, order pageOrder, key func(T) pageKey) ([]T, string) {
+	nextCursor := ""
+	if len(items) > p.limit() {
+		items = items[:p.limit()]
+		nextCursor = encodeCursor(key(items[len(items)-1]))
+	}
+
+	if descending != (order == newestFirst) {
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+		}
+	}
+	return items, nextCursor
+}
+
+// pageKey is the position of an item in a list ordered by creation time,
+// with ties broken by ID.
+type pageKey struct {
+	CreatedAt time.Time
+	ID        string
+}
+
+// Item keys for paginate.
+func messageKey(m Message) pageKey            { return pageKey{m.CreatedAt, m.ID} }
+func feedItemKey(item model.FeedItem) pageKey { return pageKey{item.CreatedAt, item.ID} }
+
+// rowKey returns the key of a row with its creation time under createdAt.
+func rowKey(createdAt string) func(row map[string]interface{}) pageKey {
+	return func(row map[string]interface{}) pageKey {
+		return pageKey{row[createdAt].(time.Time), row["id"].(string)}
+	}
+}
+
+// The creation time keeps its offset so that it binds to the same text the
+// driver stored.
+func encodeCursor(key pageKey) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(key.CreatedAt.Format(time.RFC3339Nano) + " " + key.ID))
+}
+
+func decodeCursor(cursor string) (pageKey, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return pageKey{}, ErrInvalidCursor
+	}
+	createdAt, id, ok := strings.Cut(string(decoded), " ")
+	if !ok || id == "" {
+		return pageKey{}, ErrInvalidCursor
+	}
+	t, err := time.Parse(time.RFC3339Nano, createdAt)
+	if err != nil {
+		return pageKey{}, ErrInvalidCursor
+	}
+	return pageKey{t, id}, nil
+}
@@ -0,0 +1,222 @@
+package service
+
+import (
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// seedMessages inserts n private messages alternating between alice and bob,
+// m1 oldest. m3 and m4 share a timestamp so ties are broken by ID.
+func seedMessages(t *testing.T, s *ChatService, n int) {
+	t.Helper()
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i := 1; i <= n; i++ {
+		sender, recipient := "alice", "bob"
+		if i%2 == 0 {
+			sender, recipient = recipient, sender
+		}
+		created := start.Add(time.Duration(i) * time.Minute)
+		if i == 4 {
+			created = start.Add(3 * time.Minute)
+		}
+		_, err := s.db.Exec(`
+            INSERT INTO messages (id, sender_id, recipient_id, content, created_at, is_read)
+            VALUES (?, ?, ?, 'hi', ?, false)`,
+			fmt.Sprintf("m%d", i), sender, recipient, created)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func messageIDs(messages []Message) string {
+	ids := make([]string, len(messages))
+	for i, msg := range messages {
+		ids[i] = msg.ID
+	}
+	return strings.Join(ids, " ")
+}
+
+func TestPrivateHistoryPagesAcrossBoundaries(t *testing.T) {
+	s := newTestChatService(t)
+	seedUser(t, s.db, "alice", true)
+	seedUser(t, s.db, "bob", true)
+	seedMessages(t, s, 7)
+
+	// Paging back from the latest messages, each page in chronological order
+	var pages []string
+	page := PageRequest{Limit: 3}
+	for {
+		result, err := s.GetPrivateTimeline("alice", "bob", page)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages = append(pages, messageIDs(result.Messages))
+		if result.NextCursor == "" {
+			break
+		}
+		page.Before = result.NextCursor
+	}
+	want := []string{"m5 m6 m7", "m2 m3 m4", "m1"}
+	if !reflect.DeepEqual(pages, want) {
+		t.Fatalf("pages before = %q, want %q", pages, want)
+	}
+
+	// And forward again from the oldest message
+	pages = nil
+	page = PageRequest{After: encodeCursor(pageKey{time.Date(2024, 1, 1, 12, 1, 0, 0, time.UTC), "m1"}), Limit: 3}
+	for {
+		result, err := s.GetPrivateTimeline("bob", "alice", page)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages = append(pages, messageIDs(result.Messages))
+		if result.NextCursor == "" {
+			break
+		}
+		page.After = result.NextCursor
+	}
+	want = []string{"m2 m3 m4", "m5 m6 m7"}
+	if !reflect.DeepEqual(pages, want) {
+		t.Fatalf("pages after = %q, want %q", pages, want)
+	}
+}
+
+func TestGroupHistoryPagesAcrossBoundaries(t *testing.T) {
+	s := newTestChatService(t)
+	seedUser(t, s.db, "alice", true)
+	seedUser(t, s.db, "bob", true)
+	seedGroup(t, s.db, "hikers", "alice", "bob")
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i := 1; i <= 5; i++ {
+		_, err := s.db.Exec(`
+            INSERT INTO group_messages (id, group_id, sender_id, content, created_at)
+            VALUES (?, 'hikers', 'alice', 'hi', ?)`,
+			fmt.Sprintf("g%d", i), start.Add(time.Duration(i)*time.Minute))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	first, err := s.GetGroupMessageHistory("hikers", "bob", PageRequest{Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := s.GetGroupMessageHistory("hikers", "bob", PageRequest{Before: first.NextCursor, Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	third, err := s.GetGroupMessageHistory("hikers", "bob", PageRequest{Before: second.NextCursor, Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{messageIDs(first.Messages), messageIDs(second.Messages), messageIDs(third.Messages)}
+	if want := []string{"g4 g5", "g2 g3", "g1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("pages = %q, want %q", got, want)
+	}
+	if third.NextCursor != "" {
+		t.Errorf("last page has a next cursor")
+	}
+}
+
+// TestPagingPastADeletedCursorItem deletes the message a cursor was taken
+// from between two pages: the next page still continues from its position.
+func TestPagingPastADeletedCursorItem(t *testing.T) {
+	s := newTestChatService(t)
+	seedUser(t, s.db, "alice", true)
+	seedUser(t, s.db, "bob", true)
+	seedMessages(t, s, 7)
+
+	first, err := s.GetPrivateTimeline("alice", "bob", PageRequest{Limit: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := messageIDs(first.Messages); got != "m5 m6 m7" {
+		t.Fatalf("first page = %q, want m5 m6 m7", got)
+	}
+	if _, err := s.db.Exec(`DELETE FROM messages WHERE id = 'm5'`); err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := s.GetPrivateTimeline("alice", "bob", PageRequest{Before: first.NextCursor, Limit: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := messageIDs(second.Messages); got != "m2 m3 m4" {
+		t.Errorf("second page = %q, want m2 m3 m4", got)
+	}
+}
+
+func TestInvalidCursors(t *testing.T) {
+	s := newTestChatService(t)
+	seedUser(t, s.db, "alice", true)
+	seedUser(t, s.db, "bob", true)
+	seedMessages(t, s, 2)
+
+	key := pageKey{time.Date(2024, 1, 1, 12, 1, 0, 0, time.UTC), "m1"}
+	for name, page := range map[string]PageRequest{
+		"not base64":     {Before: "%%%"},
+		"no id":          {Before: base64.RawURLEncoding.EncodeToString([]byte("2024-01-01T12:01:00Z"))},
+		"bad time":       {Before: base64.RawURLEncoding.EncodeToString([]byte("yesterday m1"))},
+		"both cursors":   {Before: encodeCursor(key), After: encodeCursor(key)},
+		"empty decoding": {After: "="},
+	} {
+		if _, err := s.GetPrivateTimeline("alice", "bob", page); err != ErrInvalidCursor {
+			t.Errorf("%s: err = %v, want ErrInvalidCursor", name, err)
+		}
+	}
+}
+
+func TestPageLimits(t *testing.T) {
+	for limit, want := range map[int]int{0: DefaultPageLimit, -1: DefaultPageLimit, 10: 10, 1000: MaxPageLimit} {
+		if got := (PageRequest{Limit: limit}).limit(); got != want {
+			t.Errorf("limit %d = %d, want %d", limit, got, want)
+		}
+	}
+}
+
+func TestPaginateOrders(t *testing.T) {
+	created := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	key := func(s string) pageKey { return pageKey{created, s} }
+	page := PageRequest{Limit: 2}
+	tests := []struct {
+		fetched    []string
+		descending bool
+		order      pageOrder
+		want       []string
+		next       string
+	}{
+		{[]string{"c", "b", "a"}, true, oldestFirst, []string{"b", "c"}, "b"},
+		{[]string{"c", "b", "a"}, true, newestFirst, []string{"c", "b"}, "b"},
+		{[]string{"a", "b", "c"}, false, oldestFirst, []string{"a", "b"}, "b"},
+		{[]string{"a", "b", "c"}, false, newestFirst, []string{"b", "a"}, "b"},
+		{[]string{"b", "a"}, true, newestFirst, []string{"b", "a"}, ""},
+	}
+	for _, tt := range tests {
+		fetched := append([]string(nil), tt.fetched...)
+		got, next := paginate(page, fetched, tt.descending, tt.order, key)
+		wantNext := ""
+		if tt.next != "" {
+			wantNext = encodeCursor(key(tt.next))
+		}
+		if !reflect.DeepEqual(got, tt.want) || next != wantNext {
+			t.Errorf("paginate(%v, descending %v, order %v) = %v, %q; want %v, %q",
+				tt.fetched, tt.descending, tt.order, got, next, tt.want, wantNext)
+		}
+	}
+}
+
+func TestCursorsRoundTrip(t *testing.T) {
+	key := pageKey{time.Date(2024, 1, 1, 12, 0, 0, 123456789, time.FixedZone("", 2*60*60)), "a b"}
+	got, err := decodeCursor(encodeCursor(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.CreatedAt.Equal(key.CreatedAt) || got.CreatedAt.Format(time.RFC3339Nano) != key.CreatedAt.Format(time.RFC3339Nano) || got.ID != key.ID {
+		t.Errorf("decodeCursor(encodeCursor(%v)) = %v", key, got)
+	}
+}
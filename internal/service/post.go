@@ -3,18 +3,25 @@ package service
 import (
 	"database/sql"
 	"errors"
+	"fmt"
+	"log"
 	"social-network/internal/model"
+	"social-network/internal/notification"
 	"time"
 
 	"github.com/google/uuid"
 )
 
 type PostService struct {
-	db *sql.DB
+	db                  *sql.DB
+	notificationService notification.Service
 }
 
-func NewPostService(db *sql.DB) *PostService {
-	return &PostService{db: db}
+func NewPostService(db *sql.DB, notificationService notification.Service) *PostService {
+	return &PostService{
+		db:                  db,
+		notificationService: notificationService,
+	}
 }
 
 func (s *PostService) CreatePost(userID string, input model.CreatePostInput) (*model.Post, error) {
@@ -58,36 +65,27 @@ func (s *PostService) CreatePost(userID string, input model.CreatePostInput) (*m
 		return nil, err
 	}
 
+	notifyMentions(s.db, s.notificationService, userID, post.Content, "post", post.ID,
+		s.canViewPost(post.ID), map[string]bool{})
+
 	return post, nil
 }
 
 func (s *PostService) GetPost(postID string, requestingUserID string) (*model.Post, error) {
+	if err := newPostVisibility(requestingUserID).check(s.db, postID); err != nil {
+		return nil, err
+	}
+
 	post := &model.Post{}
-	query := `SELECT p.*, 
-              CASE 
-                WHEN p.privacy = 'public' THEN 1
-                WHEN p.privacy = 'private' AND p.user_id = ? THEN 1
-                WHEN p.privacy = 'almost_private' AND (p.user_id = ? OR EXISTS (
-                    SELECT 1 FROM post_viewers pv WHERE pv.post_id = p.id AND pv.user_id = ?
-                )) THEN 1
-                ELSE 0 
-              END as can_view
-              FROM posts p WHERE p.id = ?`
-
-	var canView bool
-	err := s.db.QueryRow(query, requestingUserID, requestingUserID, requestingUserID, postID).
+	err := s.db.QueryRow(`SELECT * FROM posts WHERE id = ?`, postID).
 		Scan(&post.ID, &post.UserID, &post.Content, &post.ImagePath, &post.Privacy,
-			&post.CreatedAt, &post.UpdatedAt, &canView)
-
+			&post.CreatedAt, &post.UpdatedAt)
 	if err == sql.ErrNoRows {
-		return nil, errors.New("post not found")
+		return nil, ErrPostNotFound
 	}
 	if err != nil {
 		return nil, err
 	}
-	if !canView {
-		return nil, errors.New("unauthorized to view this post")
-	}
 
 	// Fetch comments for the post
 	comments, err := s.getPostComments(post.ID)
@@ -171,6 +169,25 @@ func (s *PostService) DeletePost(postID string, userID string) error {
 	}
 	defer tx.Rollback()
 
+	// Reactions reference their target without a foreign key
+	if _, err := tx.Exec(`
+        DELETE FROM reactions
+        WHERE (target_type = ? AND target_id = ?)
+        OR (target_type = ? AND target_id IN (SELECT id FROM post_comments WHERE post_id = ?))`,
+		model.ReactionTargetPost, postID, model.ReactionTargetComment, postID,
+	); err != nil {
+		return err
+	}
+
+	// Neither do the earlier versions of its comments
+	if _, err := tx.Exec(`
+        DELETE FROM comment_edits
+        WHERE comment_type = 'post' AND comment_id IN (SELECT id FROM post_comments WHERE post_id = ?)`,
+		postID,
+	); err != nil {
+		return err
+	}
+
 	// Delete comments first (if using ON DELETE CASCADE, this isn't necessary)
 	_, err = tx.Exec(`DELETE FROM post_comments WHERE post_id = ?`, postID)
 	if err != nil {
@@ -192,31 +209,31 @@ func (s *PostService) DeletePost(postID string, userID string) error {
 	return tx.Commit()
 }
 
+// GetPublicPosts returns every post the user may see, newest first, with a
+// preview of their latest comments. The number of queries does not depend on
+// the number of posts.
 func (s *PostService) GetPublicPosts(requestingUserID string) ([]map[string]interface{}, error) {
-	query := `
-        SELECT p.*,
-        CASE
-            WHEN p.privacy = 'public' THEN 1
-            WHEN p.privacy = 'private' AND p.user_id = ? THEN 1
-            WHEN p.privacy = 'almost_private' AND (p.user_id = ? OR EXISTS (
-                SELECT 1 FROM post_viewers pv WHERE pv.post_id = p.id AND pv.user_id = ?
-            )) THEN 1
-            ELSE 0
-        END as can_view
+	visible, args := newPostVisibility(requestingUserID).condition("p")
+	rows, err := s.db.Query(`
+        SELECT p.id, p.user_id, p.content, p.image_path, p.privacy, p.created_at, p.updated_at,
+               COALESCE(u.first_name, 'Unknown'), COALESCE(u.last_name, 'User'), COALESCE(u.avatar, ''),
+               (SELECT COUNT(*) FROM post_comments c WHERE c.post_id = p.id AND c.deleted_at IS NULL)
         FROM posts p
-        WHERE can_view = 1
-        ORDER BY p.created_at DESC
-    `
-	rows, err := s.db.Query(query, requestingUserID, requestingUserID, requestingUserID)
+        LEFT JOIN users u ON u.id = p.user_id
+        WHERE `+visible+`
+        ORDER BY p.created_at DESC`,
+		args...,
+	)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var postsWithUserInfo []map[string]interface{}
+	postsWithUserInfo := []map[string]interface{}{}
 	for rows.Next() {
 		post := &model.Post{}
-		var canView int
+		var firstName, lastName, avatar string
+		var commentCount int
 		err := rows.Scan(
 			&post.ID,
 			&post.UserID,
@@ -225,78 +242,62 @@ func (s *PostService) GetPublicPosts(requestingUserID string) ([]map[string]inte
 			&post.Privacy,
 			&post.CreatedAt,
 			&post.UpdatedAt,
-			&canView,
+			&firstName,
+			&lastName,
+			&avatar,
+			&commentCount,
 		)
 		if err != nil {
 			return nil, err
 		}
 
-		// Fetch user information
-		userQuery := `
-            SELECT first_name, last_name, COALESCE(avatar, '') as avatar
-            FROM users
-            WHERE id = ?
-        `
-		var firstName, lastName, avatar sql.NullString
-		err = s.db.QueryRow(userQuery, post.UserID).Scan(&firstName, &lastName, &avatar)
-		if err != nil {
-			if err == sql.ErrNoRows {
-				firstName = sql.NullString{String: "Unknown", Valid: true}
-				lastName = sql.NullString{String: "User", Valid: true}
-				avatar = sql.NullString{String: "", Valid: true}
-			} else {
-				return nil, err
-			}
-		}
-
-		// Get the actual string values, using defaults for NULL values
-		firstNameStr := "Unknown"
-		if firstName.Valid {
-			firstNameStr = firstName.String
-		}
-
-		lastNameStr := "User"
-		if lastName.Valid {
-			lastNameStr = lastName.String
-		}
-
-		avatarStr := ""
-		if avatar.Valid {
-			avatarStr = avatar.String
-		}
-
-		// Fetch comments using the updated GetPostComments
-		comments, err := s.GetPostComments(post.ID, requestingUserID)
-		if err != nil {
-			return nil, err
-		}
-
-		// Combine post and user data
 		postWithUser := map[string]interface{}{
-			"id":         post.ID,
-			"user_id":    post.UserID,
-			"first_name": firstNameStr, // Changed from "first_Name" to "first_name"
-			"last_name":  lastNameStr,  // Changed from "last_Name" to "last_name"
-			"avatar":     avatarStr,
-			"content":    post.Content,
-			"image_path": post.ImagePath, // Changed from "imagePath" to "image_path"
-			"privacy":    post.Privacy,
-			"created_at": post.CreatedAt,
-			"updated_at": post.UpdatedAt,
-			"comments":   comments, // Now a slice of maps with user info
+			"id":            post.ID,
+			"user_id":       post.UserID,
+			"first_name":    firstName,
+			"last_name":     lastName,
+			"avatar":        avatar,
+			"content":       post.Content,
+			"image_path":    post.ImagePath,
+			"privacy":       post.Privacy,
+			"created_at":    post.CreatedAt,
+			"updated_at":    post.UpdatedAt,
+			"comment_count": commentCount,
 		}
 		postsWithUserInfo = append(postsWithUserInfo, postWithUser)
 	}
-
 	if err := rows.Err(); err != nil {
 		return nil, err
 	}
 
+	if len(postsWithUserInfo) == 0 {
+		return postsWithUserInfo, nil
+	}
+
+	// The previews and reactions select the listed posts again rather than
+	// binding their IDs, which keeps the number of queries constant.
+	visiblePosts := "SELECT p.id FROM posts p WHERE " + visible
+	previews, err := s.loadCommentPreviews(visiblePosts, args, requestingUserID)
+	if err != nil {
+		return nil, err
+	}
+	for _, post := range postsWithUserInfo {
+		post["comments"] = commentPreview(previews, post["id"].(string))
+	}
+
+	reactions, err := loadReactionSummariesIn(s.db, model.ReactionTargetPost, visiblePosts, args, requestingUserID)
+	if err != nil {
+		return nil, err
+	}
+	setReactions(postsWithUserInfo, reactions)
+
 	return postsWithUserInfo, nil
 }
 
-func (s *PostService) CreateComment(postID string, userID string, content string, imagePath *string) (*model.PostComment, error) {
-	_, err := s.GetPost(postID, userID)
+// CreateComment comments on a post, or replies to one of its comments when
+// parentCommentID is set.
+func (s *PostService) CreateComment(postID string, userID string, content string, imagePath *string, parentCommentID string) (*model.PostComment, error) {
+	post, err := s.GetPost(postID, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -311,16 +312,48 @@ func (s *PostService) CreateComment(postID string, userID string, content string
 		UpdatedAt: time.Now(),
 	}
 
+	var parentAuthorID string
+	if parentCommentID != "" {
+		var parentPostID string
+		var deletedAt sql.NullTime
+		err := s.db.QueryRow(`
+            SELECT post_id, user_id, deleted_at FROM post_comments WHERE id = ?`,
+			parentCommentID,
+		).Scan(&parentPostID, &parentAuthorID, &deletedAt)
+		if err == sql.ErrNoRows || (err == nil && (parentPostID != postID || deletedAt.Valid)) {
+			return nil, ErrParentCommentNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		comment.ParentCommentID = &parentCommentID
+	}
+
 	_, err = s.db.Exec(`
-        INSERT INTO post_comments (id, post_id, user_id, content, image_path, created_at, updated_at)
-        VALUES (?, ?, ?, ?, ?, ?, ?)`,
-		comment.ID, comment.PostID, comment.UserID,
+        INSERT INTO post_comments (id, post_id, user_id, parent_comment_id, content, image_path, created_at, updated_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		comment.ID, comment.PostID, comment.UserID, comment.ParentCommentID,
 		comment.Content, comment.ImagePath, comment.CreatedAt, comment.UpdatedAt,
 	)
 	if err != nil {
 		return nil, err
 	}
 
+	// Each user hears about a comment once, the most specific way
+	notified := map[string]bool{userID: true}
+	if parentAuthorID != "" && !notified[parentAuthorID] {
+		s.notify(parentAuthorID, "comment_reply",
+			fmt.Sprintf("%s replied to your comment", userFullName(s.db, userID)), postID)
+		notified[parentAuthorID] = true
+	}
+	if !notified[post.UserID] {
+		s.notify(post.UserID, "post_comment",
+			fmt.Sprintf("%s commented on your post", userFullName(s.db, userID)), postID)
+		notified[post.UserID] = true
+	}
+	notifyMentions(s.db, s.notificationService, userID, content, "comment", postID,
+		s.canViewPost(postID), notified)
+
 	err = s.db.QueryRow(`
         SELECT nickname, avatar FROM users WHERE id = ?`,
 		userID,
@@ -332,61 +365,257 @@ func (s *PostService) CreateComment(postID string, userID string, content string
 	return comment, nil
 }
 
+func (s *PostService) notify(recipientID, notificationType, content, postID string) {
+	err := s.notificationService.CreateNotification(recipientID, notificationType, content, postID)
+	if err != nil {
+		log.Printf("Error creating %s notification: %v", notificationType, err)
+	}
+}
+
+// canViewPost reports whether a user may see a post, for mentions.
+func (s *PostService) canViewPost(postID string) func(userID string) bool {
+	return func(userID string) bool {
+		return newPostVisibility(userID).check(s.db, postID) == nil
+	}
+}
+
 func (s *PostService) GetComment(commentID string) (*model.PostComment, error) {
 	comment := &model.PostComment{}
+	var editedAt, deletedAt sql.NullTime
+	var nickname, avatar sql.NullString
 	err := s.db.QueryRow(`
-        SELECT c.id, c.post_id, c.user_id, c.content, c.image_path, 
-               c.created_at, c.updated_at, u.nickname, u.avatar
+        SELECT c.id, c.post_id, c.user_id, c.parent_comment_id, c.content, c.image_path, 
+               c.created_at, c.updated_at, c.edited_at, c.deleted_at, u.nickname, u.avatar
         FROM post_comments c
         LEFT JOIN users u ON c.user_id = u.id
         WHERE c.id = ?`,
 		commentID,
 	).Scan(
-		&comment.ID, &comment.PostID, &comment.UserID, &comment.Content,
-		&comment.ImagePath, &comment.CreatedAt, &comment.UpdatedAt,
-		&comment.UserNickname, &comment.UserAvatar,
+		&comment.ID, &comment.PostID, &comment.UserID, &comment.ParentCommentID, &comment.Content,
+		&comment.ImagePath, &comment.CreatedAt, &comment.UpdatedAt, &editedAt, &deletedAt,
+		&nickname, &avatar,
 	)
 
 	if err == sql.ErrNoRows {
-		return nil, errors.New("comment not found")
+		return nil, ErrCommentNotFound
 	}
 	if err != nil {
 		return nil, err
 	}
 
+	comment.UserNickname = nickname.String
 	if comment.UserNickname == "" {
 		comment.UserNickname = "Unknown User"
 	}
+	comment.UserAvatar = avatar.String
+	comment.EditedAt = nullTime(editedAt)
+	comment.Edited = editedAt.Valid
+	if deletedAt.Valid {
+		comment.Deleted = true
+		comment.Content = removedCommentContent
+	}
 
 	return comment, nil
 }
 
-func (s *PostService) GetPostComments(postID string, userID string) ([]map[string]interface{}, error) {
+// GetPostComments returns the comments of a post as a tree of replies.
+func (s *PostService) GetPostComments(postID string, userID string, opts CommentThreadOptions) ([]map[string]interface{}, error) {
 	// Verify the post exists and the user has access
-	_, err := s.GetPost(postID, userID)
-	if err != nil {
+	if err := newPostVisibility(userID).check(s.db, postID); err != nil {
 		return nil, err
 	}
 
-	// Updated SQL Query to include first_name and last_name
 	rows, err := s.db.Query(`
-        SELECT 
+        SELECT `+commentMapColumns+`
+        FROM post_comments pc
+        LEFT JOIN users u ON pc.user_id = u.id
+        WHERE pc.post_id = ?
+        ORDER BY pc.created_at ASC`,
+		postID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var comments []map[string]interface{}
+	for rows.Next() {
+		comment, err := scanCommentMap(rows)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, comment)
+	}
+
+	// Check for errors from iterating over rows
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := attachReactions(s.db, model.ReactionTargetComment, comments, userID); err != nil {
+		return nil, err
+	}
+
+	return commentTree(comments, opts)
+}
+
+// commentMapColumns are the columns scanCommentMap reads, from post_comments
+// aliased as pc joined with users aliased as u.
+const commentMapColumns = `
             pc.id, 
             pc.post_id, 
             pc.user_id, 
+            pc.parent_comment_id,
             pc.content, 
             pc.image_path,
             pc.created_at, 
             pc.updated_at, 
+            pc.edited_at,
+            pc.deleted_at,
             u.nickname, 
             u.avatar,
             u.first_name,
-            u.last_name
-        FROM post_comments pc
+            u.last_name`
+
+// scanCommentMap reads a post comment with its author selected with
+// commentMapColumns. Columns selected after them are read into extra.
+func scanCommentMap(rows *sql.Rows, extra ...interface{}) (map[string]interface{}, error) {
+	var (
+		id         string
+		post_id    string
+		user_id    string
+		parent_id  sql.NullString
+		content    string
+		image_path sql.NullString
+		created_at time.Time
+		updated_at time.Time
+		edited_at  sql.NullTime
+		deleted_at sql.NullTime
+		nickname   sql.NullString
+		avatar     sql.NullString
+		firstName  sql.NullString
+		lastName   sql.NullString
+	)
+
+	err := rows.Scan(append([]interface{}{
+		&id,
+		&post_id,
+		&user_id,
+		&parent_id,
+		&content,
+		&image_path,
+		&created_at,
+		&updated_at,
+		&edited_at,
+		&deleted_at,
+		&nickname,
+		&avatar,
+		&firstName,
+		&lastName,
+	}, extra...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	comment := make(map[string]interface{})
+	comment["id"] = id
+	comment["post_id"] = post_id
+	comment["user_id"] = user_id
+	if parent_id.Valid {
+		comment["parent_comment_id"] = parent_id.String
+	} else {
+		comment["parent_comment_id"] = nil
+	}
+	comment["content"] = content
+	if image_path.Valid {
+		comment["image_path"] = image_path.String
+	} else {
+		comment["image_path"] = nil
+	}
+	comment["created_at"] = created_at
+	comment["updated_at"] = updated_at
+	comment["edited"] = edited_at.Valid
+	comment["edited_at"] = nullTime(edited_at)
+
+	// Handle nickname
+	if nickname.Valid {
+		comment["user_nickname"] = nickname.String
+	} else {
+		comment["user_nickname"] = "Unknown User"
+	}
+
+	// Handle avatar
+	if avatar.Valid {
+		comment["user_avatar"] = avatar.String
+	} else {
+		comment["user_avatar"] = "" // Or set to a default avatar URL
+	}
+
+	// Handle first_name
+	if firstName.Valid {
+		comment["first_name"] = firstName.String
+	} else {
+		comment["first_name"] = "Unknown"
+	}
+
+	// Handle last_name
+	if lastName.Valid {
+		comment["last_name"] = lastName.String
+	} else {
+		comment["last_name"] = "User"
+	}
+
+	// Removed comments only hold their replies together
+	comment["deleted"] = deleted_at.Valid
+	if deleted_at.Valid {
+		comment["content"] = removedCommentContent
+		comment["user_id"] = ""
+		comment["user_nickname"] = ""
+		comment["user_avatar"] = ""
+		comment["first_name"] = ""
+		comment["last_name"] = ""
+	}
+	return comment, nil
+}
+
+// CommentPreviewLimit is the number of latest top-level comments listed with
+// each post. The whole thread comes from GetPostComments.
+const CommentPreviewLimit = 3
+
+// previewCommentIDs selects the IDs of the comments loadCommentPreviews
+// lists for the posts selected by posts, the contents of an IN (...) clause.
+// It binds the arguments of posts followed by CommentPreviewLimit.
+func previewCommentIDs(posts string) string {
+	return `
+        SELECT id FROM (
+            SELECT c.id,
+                   ROW_NUMBER() OVER (PARTITION BY c.post_id ORDER BY c.created_at DESC, c.id DESC) AS position
+            FROM post_comments c
+            WHERE c.post_id IN (` + posts + `) AND c.parent_comment_id IS NULL
+        )
+        WHERE position <= ?`
+}
+
+// loadCommentPreviews returns the latest top-level comments of each post, in
+// chronological order and with their reply counts, for the posts selected by
+// posts, the contents of an IN (...) clause binding args. It takes one query
+// for the comments and one for their reactions however many posts there are.
+func (s *PostService) loadCommentPreviews(posts string, args []interface{}, viewerID string) (map[string][]map[string]interface{}, error) {
+	previewArgs := append(append([]interface{}{}, args...), CommentPreviewLimit)
+	rows, err := s.db.Query(`
+        SELECT `+commentMapColumns+`,
+            pc.reply_count
+        FROM (
+            SELECT c.*,
+                   (SELECT COUNT(*) FROM post_comments r WHERE r.parent_comment_id = c.id AND r.deleted_at IS NULL) AS reply_count,
+                   ROW_NUMBER() OVER (PARTITION BY c.post_id ORDER BY c.created_at DESC, c.id DESC) AS position
+            FROM post_comments c
+            WHERE c.post_id IN (`+posts+`) AND c.parent_comment_id IS NULL
+        ) pc
         LEFT JOIN users u ON pc.user_id = u.id
-        WHERE pc.post_id = ?
-        ORDER BY pc.created_at ASC`,
-		postID,
+        WHERE pc.position <= ?
+        ORDER BY pc.created_at ASC, pc.id ASC`,
+		previewArgs...,
 	)
 	if err != nil {
 		return nil, err
@@ -395,98 +624,59 @@ func (s *PostService) GetPostComments(postID string, userID string) ([]map[strin
 
 	var comments []map[string]interface{}
 	for rows.Next() {
-		var (
-			id         string
-			post_id    string
-			user_id    string
-			content    string
-			image_path sql.NullString
-			created_at time.Time
-			updated_at time.Time
-			nickname   sql.NullString
-			avatar     sql.NullString
-			firstName  sql.NullString
-			lastName   sql.NullString
-		)
-
-		err := rows.Scan(
-			&id,
-			&post_id,
-			&user_id,
-			&content,
-			&image_path,
-			&created_at,
-			&updated_at,
-			&nickname,
-			&avatar,
-			&firstName,
-			&lastName,
-		)
+		var replyCount int
+		comment, err := scanCommentMap(rows, &replyCount)
 		if err != nil {
 			return nil, err
 		}
-
-		comment := make(map[string]interface{})
-		comment["id"] = id
-		comment["post_id"] = post_id
-		comment["user_id"] = user_id
-		comment["content"] = content
-		if image_path.Valid {
-			comment["image_path"] = image_path.String
-		} else {
-			comment["image_path"] = nil
-		}
-		comment["created_at"] = created_at
-		comment["updated_at"] = updated_at
-
-		// Handle nickname
-		if nickname.Valid {
-			comment["user_nickname"] = nickname.String
-		} else {
-			comment["user_nickname"] = "Unknown User"
-		}
-
-		// Handle avatar
-		if avatar.Valid {
-			comment["user_avatar"] = avatar.String
-		} else {
-			comment["user_avatar"] = "" // Or set to a default avatar URL
-		}
-
-		// Handle first_name
-		if firstName.Valid {
-			comment["first_name"] = firstName.String
-		} else {
-			comment["first_name"] = "Unknown"
-		}
-
-		// Handle last_name
-		if lastName.Valid {
-			comment["last_name"] = lastName.String
-		} else {
-			comment["last_name"] = "User"
-		}
-
+		comment["depth"] = 0
+		comment["reply_count"] = replyCount
+		comment["replies"] = []map[string]interface{}{}
 		comments = append(comments, comment)
 	}
-
-	// Check for errors from iterating over rows
 	if err := rows.Err(); err != nil {
 		return nil, err
 	}
 
-	return comments, nil
+	previews := make(map[string][]map[string]interface{})
+	if len(comments) == 0 {
+		return previews, nil
+	}
+
+	reactions, err := loadReactionSummariesIn(s.db, model.ReactionTargetComment, previewCommentIDs(posts), previewArgs, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	setReactions(comments, reactions)
+
+	for _, comment := range comments {
+		postID := comment["post_id"].(string)
+		previews[postID] = append(previews[postID], comment)
+	}
+	return previews, nil
+}
+
+func commentPreview(previews map[string][]map[string]interface{}, postID string) []map[string]interface{} {
+	if comments, ok := previews[postID]; ok {
+		return comments
+	}
+	return []map[string]interface{}{}
 }
 
+// DeleteComment removes a comment. A comment with replies is kept as a
+// "comment removed" placeholder so the thread below it stays in place, and
+// placeholders are dropped once their last reply is gone.
 func (s *PostService) DeleteComment(commentID string, userID string) error {
 	var commentUserID string
+	var parentID sql.NullString
 	err := s.db.QueryRow(`
-        SELECT user_id FROM post_comments WHERE id = ?`,
+        SELECT user_id, parent_comment_id FROM post_comments
+        WHERE id = ? AND deleted_at IS NULL`,
 		commentID,
-	).Scan(&commentUserID)
+	).Scan(&commentUserID, &parentID)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return errors.New("comment not found")
+			return ErrCommentNotFound
 		}
 		return err
 	}
@@ -495,14 +685,71 @@ func (s *PostService) DeleteComment(commentID string, userID string) error {
 		return errors.New("unauthorized to delete this comment")
 	}
 
-	_, err = s.db.Exec(`DELETE FROM post_comments WHERE id = ?`, commentID)
-	return err
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+        DELETE FROM reactions WHERE target_type = ? AND target_id = ?`,
+		model.ReactionTargetComment, commentID,
+	); err != nil {
+		return err
+	}
+	// Earlier versions would still reveal the deleted content
+	if _, err := tx.Exec(`DELETE FROM comment_edits WHERE comment_id = ?`, commentID); err != nil {
+		return err
+	}
+
+	var replies int
+	err = tx.QueryRow(`SELECT COUNT(*) FROM post_comments WHERE parent_comment_id = ?`, commentID).Scan(&replies)
+	if err != nil {
+		return err
+	}
+	if replies > 0 {
+		_, err = tx.Exec(`
+            UPDATE post_comments
+            SET content = '', image_path = NULL, deleted_at = ?
+            WHERE id = ?`,
+			time.Now(), commentID,
+		)
+		if err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+
+	if _, err := tx.Exec(`DELETE FROM post_comments WHERE id = ?`, commentID); err != nil {
+		return err
+	}
+
+	for parentID.Valid {
+		id := parentID.String
+		err := tx.QueryRow(`
+            SELECT parent_comment_id FROM post_comments
+            WHERE id = ? AND deleted_at IS NOT NULL
+            AND NOT EXISTS (SELECT 1 FROM post_comments WHERE parent_comment_id = ?)`,
+			id, id,
+		).Scan(&parentID)
+		if err == sql.ErrNoRows {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`DELETE FROM post_comments WHERE id = ?`, id); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
 }
 
 func (s *PostService) getPostComments(postID string) ([]model.PostComment, error) {
 	rows, err := s.db.Query(`
-        SELECT pc.id, pc.post_id, pc.user_id, pc.content, pc.image_path, 
-               pc.created_at, pc.updated_at, u.nickname, u.avatar
+        SELECT pc.id, pc.post_id, pc.user_id, pc.parent_comment_id, pc.content, pc.image_path, 
+               pc.created_at, pc.updated_at, pc.edited_at, pc.deleted_at, u.nickname, u.avatar
         FROM post_comments pc
         LEFT JOIN users u ON pc.user_id = u.id
         WHERE pc.post_id = ?
@@ -518,14 +765,18 @@ func (s *PostService) getPostComments(postID string) ([]model.PostComment, error
 	for rows.Next() {
 		var comment model.PostComment
 		var nickname, avatar sql.NullString
+		var editedAt, deletedAt sql.NullTime
 		err := rows.Scan(
 			&comment.ID,
 			&comment.PostID,
 			&comment.UserID,
+			&comment.ParentCommentID,
 			&comment.Content,
 			&comment.ImagePath,
 			&comment.CreatedAt,
 			&comment.UpdatedAt,
+			&editedAt,
+			&deletedAt,
 			&nickname,
 			&avatar,
 		)
@@ -533,6 +784,15 @@ func (s *PostService) getPostComments(postID string) ([]model.PostComment, error
 			return nil, err
 		}
 
+		comment.EditedAt = nullTime(editedAt)
+		comment.Edited = editedAt.Valid
+		if deletedAt.Valid {
+			comment.Deleted = true
+			comment.Content = removedCommentContent
+			comment.UserID = ""
+			nickname, avatar = sql.NullString{Valid: true}, sql.NullString{}
+		}
+
 		comment.UserNickname = nickname.String
 		if !nickname.Valid {
 			comment.UserNickname = "Unknown User"
@@ -545,25 +805,41 @@ func (s *PostService) getPostComments(postID string) ([]model.PostComment, error
 	return comments, nil
 }
 
-func (s *PostService) GetUserPosts(userID string) ([]map[string]interface{}, error) {
+// UserPostsPage is one page of a user's posts, newest first.
+type UserPostsPage struct {
+	Posts      []map[string]interface{} `json:"posts"`
+	NextCursor string                   `json:"next_cursor,omitempty"`
+}
+
+// GetUserPosts returns a page of the posts of userID that viewerID may see,
+// with a preview of their latest comments.
+func (s *PostService) GetUserPosts(userID string, viewerID string, page PageRequest) (*UserPostsPage, error) {
+	visible, args := newPostVisibility(viewerID).condition("p")
+	// Authors are selected rather than joined so the cursor condition only
+	// sees posts columns
 	query := `
-        SELECT p.*, u.nickname, u.avatar
+        SELECT p.id, p.user_id, p.content, p.image_path, p.privacy, p.created_at, p.updated_at,
+               (SELECT nickname FROM users WHERE id = p.user_id),
+               (SELECT avatar FROM users WHERE id = p.user_id),
+               (SELECT COUNT(*) FROM post_comments c WHERE c.post_id = p.id AND c.deleted_at IS NULL)
         FROM posts p
-        LEFT JOIN users u ON p.user_id = u.id
-        WHERE p.user_id = ?
-        ORDER BY p.created_at DESC
-    `
+        WHERE p.user_id = ? AND ` + visible
+	query, args, descending, err := page.cursorQuery(query, append([]interface{}{userID}, args...))
+	if err != nil {
+		return nil, err
+	}
 
-	rows, err := s.db.Query(query, userID)
+	rows, err := s.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var userPosts []map[string]interface{}
+	userPosts := []map[string]interface{}{}
 	for rows.Next() {
 		post := &model.Post{}
 		var nickname, avatar sql.NullString
+		var commentCount int
 
 		err := rows.Scan(
 			&post.ID,
@@ -575,27 +851,23 @@ func (s *PostService) GetUserPosts(userID string) ([]map[string]interface{}, err
 			&post.UpdatedAt,
 			&nickname,
 			&avatar,
+			&commentCount,
 		)
 		if err != nil {
 			return nil, err
 		}
 
-		comments, err := s.getPostComments(post.ID)
-		if err != nil {
-			return nil, err
-		}
-
 		postWithDetails := map[string]interface{}{
-			"id":        post.ID,
-			"user_id":   post.UserID,
-			"nickname":  nickname.String,
-			"avatar":    avatar.String,
-			"content":   post.Content,
-			"imagePath": post.ImagePath,
-			"privacy":   post.Privacy,
-			"createdAt": post.CreatedAt,
-			"updatedAt": post.UpdatedAt,
-			"comments":  comments,
+			"id":            post.ID,
+			"user_id":       post.UserID,
+			"nickname":      nickname.String,
+			"avatar":        avatar.String,
+			"content":       post.Content,
+			"imagePath":     post.ImagePath,
+			"privacy":       post.Privacy,
+			"createdAt":     post.CreatedAt,
+			"updatedAt":     post.UpdatedAt,
+			"comment_count": commentCount,
 		}
 		userPosts = append(userPosts, postWithDetails)
 	}
@@ -604,5 +876,21 @@ func (s *PostService) GetUserPosts(userID string) ([]map[string]interface{}, err
 		return nil, err
 	}
 
-	return userPosts, nil
+	result := &UserPostsPage{}
+	result.Posts, result.NextCursor = paginate(page, userPosts, descending, newestFirst, rowKey("createdAt"))
+
+	postIDs := make([]string, 0, len(result.Posts))
+	for _, post := range result.Posts {
+		postIDs = append(postIDs, post["id"].(string))
+	}
+	posts, args := inList(postIDs)
+	previews, err := s.loadCommentPreviews(posts, args, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	for _, post := range result.Posts {
+		post["comments"] = commentPreview(previews, post["id"].(string))
+	}
+
+	return result, nil
 }
@@ -0,0 +1,186 @@
+package service
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"path/filepath"
+	"social-network/pkg/db/sqlite"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	sqlite3 "github.com/mattn/go-sqlite3"
+)
+
+// countingDriver wraps the sqlite3 driver and counts the statements run
+// through it. Its connections don't implement the context fast paths, so
+// database/sql prepares every query and exec.
+type countingDriver struct {
+	sqlite3.SQLiteDriver
+	queries atomic.Int64
+}
+
+func (d *countingDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.SQLiteDriver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &countingConn{conn: conn, driver: d}, nil
+}
+
+type countingConn struct {
+	conn   driver.Conn
+	driver *countingDriver
+}
+
+func (c *countingConn) Prepare(query string) (driver.Stmt, error) {
+	c.driver.queries.Add(1)
+	return c.conn.Prepare(query)
+}
+
+func (c *countingConn) Close() error              { return c.conn.Close() }
+func (c *countingConn) Begin() (driver.Tx, error) { return c.conn.Begin() }
+
+var (
+	registerCounting sync.Once
+	counting         = &countingDriver{}
+)
+
+// seedPosts creates a migrated database holding posts public posts, each
+// with a few comments and a reply, and returns it opened through the
+// counting driver.
+func seedPosts(b testing.TB, posts int) *sql.DB {
+	b.Helper()
+
+	path := filepath.Join(b.TempDir(), "bench.db")
+	seed, err := sqlite.New(path)
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := seed.RunMigrations("../../pkg/db/migrations/sqlite"); err != nil {
+		b.Fatal(err)
+	}
+
+	tx, err := seed.Begin()
+	if err != nil {
+		b.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		_, err := tx.Exec(`
+            INSERT INTO users (id, email, password, first_name, last_name, date_of_birth, nickname, is_public)
+            VALUES (?, ?, 'x', 'First', 'Last', '2000-01-01', ?, true)`,
+			fmt.Sprintf("user-%d", i), fmt.Sprintf("user-%d@example.com", i), fmt.Sprintf("user%d", i))
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+	now := time.Now()
+	for i := 0; i < posts; i++ {
+		postID := fmt.Sprintf("post-%d", i)
+		created := now.Add(time.Duration(i) * time.Second)
+		_, err := tx.Exec(`
+            INSERT INTO posts (id, user_id, content, privacy, created_at, updated_at)
+            VALUES (?, ?, 'content', 'public', ?, ?)`,
+			postID, fmt.Sprintf("user-%d", i%5), created, created)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for j := 0; j < 5; j++ {
+			var parentID interface{}
+			if j == 4 {
+				parentID = fmt.Sprintf("comment-%d-0", i)
+			}
+			_, err := tx.Exec(`
+                INSERT INTO post_comments (id, post_id, user_id, parent_comment_id, content, created_at, updated_at)
+                VALUES (?, ?, ?, ?, 'comment', ?, ?)`,
+				fmt.Sprintf("comment-%d-%d", i, j), postID, fmt.Sprintf("user-%d", j), parentID,
+				created.Add(time.Duration(j)*time.Millisecond), created)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		b.Fatal(err)
+	}
+	seed.Close()
+
+	registerCounting.Do(func() { sql.Register("sqlite3_counting", counting) })
+	db, err := sql.Open("sqlite3_counting", path)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { db.Close() })
+	return db
+}
+
+// queriesPerCall runs fn once and returns the number of statements it ran.
+func queriesPerCall(b testing.TB, fn func() error) int64 {
+	b.Helper()
+	before := counting.queries.Load()
+	if err := fn(); err != nil {
+		b.Fatal(err)
+	}
+	return counting.queries.Load() - before
+}
+
+// postListings are the listings whose number of queries must not depend on
+// the number of posts.
+var postListings = []struct {
+	name string
+	load func(s *PostService) error
+}{
+	{"GetPublicPosts", func(s *PostService) error {
+		_, err := s.GetPublicPosts("user-0")
+		return err
+	}},
+	{"GetUserPosts", func(s *PostService) error {
+		_, err := s.GetUserPosts("user-1", "user-0", PageRequest{})
+		return err
+	}},
+}
+
+func TestPostListingQueryCounts(t *testing.T) {
+	if testing.Short() {
+		t.Skip("seeds thousands of posts")
+	}
+
+	for _, listing := range postListings {
+		t.Run(listing.name, func(t *testing.T) {
+			baseline := int64(-1)
+			for _, posts := range []int{10, 100, 500, 2000} {
+				s := NewPostService(seedPosts(t, posts), nil)
+				queries := queriesPerCall(t, func() error { return listing.load(s) })
+				if baseline < 0 {
+					baseline = queries
+				} else if queries != baseline {
+					t.Errorf("%d queries for %d posts, %d for the smallest database", queries, posts, baseline)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkPostListings times the post listings over databases of growing
+// size.
+func BenchmarkPostListings(b *testing.B) {
+	for _, listing := range postListings {
+		for _, posts := range []int{10, 100, 500, 2000} {
+			b.Run(fmt.Sprintf("%s/posts=%d", listing.name, posts), func(b *testing.B) {
+				s := NewPostService(seedPosts(b, posts), nil)
+				load := func() error { return listing.load(s) }
+				queries := queriesPerCall(b, load)
+
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if err := load(); err != nil {
+						b.Fatal(err)
+					}
+				}
+				b.ReportMetric(float64(queries), "queries/op")
+			})
+		}
+	}
+}
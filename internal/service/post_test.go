@@ -0,0 +1,60 @@
+package service
+
+import (
+	"social-network/internal/model"
+	"testing"
+)
+
+// TestGetPublicPostsBeyondVariableLimit lists more posts than SQLite binds
+// variables in one statement, so the comment previews and reactions loaded
+// for them cannot bind the post IDs.
+func TestGetPublicPostsBeyondVariableLimit(t *testing.T) {
+	const posts = 33000
+	db := newTestDB(t)
+	seedUser(t, db, "alice", true)
+	seedUser(t, db, "bob", true)
+	_, err := db.Exec(`
+        WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < ?)
+        INSERT INTO posts (id, user_id, content, privacy, created_at, updated_at)
+        SELECT 'post-' || i, 'alice', 'content', 'public',
+               datetime('2024-01-01', '+' || i || ' seconds'), datetime('2024-01-01')
+        FROM n`, posts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`
+        INSERT INTO post_comments (id, post_id, user_id, content, created_at, updated_at)
+        VALUES ('comment-1', 'post-1', 'bob', 'first!', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`
+        INSERT INTO reactions (id, target_type, target_id, user_id, emoji, created_at)
+        VALUES ('r1', 'post', 'post-1', 'bob', '👍', CURRENT_TIMESTAMP),
+               ('r2', 'comment', 'comment-1', 'alice', '🎉', CURRENT_TIMESTAMP)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := NewPostService(db, nil).GetPublicPosts("bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != posts {
+		t.Fatalf("got %d posts, want %d", len(list), posts)
+	}
+	oldest := list[len(list)-1]
+	if oldest["id"] != "post-1" {
+		t.Fatalf("last post is %v, want the oldest", oldest["id"])
+	}
+	comments := oldest["comments"].([]map[string]interface{})
+	if len(comments) != 1 {
+		t.Fatalf("oldest post has %d preview comments, want 1", len(comments))
+	}
+	if reactions := comments[0]["reactions"].([]model.ReactionSummary); len(reactions) != 1 || reactions[0].Emoji != "🎉" {
+		t.Errorf("preview comment reactions = %v, want one 🎉", reactions)
+	}
+	if reactions := oldest["reactions"]; reactions == nil || len(reactions.([]model.ReactionSummary)) != 1 {
+		t.Errorf("oldest post reactions = %v, want one summary", reactions)
+	}
+}
@@ -0,0 +1,309 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"social-network/internal/model"
+	"social-network/internal/realtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// typingThrottle is the minimum interval between two relayed "is typing"
+// events from the same user to the same conversation.
+const typingThrottle = 2 * time.Second
+
+// typingPruneInterval is how often throttle entries of users who never sent
+// a "stopped typing" event are dropped.
+const typingPruneInterval = time.Minute
+
+// MaxPresenceUsers caps the number of users in one presence lookup.
+const MaxPresenceUsers = 100
+
+var (
+	ErrTooManyPresenceUsers = errors.New("too many user_ids")
+	ErrNotChatPartner       = errors.New("typing indicators can only be sent to followers, followed users and chat partners")
+)
+
+type PresenceService struct {
+	db  *sql.DB
+	hub *realtime.Hub
+
+	typingMu   sync.Mutex
+	lastTyping map[string]time.Time
+	lastPrune  time.Time
+}
+
+func NewPresenceService(db *sql.DB, hub *realtime.Hub) *PresenceService {
+	return &PresenceService{
+		db:         db,
+		hub:        hub,
+		lastTyping: make(map[string]time.Time),
+	}
+}
+
+// HandlePresenceChange records when a user goes offline and broadcasts the
+// change to their followers, the people they follow and their chat partners.
+func (s *PresenceService) HandlePresenceChange(userID string, online bool) {
+	presence := model.Presence{
+		UserID: userID,
+		Online: online,
+	}
+
+	if !online {
+		s.forgetTyping(userID)
+		now := time.Now()
+		presence.LastSeen = &now
+		if _, err := s.db.Exec(`UPDATE users SET last_seen_at = ? WHERE id = ?`, now.UTC(), userID); err != nil {
+			log.Printf("Error updating last seen for %s: %v", userID, err)
+		}
+	}
+
+	audience, err := s.presenceAudience(userID)
+	if err != nil {
+		log.Printf("Error getting presence audience for %s: %v", userID, err)
+		return
+	}
+
+	frame := realtime.NewFrame(realtime.TypePresence, presence)
+	for _, memberID := range audience {
+		if err := s.hub.Publish(memberID, frame); err != nil {
+			log.Printf("Error sending presence to %s: %v", memberID, err)
+		}
+	}
+}
+
+// GetPresence returns the presence of the given users that viewerID may
+// see: people they follow or chat with whose profile is visible to them.
+// Other users are left out of the result.
+func (s *PresenceService) GetPresence(viewerID string, userIDs []string) ([]model.Presence, error) {
+	result := []model.Presence{}
+	if len(userIDs) == 0 {
+		return result, nil
+	}
+	if len(userIDs) > MaxPresenceUsers {
+		return nil, ErrTooManyPresenceUsers
+	}
+
+	placeholders, ids := inList(userIDs)
+	rows, err := s.db.Query(`
+        SELECT u.id, u.last_seen_at
+        FROM users u, (SELECT ? AS id) viewer
+        WHERE u.id IN (`+placeholders+`)
+          AND (u.id = viewer.id OR `+presenceVisible("viewer.id", "u.id")+`)`,
+		append([]interface{}{viewerID}, ids...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var presence model.Presence
+		var lastSeen sql.NullTime
+		if err := rows.Scan(&presence.UserID, &lastSeen); err != nil {
+			return nil, err
+		}
+
+		presence.Online = s.hub.IsConnected(presence.UserID)
+		if !presence.Online && lastSeen.Valid {
+			presence.LastSeen = &lastSeen.Time
+		}
+		result = append(result, presence)
+	}
+
+	return result, rows.Err()
+}
+
+// SendTyping relays a typing indicator to the other participants of a private
+// or group conversation. Repeated "is typing" events are throttled; "stopped
+// typing" events are always relayed.
+func (s *PresenceService) SendTyping(userID string, recipientID string, groupID string, isTyping bool) error {
+	if (recipientID == "") == (groupID == "") {
+		return errors.New("exactly one of recipient_id or group_id is required")
+	}
+
+	// Authorization comes first, so refused events don't count against the
+	// throttle
+	var members []string
+	if recipientID != "" {
+		related, err := s.related(userID, recipientID)
+		if err != nil {
+			return err
+		}
+		if !related {
+			return ErrNotChatPartner
+		}
+	} else {
+		var err error
+		if members, err = s.otherGroupMembers(groupID, userID); err != nil {
+			return err
+		}
+	}
+
+	target := recipientID
+	if groupID != "" {
+		target = "group:" + groupID
+	}
+	if !s.allowTyping(userID+"|"+target, isTyping) {
+		return nil
+	}
+
+	frame := realtime.NewFrame(realtime.TypeTyping, model.TypingEvent{
+		UserID:      userID,
+		RecipientID: recipientID,
+		GroupID:     groupID,
+		IsTyping:    isTyping,
+	})
+	if recipientID != "" {
+		return s.hub.Publish(recipientID, frame)
+	}
+	for _, memberID := range members {
+		if err := s.hub.Publish(memberID, frame); err != nil {
+			log.Printf("Error sending typing event to %s: %v", memberID, err)
+		}
+	}
+	return nil
+}
+
+// otherGroupMembers returns the accepted members of a group other than
+// userID, or ErrNotGroupMember if userID is not one of them.
+func (s *PresenceService) otherGroupMembers(groupID, userID string) ([]string, error) {
+	var status string
+	err := s.db.QueryRow(`
+        SELECT status FROM group_members
+        WHERE group_id = ? AND user_id = ? AND status = 'accepted'`,
+		groupID, userID).Scan(&status)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotGroupMember
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`
+        SELECT user_id FROM group_members
+        WHERE group_id = ? AND status = 'accepted' AND user_id != ?`,
+		groupID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var members []string
+	for rows.Next() {
+		var memberID string
+		if err := rows.Scan(&memberID); err != nil {
+			return nil, err
+		}
+		members = append(members, memberID)
+	}
+	return members, rows.Err()
+}
+
+func (s *PresenceService) allowTyping(key string, isTyping bool) bool {
+	s.typingMu.Lock()
+	defer s.typingMu.Unlock()
+
+	if !isTyping {
+		delete(s.lastTyping, key)
+		return true
+	}
+
+	now := time.Now()
+	if now.Sub(s.lastPrune) > typingPruneInterval {
+		// Entries older than the throttle no longer hold anything back
+		for k, last := range s.lastTyping {
+			if now.Sub(last) >= typingThrottle {
+				delete(s.lastTyping, k)
+			}
+		}
+		s.lastPrune = now
+	}
+
+	if last, ok := s.lastTyping[key]; ok && now.Sub(last) < typingThrottle {
+		return false
+	}
+	s.lastTyping[key] = now
+	return true
+}
+
+// forgetTyping drops the throttle entries of a user who went offline.
+func (s *PresenceService) forgetTyping(userID string) {
+	s.typingMu.Lock()
+	defer s.typingMu.Unlock()
+
+	for key := range s.lastTyping {
+		if strings.HasPrefix(key, userID+"|") {
+			delete(s.lastTyping, key)
+		}
+	}
+}
+
+// related reports whether two users follow each other in either direction
+// or have exchanged private messages.
+func (s *PresenceService) related(userID, otherID string) (bool, error) {
+	var related bool
+	err := s.db.QueryRow(`
+        SELECT EXISTS (
+            SELECT 1 FROM follow_requests
+            WHERE status = 'accepted'
+              AND ((follower_id = ? AND following_id = ?) OR (follower_id = ? AND following_id = ?))
+        ) OR EXISTS (
+            SELECT 1 FROM messages
+            WHERE (sender_id = ? AND recipient_id = ?) OR (sender_id = ? AND recipient_id = ?)
+        )`,
+		userID, otherID, otherID, userID, userID, otherID, otherID, userID,
+	).Scan(&related)
+	return related, err
+}
+
+// presenceAudience returns every user who should see userID's presence: the
+// users GetPresence would return it to.
+func (s *PresenceService) presenceAudience(userID string) ([]string, error) {
+	rows, err := s.db.Query(`
+        SELECT candidate.id
+        FROM (
+            SELECT follower_id AS id FROM follow_requests
+            WHERE following_id = ? AND status = 'accepted'
+            UNION
+            SELECT recipient_id FROM messages WHERE sender_id = ?
+            UNION
+            SELECT sender_id FROM messages WHERE recipient_id = ?
+        ) candidate, (SELECT ? AS id) subject
+        WHERE candidate.id != subject.id AND `+presenceVisible("candidate.id", "subject.id"),
+		userID, userID, userID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var audience []string
+	for rows.Next() {
+		var memberID string
+		if err := rows.Scan(&memberID); err != nil {
+			return nil, err
+		}
+		audience = append(audience, memberID)
+	}
+	return audience, rows.Err()
+}
+
+// presenceVisible is the condition under which the user in column viewer
+// sees the presence of the user in column user: the viewer follows or chats
+// with them and may view their profile, as canViewProfile decides.
+func presenceVisible(viewer, user string) string {
+	follows := `EXISTS (
+                SELECT 1 FROM follow_requests pf
+                WHERE pf.follower_id = ` + viewer + ` AND pf.following_id = ` + user + ` AND pf.status = 'accepted'
+            )`
+	return `(
+            (` + follows + ` OR EXISTS (
+                SELECT 1 FROM messages pm
+                WHERE (pm.sender_id = ` + viewer + ` AND pm.recipient_id = ` + user + `)
+                   OR (pm.sender_id = ` + user + ` AND pm.recipient_id = ` + viewer + `)
+            ))
+            AND (EXISTS (SELECT 1 FROM users pu WHERE pu.id = ` + user + ` AND pu.is_public) OR ` + follows + `)
+        )`
+}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"fmt"
+	"social-network/internal/realtime"
+	"testing"
+	"time"
+)
+
+func TestGetPresenceOnlyReturnsRelatedVisibleUsers(t *testing.T) {
+	db := newTestDB(t)
+	for _, id := range []string{"viewer", "followed", "private", "partner", "stranger"} {
+		seedUser(t, db, id, id != "private")
+	}
+	seedFollow(t, db, "viewer", "followed")
+	// Following a private profile reveals it, chatting alone does not
+	seedFollow(t, db, "private", "viewer")
+	_, err := db.Exec(`
+        INSERT INTO messages (id, sender_id, recipient_id, content, created_at, is_read)
+        VALUES ('m1', 'partner', 'viewer', 'hi', ?, false), ('m2', 'viewer', 'private', 'hi', ?, false)`,
+		time.Now(), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewPresenceService(db, realtime.NewHub(realtime.DefaultConfig))
+	presence, err := s.GetPresence("viewer", []string{"viewer", "followed", "private", "partner", "stranger"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]bool{}
+	for _, p := range presence {
+		got[p.UserID] = true
+	}
+	for id, want := range map[string]bool{"viewer": true, "followed": true, "partner": true, "private": false, "stranger": false} {
+		if got[id] != want {
+			t.Errorf("presence of %s returned = %v, want %v", id, got[id], want)
+		}
+	}
+}
+
+func TestGetPresenceCapsUserIDs(t *testing.T) {
+	s := NewPresenceService(newTestDB(t), realtime.NewHub(realtime.DefaultConfig))
+	ids := make([]string, MaxPresenceUsers+1)
+	for i := range ids {
+		ids[i] = fmt.Sprint(i)
+	}
+	if _, err := s.GetPresence("viewer", ids); err != ErrTooManyPresenceUsers {
+		t.Fatalf("err = %v, want ErrTooManyPresenceUsers", err)
+	}
+}
+
+func TestSendTypingRequiresRelationship(t *testing.T) {
+	db := newTestDB(t)
+	for _, id := range []string{"a", "b", "c"} {
+		seedUser(t, db, id, true)
+	}
+	seedFollow(t, db, "b", "a")
+
+	s := NewPresenceService(db, realtime.NewHub(realtime.DefaultConfig))
+	if err := s.SendTyping("a", "b", "", true); err != nil {
+		t.Fatalf("typing to a follower: %v", err)
+	}
+	if err := s.SendTyping("a", "c", "", true); err != ErrNotChatPartner {
+		t.Fatalf("typing to a stranger: err = %v, want ErrNotChatPartner", err)
+	}
+}
+
+func TestTypingEntriesArePruned(t *testing.T) {
+	s := NewPresenceService(newTestDB(t), realtime.NewHub(realtime.DefaultConfig))
+
+	s.allowTyping("a|b", true)
+	s.allowTyping("a|group:g", true)
+	s.forgetTyping("a")
+	if len(s.lastTyping) != 0 {
+		t.Fatalf("entries left after going offline: %v", s.lastTyping)
+	}
+
+	s.lastTyping["gone|b"] = time.Now().Add(-time.Hour)
+	s.lastPrune = time.Now().Add(-2 * typingPruneInterval)
+	s.allowTyping("c|d", true)
+	if _, ok := s.lastTyping["gone|b"]; ok {
+		t.Fatal("stale entry was not pruned")
+	}
+	if _, ok := s.lastTyping["c|d"]; !ok {
+		t.Fatal("current entry missing")
+	}
+}
+
+// TestPresenceAudienceMatchesGetPresence checks that a presence change is
+// broadcast to exactly the users who could look it up.
+func TestPresenceAudienceMatchesGetPresence(t *testing.T) {
+	db := newTestDB(t)
+	users := []string{"open", "closed", "follower", "partner", "followee", "requester", "stranger"}
+	for _, id := range users {
+		seedUser(t, db, id, id != "closed")
+	}
+	seedFollow(t, db, "follower", "open")
+	seedFollow(t, db, "follower", "closed")
+	seedFollow(t, db, "open", "followee")
+	seedFollow(t, db, "closed", "followee")
+	_, err := db.Exec(`
+        INSERT INTO follow_requests (id, follower_id, following_id, status)
+        VALUES ('requester->closed', 'requester', 'closed', 'pending')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`
+        INSERT INTO messages (id, sender_id, recipient_id, content, created_at, is_read)
+        VALUES ('m1', 'partner', 'open', 'hi', ?, false), ('m2', 'closed', 'partner', 'hi', ?, false)`,
+		time.Now(), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewPresenceService(db, realtime.NewHub(realtime.DefaultConfig))
+	for _, subject := range []string{"open", "closed"} {
+		audience, err := s.presenceAudience(subject)
+		if err != nil {
+			t.Fatal(err)
+		}
+		told := map[string]bool{}
+		for _, id := range audience {
+			told[id] = true
+		}
+		if !told["follower"] {
+			t.Errorf("%s's presence is not broadcast to their follower", subject)
+		}
+
+		for _, viewer := range users {
+			if viewer == subject {
+				continue
+			}
+			presence, err := s.GetPresence(viewer, []string{subject})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if canSee := len(presence) == 1; told[viewer] != canSee {
+				t.Errorf("%s's presence: broadcast to %s = %v, but GetPresence returns it = %v",
+					subject, viewer, told[viewer], canSee)
+			}
+		}
+	}
+}
+
+func TestRefusedTypingIsNotThrottled(t *testing.T) {
+	db := newTestDB(t)
+	for _, id := range []string{"a", "b", "c"} {
+		seedUser(t, db, id, true)
+	}
+	seedGroup(t, db, "hikers", "b", "c")
+
+	s := NewPresenceService(db, realtime.NewHub(realtime.DefaultConfig))
+	if err := s.SendTyping("a", "c", "", true); err != ErrNotChatPartner {
+		t.Fatalf("typing to a stranger: err = %v, want ErrNotChatPartner", err)
+	}
+	if err := s.SendTyping("a", "", "hikers", true); err != ErrNotGroupMember {
+		t.Fatalf("typing to another group: err = %v, want ErrNotGroupMember", err)
+	}
+	if len(s.lastTyping) != 0 {
+		t.Errorf("refused events were throttled: %v", s.lastTyping)
+	}
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"database/sql"
+	"social-network/internal/model"
+)
+
+// Profile is everything a profile page shows, loaded in one call. When the
+// viewer may not see a private profile, Restricted is set and the user is
+// only described by Card; User and Posts are nil.
+type Profile struct {
+	User           *model.User     `json:"user,omitempty"`
+	Card           *model.UserCard `json:"card,omitempty"`
+	FollowerCount  int             `json:"follower_count"`
+	FollowingCount int             `json:"following_count"`
+	IsOwnProfile   bool            `json:"is_own_profile"`
+	FollowStatus   string          `json:"follow_status,omitempty"`
+	Restricted     bool            `json:"restricted"`
+	Posts          *UserPostsPage  `json:"posts,omitempty"`
+}
+
+type ProfileService struct {
+	db          *sql.DB
+	userService *UserService
+	postService *PostService
+}
+
+func NewProfileService(db *sql.DB, userService *UserService, postService *PostService) *ProfileService {
+	return &ProfileService{
+		db:          db,
+		userService: userService,
+		postService: postService,
+	}
+}
+
+// GetProfile returns the profile of userID as viewerID sees it, with the
+// first page of posts.
+func (s *ProfileService) GetProfile(userID string, viewerID string, page PageRequest) (*Profile, error) {
+	profile := &Profile{IsOwnProfile: userID == viewerID}
+	user, err := s.userService.GetUserForViewer(userID, viewerID)
+	switch err {
+	case nil:
+		profile.User = user
+	case ErrPrivateProfile:
+		profile.Restricted = true
+		if profile.Card, err = s.userService.GetUserCard(userID); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+
+	err = s.db.QueryRow(`
+        SELECT
+            (SELECT COUNT(*) FROM follow_requests WHERE following_id = ? AND status = 'accepted'),
+            (SELECT COUNT(*) FROM follow_requests WHERE follower_id = ? AND status = 'accepted'),
+            COALESCE((SELECT status FROM follow_requests WHERE follower_id = ? AND following_id = ?), 'not_followed')`,
+		userID, userID, viewerID, userID,
+	).Scan(&profile.FollowerCount, &profile.FollowingCount, &profile.FollowStatus)
+	if err != nil {
+		return nil, err
+	}
+	if profile.IsOwnProfile {
+		profile.FollowStatus = ""
+	}
+
+	if profile.Restricted {
+		return profile, nil
+	}
+
+	profile.Posts, err = s.postService.GetUserPosts(userID, viewerID, page)
+	if err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
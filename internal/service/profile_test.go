@@ -0,0 +1,114 @@
+package service
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// seedUserPosts inserts posts p1 (oldest) to pN by userID. Every third post
+// is private.
+func seedUserPosts(t *testing.T, s *PostService, userID string, n int) {
+	t.Helper()
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i := 1; i <= n; i++ {
+		privacy := "public"
+		if i%3 == 0 {
+			privacy = "private"
+		}
+		created := start.Add(time.Duration(i) * time.Minute)
+		_, err := s.db.Exec(`
+            INSERT INTO posts (id, user_id, content, privacy, created_at, updated_at)
+            VALUES (?, ?, 'x', ?, ?, ?)`,
+			fmt.Sprintf("p%d", i), userID, privacy, created, created)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func userPostPages(t *testing.T, s *PostService, userID, viewerID string, limit int) [][]string {
+	t.Helper()
+	var pages [][]string
+	page := PageRequest{Limit: limit}
+	for {
+		result, err := s.GetUserPosts(userID, viewerID, page)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, post := range result.Posts {
+			ids = append(ids, post["id"].(string))
+		}
+		pages = append(pages, ids)
+		if result.NextCursor == "" {
+			return pages
+		}
+		page.Before = result.NextCursor
+	}
+}
+
+func TestGetUserPostsPages(t *testing.T) {
+	db := newTestDB(t)
+	seedUser(t, db, "alice", true)
+	seedUser(t, db, "follower", true)
+	seedUser(t, db, "stranger", true)
+	seedFollow(t, db, "follower", "alice")
+	s := NewPostService(db, nil)
+	seedUserPosts(t, s, "alice", 7)
+
+	tests := []struct {
+		viewerID string
+		want     [][]string
+	}{
+		{"alice", [][]string{{"p7", "p6", "p5"}, {"p4", "p3", "p2"}, {"p1"}}},
+		{"follower", [][]string{{"p7", "p6", "p5"}, {"p4", "p3", "p2"}, {"p1"}}},
+		// Hidden posts don't leave gaps or short pages
+		{"stranger", [][]string{{"p7", "p5", "p4"}, {"p2", "p1"}}},
+	}
+	for _, tt := range tests {
+		if got := userPostPages(t, s, "alice", tt.viewerID, 3); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s sees pages %v, want %v", tt.viewerID, got, tt.want)
+		}
+	}
+}
+
+func TestGetProfile(t *testing.T) {
+	db := newTestDB(t)
+	for _, id := range []string{"alice", "bob", "carol"} {
+		seedUser(t, db, id, true)
+	}
+	seedFollow(t, db, "bob", "alice")
+	seedFollow(t, db, "carol", "alice")
+	seedFollow(t, db, "alice", "carol")
+	posts := NewPostService(db, nil)
+	seedUserPosts(t, posts, "alice", 4)
+	s := NewProfileService(db, NewUserService(db), posts)
+
+	profile, err := s.GetProfile("alice", "bob", PageRequest{Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.User == nil || profile.User.ID != "alice" || profile.Restricted || profile.IsOwnProfile {
+		t.Errorf("profile = %+v", profile)
+	}
+	if profile.FollowerCount != 2 || profile.FollowingCount != 1 || profile.FollowStatus != "accepted" {
+		t.Errorf("counts %d/%d, follow status %q", profile.FollowerCount, profile.FollowingCount, profile.FollowStatus)
+	}
+	if len(profile.Posts.Posts) != 2 || profile.Posts.NextCursor == "" {
+		t.Errorf("first page has %d posts, next cursor %q", len(profile.Posts.Posts), profile.Posts.NextCursor)
+	}
+
+	own, err := s.GetProfile("alice", "alice", PageRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !own.IsOwnProfile || own.FollowStatus != "" || len(own.Posts.Posts) != 4 {
+		t.Errorf("own profile = %+v", own)
+	}
+
+	if _, err := s.GetProfile("nobody", "bob", PageRequest{}); err != ErrUserNotFound {
+		t.Errorf("missing user: %v, want ErrUserNotFound", err)
+	}
+}
@@ -0,0 +1,434 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"social-network/internal/model"
+	"social-network/internal/realtime"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidReactionTarget  = errors.New("invalid reaction target type")
+	ErrInvalidEmoji           = errors.New("reaction must be a single emoji")
+	ErrReactionTargetNotFound = errors.New("reaction target not found")
+)
+
+// maxEmojiRunes allows emoji built from several code points (skin tones,
+// flags, ZWJ sequences) while rejecting long runs of them. The longest
+// standard sequences, kisses with two skin tones, have 10.
+const maxEmojiRunes = 10
+
+type ReactionService struct {
+	db          *sql.DB
+	hub         *realtime.Hub
+	postService *PostService
+}
+
+func NewReactionService(db *sql.DB, hub *realtime.Hub, postService *PostService) *ReactionService {
+	return &ReactionService{
+		db:          db,
+		hub:         hub,
+		postService: postService,
+	}
+}
+
+// AddReaction reacts to a target the user can see. Adding the same emoji
+// twice is a no-op.
+func (s *ReactionService) AddReaction(userID string, input model.ReactionInput) ([]model.ReactionSummary, error) {
+	if !validEmoji(input.Emoji) {
+		return nil, ErrInvalidEmoji
+	}
+	audience, err := s.audience(userID, input.TargetType, input.TargetID)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.db.Exec(`
+        INSERT OR IGNORE INTO reactions (id, target_type, target_id, user_id, emoji, created_at)
+        VALUES (?, ?, ?, ?, ?, ?)`,
+		uuid.New().String(), input.TargetType, input.TargetID, userID, input.Emoji, time.Now(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.afterChange(userID, input, true, result, audience)
+}
+
+func (s *ReactionService) RemoveReaction(userID string, input model.ReactionInput) ([]model.ReactionSummary, error) {
+	audience, err := s.audience(userID, input.TargetType, input.TargetID)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.db.Exec(`
+        DELETE FROM reactions
+        WHERE target_type = ? AND target_id = ? AND user_id = ? AND emoji = ?`,
+		input.TargetType, input.TargetID, userID, input.Emoji,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.afterChange(userID, input, false, result, audience)
+}
+
+// GetReactions returns the reaction summary of a target the user can see.
+func (s *ReactionService) GetReactions(userID, targetType, targetID string) ([]model.ReactionSummary, error) {
+	if _, err := s.audience(userID, targetType, targetID); err != nil {
+		return nil, err
+	}
+	return s.summary(targetType, targetID, userID)
+}
+
+// afterChange broadcasts a reaction change if anything changed and returns
+// the target's summary from the user's point of view.
+func (s *ReactionService) afterChange(userID string, input model.ReactionInput, added bool, result sql.Result, audience []string) ([]model.ReactionSummary, error) {
+	if changed, err := result.RowsAffected(); err == nil && changed > 0 {
+		// Reacted is relative to each viewer, so it is left unset in events
+		reactions, err := s.summary(input.TargetType, input.TargetID, "")
+		if err != nil {
+			return nil, err
+		}
+
+		frame := realtime.NewFrame(realtime.TypeReaction, model.ReactionEvent{
+			TargetType: input.TargetType,
+			TargetID:   input.TargetID,
+			UserID:     userID,
+			Emoji:      input.Emoji,
+			Added:      added,
+			Reactions:  reactions,
+		})
+		for _, memberID := range audience {
+			if err := s.hub.Publish(memberID, frame); err != nil {
+				log.Printf("Error sending reaction to %s: %v", memberID, err)
+			}
+		}
+	}
+
+	return s.summary(input.TargetType, input.TargetID, userID)
+}
+
+func (s *ReactionService) summary(targetType, targetID, viewerID string) ([]model.ReactionSummary, error) {
+	summaries, err := loadReactionSummaries(s.db, targetType, []string{targetID}, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	if summaries[targetID] == nil {
+		return []model.ReactionSummary{}, nil
+	}
+	return summaries[targetID], nil
+}
+
+// audience checks that userID may see the target and returns the users who
+// should receive reaction events for it.
+func (s *ReactionService) audience(userID, targetType, targetID string) ([]string, error) {
+	switch targetType {
+	case model.ReactionTargetPost:
+		post, err := s.postService.GetPost(targetID, userID)
+		if err != nil {
+			return nil, ErrReactionTargetNotFound
+		}
+		return uniqueIDs(post.UserID, userID), nil
+
+	case model.ReactionTargetComment:
+		var postID, authorID string
+		err := s.db.QueryRow(`SELECT post_id, user_id FROM post_comments WHERE id = ?`, targetID).
+			Scan(&postID, &authorID)
+		if err == sql.ErrNoRows {
+			return nil, ErrReactionTargetNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := newPostVisibility(userID).check(s.db, postID); err != nil {
+			return nil, ErrReactionTargetNotFound
+		}
+		return uniqueIDs(authorID, userID), nil
+
+	case model.ReactionTargetGroupPost:
+		return s.groupAudience(userID, `SELECT group_id FROM group_posts WHERE id = ?`, targetID)
+
+	case model.ReactionTargetGroupComment:
+		return s.groupAudience(userID, `
+            SELECT gp.group_id
+            FROM group_post_comments c
+            JOIN group_posts gp ON gp.id = c.post_id
+            WHERE c.id = ?`, targetID)
+
+	case model.ReactionTargetGroupMessage:
+		return s.groupAudience(userID, `
+            SELECT group_id FROM group_messages
+            WHERE id = ? AND deleted_at IS NULL`, targetID)
+
+	case model.ReactionTargetMessage:
+		var senderID, recipientID string
+		err := s.db.QueryRow(`
+            SELECT sender_id, recipient_id FROM messages
+            WHERE id = ? AND deleted_at IS NULL`,
+			targetID).Scan(&senderID, &recipientID)
+		if err == sql.ErrNoRows {
+			return nil, ErrReactionTargetNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		if userID != senderID && userID != recipientID {
+			return nil, ErrReactionTargetNotFound
+		}
+		return uniqueIDs(senderID, recipientID), nil
+	}
+
+	return nil, ErrInvalidReactionTarget
+}
+
+// groupAudience resolves the group of a target with groupQuery and returns
+// its members, provided userID is one of them.
+func (s *ReactionService) groupAudience(userID, groupQuery, targetID string) ([]string, error) {
+	var groupID string
+	err := s.db.QueryRow(groupQuery, targetID).Scan(&groupID)
+	if err == sql.ErrNoRows {
+		return nil, ErrReactionTargetNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`
+        SELECT user_id FROM group_members
+        WHERE group_id = ? AND status = 'accepted'`,
+		groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var members []string
+	isMember := false
+	for rows.Next() {
+		var memberID string
+		if err := rows.Scan(&memberID); err != nil {
+			return nil, err
+		}
+		isMember = isMember || memberID == userID
+		members = append(members, memberID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if !isMember {
+		return nil, ErrReactionTargetNotFound
+	}
+	return members, nil
+}
+
+// loadReactionSummaries returns the reaction summaries of several targets of
+// the same type, keyed by target ID, with the most used emoji first. Targets
+// without reactions are absent from the map.
+func loadReactionSummaries(db *sql.DB, targetType string, targetIDs []string, viewerID string) (map[string][]model.ReactionSummary, error) {
+	summaries := make(map[string][]model.ReactionSummary)
+	err := inBatches(targetIDs, func(placeholders string, ids []interface{}) error {
+		return scanReactionSummaries(db, summaries, targetType, placeholders, ids, viewerID)
+	})
+	return summaries, err
+}
+
+// loadReactionSummariesIn is loadReactionSummaries for the targets selected
+// by a subquery, so that listings of any length take a single query.
+func loadReactionSummariesIn(db *sql.DB, targetType string, targets string, args []interface{}, viewerID string) (map[string][]model.ReactionSummary, error) {
+	summaries := make(map[string][]model.ReactionSummary)
+	err := scanReactionSummaries(db, summaries, targetType, targets, args, viewerID)
+	return summaries, err
+}
+
+// scanReactionSummaries adds the reaction summaries of the targets in
+// targets, the contents of an IN (...) clause, to summaries.
+func scanReactionSummaries(db *sql.DB, summaries map[string][]model.ReactionSummary, targetType string, targets string, args []interface{}, viewerID string) error {
+	rows, err := db.Query(`
+        SELECT target_id, emoji, COUNT(*), MAX(user_id = ?)
+        FROM reactions
+        WHERE target_type = ? AND target_id IN (`+targets+`)
+        GROUP BY target_id, emoji
+        ORDER BY target_id, COUNT(*) DESC, MIN(created_at) ASC`,
+		append([]interface{}{viewerID, targetType}, args...)...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var targetID string
+		var summary model.ReactionSummary
+		if err := rows.Scan(&targetID, &summary.Emoji, &summary.Count, &summary.Reacted); err != nil {
+			return err
+		}
+		summaries[targetID] = append(summaries[targetID], summary)
+	}
+	return rows.Err()
+}
+
+// attachReactions sets the "reactions" key of each item, keyed by "id", to
+// its reaction summary.
+func attachReactions(db *sql.DB, targetType string, items []map[string]interface{}, viewerID string) error {
+	ids := make([]string, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, item["id"].(string))
+	}
+
+	summaries, err := loadReactionSummaries(db, targetType, ids, viewerID)
+	if err != nil {
+		return err
+	}
+	setReactions(items, summaries)
+	return nil
+}
+
+// setReactions sets the "reactions" key of each item, keyed by "id", to its
+// summary in summaries.
+func setReactions(items []map[string]interface{}, summaries map[string][]model.ReactionSummary) {
+	for _, item := range items {
+		reactions := summaries[item["id"].(string)]
+		if reactions == nil {
+			reactions = []model.ReactionSummary{}
+		}
+		item["reactions"] = reactions
+	}
+}
+
+// validEmoji reports whether emoji is a single emoji: a pictograph with an
+// optional variation selector, skin tone or subdivision tags, a keycap, a
+// flag, or such elements joined by zero width joiners.
+func validEmoji(emoji string) bool {
+	if !utf8.ValidString(emoji) {
+		return false
+	}
+	runes := []rune(emoji)
+	if len(runes) == 0 || len(runes) > maxEmojiRunes {
+		return false
+	}
+
+	for i := 0; i < len(runes); {
+		n := emojiElement(runes[i:])
+		if n == 0 {
+			return false
+		}
+		i += n
+		if i == len(runes) {
+			return true
+		}
+		if runes[i] != zeroWidthJoiner {
+			return false
+		}
+		i++
+	}
+	// A trailing joiner
+	return false
+}
+
+const (
+	zeroWidthJoiner     = 0x200D
+	variationSelector15 = 0xFE0E
+	variationSelector16 = 0xFE0F
+	combiningKeycap     = 0x20E3
+	cancelTag           = 0xE007F
+)
+
+// emojiElement returns the length of the emoji element at the start of r, or
+// 0 if r doesn't start with one.
+func emojiElement(r []rune) int {
+	switch {
+	case len(r) == 0:
+		return 0
+	case r[0] >= '0' && r[0] <= '9' || r[0] == '#' || r[0] == '*':
+		i := 1
+		if i < len(r) && r[i] == variationSelector16 {
+			i++
+		}
+		if i < len(r) && r[i] == combiningKeycap {
+			return i + 1
+		}
+		return 0
+	case isRegionalIndicator(r[0]):
+		// Flags are pairs of regional indicators
+		if len(r) >= 2 && isRegionalIndicator(r[1]) {
+			return 2
+		}
+		return 0
+	case !isPictograph(r[0]):
+		return 0
+	}
+
+	i := 1
+	if i < len(r) && (r[i] == variationSelector15 || r[i] == variationSelector16) {
+		i++
+	}
+	if i < len(r) && isSkinTone(r[i]) {
+		i++
+	}
+	// Subdivision flags such as England's add tags ended by a cancel tag
+	if i < len(r) && isTag(r[i]) {
+		for i < len(r) && isTag(r[i]) && r[i] != cancelTag {
+			i++
+		}
+		if i < len(r) && r[i] == cancelTag {
+			return i + 1
+		}
+		return 0
+	}
+	return i
+}
+
+// emojiRanges are the blocks holding pictographic emoji.
+var emojiRanges = [][2]rune{
+	{0x00A9, 0x00A9},   // ©
+	{0x00AE, 0x00AE},   // ®
+	{0x203C, 0x203C},   // ‼
+	{0x2049, 0x2049},   // ⁉
+	{0x2122, 0x2122},   // ™
+	{0x2139, 0x2139},   // ℹ
+	{0x2194, 0x21AA},   // arrows
+	{0x231A, 0x23FF},   // miscellaneous technical
+	{0x24C2, 0x24C2},   // Ⓜ
+	{0x25AA, 0x25FE},   // geometric shapes
+	{0x2600, 0x27BF},   // miscellaneous symbols and dingbats
+	{0x2934, 0x2935},   // ⤴ ⤵
+	{0x2B05, 0x2B55},   // arrows, squares and stars
+	{0x3030, 0x3030},   // 〰
+	{0x303D, 0x303D},   // 〽
+	{0x3297, 0x3299},   // ㊗ ㊙
+	{0x1F000, 0x1F0FF}, // mahjong and playing cards
+	{0x1F100, 0x1F1E5}, // enclosed alphanumerics
+	{0x1F200, 0x1F2FF}, // enclosed ideographs
+	{0x1F300, 0x1F3FA}, // symbols and pictographs, up to the skin tones
+	{0x1F400, 0x1FAFF}, // the remaining pictograph, emoticon and symbol blocks
+}
+
+func isPictograph(r rune) bool {
+	for _, block := range emojiRanges {
+		if r >= block[0] && r <= block[1] {
+			return true
+		}
+	}
+	return false
+}
+
+func isRegionalIndicator(r rune) bool { return r >= 0x1F1E6 && r <= 0x1F1FF }
+func isSkinTone(r rune) bool          { return r >= 0x1F3FB && r <= 0x1F3FF }
+func isTag(r rune) bool               { return r >= 0xE0020 && r <= 0xE007F }
+
+func uniqueIDs(ids ...string) []string {
+	var unique []string
+	seen := make(map[string]bool)
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
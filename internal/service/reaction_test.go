@@ -0,0 +1,78 @@
+package service
+
+import (
+	"social-network/internal/model"
+	"social-network/internal/realtime"
+	"testing"
+)
+
+func TestValidEmoji(t *testing.T) {
+	tests := []struct {
+		emoji string
+		valid bool
+	}{
+		{"👍", true},
+		{"❤️", true},         // variation selector
+		{"👍🏽", true},         // skin tone
+		{"👨‍👩‍👧‍👦", true},    // ZWJ family
+		{"👩🏽‍❤️‍💋‍👨🏻", true}, // the longest standard sequence
+		{"🇫🇷", true},         // flag
+		{"🏴󠁧󠁢󠁥󠁮󠁧󠁿", true},    // subdivision flag
+		{"1️⃣", true},        // keycap
+		{"#⃣", true},         // keycap without selector
+		{"", false},
+		{"a", false},
+		{"1", false},
+		{"é", false},
+		{"日本", false},
+		{"👍a", false},
+		{"👍👍", false},
+		{"👍‍", false}, // trailing joiner
+		{"🇫", false},  // half a flag
+		{"🏽", false},  // lone skin tone
+		{"‍👍", false},
+		{"\xff", false},
+	}
+	for _, tt := range tests {
+		if got := validEmoji(tt.emoji); got != tt.valid {
+			t.Errorf("validEmoji(%q) = %v, want %v", tt.emoji, got, tt.valid)
+		}
+	}
+}
+
+func TestDeletePostRemovesReactions(t *testing.T) {
+	db := newTestDB(t)
+	seedUser(t, db, "alice", true)
+	seedUser(t, db, "bob", true)
+	hub := realtime.NewHub(realtime.DefaultConfig)
+	posts := NewPostService(db, NewNotificationService(db, hub))
+	reactions := NewReactionService(db, hub, posts)
+
+	post, err := posts.CreatePost("alice", model.CreatePostInput{Content: "hello", Privacy: "public"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	comment, err := posts.CreateComment(post.ID, "bob", "hi", nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, input := range []model.ReactionInput{
+		{TargetType: model.ReactionTargetPost, TargetID: post.ID, Emoji: "👍"},
+		{TargetType: model.ReactionTargetComment, TargetID: comment.ID, Emoji: "🎉"},
+	} {
+		if _, err := reactions.AddReaction("bob", input); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := posts.DeletePost(post.ID, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	var left int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM reactions`).Scan(&left); err != nil {
+		t.Fatal(err)
+	}
+	if left != 0 {
+		t.Errorf("%d reactions left after deleting the post", left)
+	}
+}
@@ -7,6 +7,11 @@ import (
 	"time"
 )
 
+var (
+	ErrUserNotFound   = errors.New("user not found")
+	ErrPrivateProfile = errors.New("this profile is private")
+)
+
 type UserService struct {
 	db *sql.DB
 }
@@ -68,13 +73,51 @@ func (s *UserService) GetUserByUUID(uuid string) (*model.User, error) {
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, errors.New("user not found")
+			return nil, ErrUserNotFound
 		}
 		return nil, err
 	}
 	return &user, nil
 }
 
+// GetUserForViewer returns the profile of userID as viewerID may see it.
+// Only the owner sees the email address. Non-followers of a private profile
+// get ErrPrivateProfile and should be shown the user's card instead.
+func (s *UserService) GetUserForViewer(userID string, viewerID string) (*model.User, error) {
+	user, err := s.GetUserByUUID(userID)
+	if err != nil {
+		return nil, err
+	}
+	canView, err := canViewProfile(s.db, userID, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	if !canView {
+		return nil, ErrPrivateProfile
+	}
+	if userID != viewerID {
+		user.Email = ""
+	}
+	return user, nil
+}
+
+func (s *UserService) GetUserCard(userID string) (*model.UserCard, error) {
+	var card model.UserCard
+	err := s.db.QueryRow(`
+        SELECT id, first_name, last_name, avatar, is_public
+        FROM users
+        WHERE id = ?`,
+		userID,
+	).Scan(&card.ID, &card.FirstName, &card.LastName, &card.Avatar, &card.IsPublic)
+	if err == sql.ErrNoRows {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &card, nil
+}
+
 func (s *UserService) UpdateProfileVisibility(userID string, isPublic bool) error {
 	_, err := s.db.Exec(`
         UPDATE users 
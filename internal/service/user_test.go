@@ -0,0 +1,125 @@
+package service
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestCanViewProfile(t *testing.T) {
+	db := newTestDB(t)
+	seedUser(t, db, "open", true)
+	seedUser(t, db, "closed", false)
+	seedUser(t, db, "follower", true)
+	seedUser(t, db, "pending", true)
+	seedUser(t, db, "stranger", true)
+	seedFollow(t, db, "follower", "closed")
+	_, err := db.Exec(`
+        INSERT INTO follow_requests (id, follower_id, following_id, status)
+        VALUES ('pending->closed', 'pending', 'closed', 'pending')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		owner, viewer string
+		want          bool
+	}{
+		{"open", "stranger", true},
+		{"closed", "closed", true},
+		{"closed", "follower", true},
+		{"closed", "pending", false},
+		{"closed", "stranger", false},
+	}
+	for _, tt := range tests {
+		got, err := canViewProfile(db, tt.owner, tt.viewer)
+		if err != nil || got != tt.want {
+			t.Errorf("canViewProfile(%s, %s) = %v, %v; want %v", tt.owner, tt.viewer, got, err, tt.want)
+		}
+	}
+	if _, err := canViewProfile(db, "nobody", "stranger"); err != ErrUserNotFound {
+		t.Errorf("missing owner: %v, want ErrUserNotFound", err)
+	}
+}
+
+func TestPrivateProfilesShowOnlyACard(t *testing.T) {
+	db := newTestDB(t)
+	seedUser(t, db, "closed", false)
+	seedUser(t, db, "follower", true)
+	seedUser(t, db, "stranger", true)
+	seedFollow(t, db, "follower", "closed")
+	users := NewUserService(db)
+
+	if _, err := users.GetUserForViewer("closed", "stranger"); err != ErrPrivateProfile {
+		t.Fatalf("stranger viewing a private profile: %v, want ErrPrivateProfile", err)
+	}
+	user, err := users.GetUserForViewer("closed", "follower")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Email != "" {
+		t.Errorf("follower sees the email address %q", user.Email)
+	}
+	if own, err := users.GetUserForViewer("closed", "closed"); err != nil || own.Email != "closed@example.com" {
+		t.Errorf("owner sees %+v, %v", own, err)
+	}
+
+	card, err := users.GetUserCard("closed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(card)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	if want := []string{"first_name", "id", "is_public", "last_name"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("card fields = %v, want %v", keys, want)
+	}
+
+	profile, err := NewProfileService(db, users, NewPostService(db, nil)).GetProfile("closed", "stranger", PageRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !profile.Restricted || profile.User != nil || profile.Posts != nil || profile.Card == nil {
+		t.Errorf("restricted profile = %+v", profile)
+	}
+	if profile.FollowerCount != 1 || profile.FollowStatus != "not_followed" {
+		t.Errorf("restricted profile counts %d, follow status %q", profile.FollowerCount, profile.FollowStatus)
+	}
+}
+
+func TestFollowListsOfPrivateProfiles(t *testing.T) {
+	db := newTestDB(t)
+	seedUser(t, db, "closed", false)
+	seedUser(t, db, "follower", true)
+	seedUser(t, db, "stranger", true)
+	seedFollow(t, db, "follower", "closed")
+	s := NewFollowerService(db, nil)
+
+	if _, err := s.GetFollowers("closed", "stranger"); err != ErrPrivateProfile {
+		t.Errorf("stranger listing followers: %v, want ErrPrivateProfile", err)
+	}
+	if _, err := s.GetFollowing("closed", "stranger"); err != ErrPrivateProfile {
+		t.Errorf("stranger listing following: %v, want ErrPrivateProfile", err)
+	}
+	followers, err := s.GetFollowers("closed", "follower")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(followers) != 1 || followers[0]["id"] != "follower" {
+		t.Errorf("followers = %v", followers)
+	}
+	if _, err := s.GetFollowers("nobody", "stranger"); err != ErrUserNotFound {
+		t.Errorf("missing user: %v, want ErrUserNotFound", err)
+	}
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+)
+
+var (
+	ErrPostNotFound     = errors.New("post not found")
+	ErrPostAccessDenied = errors.New("unauthorized to view this post")
+)
+
+// postVisibility decides which posts a viewer may see:
+//
+//   - their own posts, always
+//   - public posts, when the author's profile is public or the viewer
+//     follows the author
+//   - private posts, when the viewer follows the author
+//   - almost_private posts, when the author chose the viewer
+//
+// Following means an accepted follow request.
+type postVisibility struct {
+	viewerID string
+}
+
+func newPostVisibility(viewerID string) postVisibility {
+	return postVisibility{viewerID: viewerID}
+}
+
+// condition returns a SQL condition, and its arguments, that holds for the
+// visible rows of the posts table aliased as alias.
+func (v postVisibility) condition(alias string) (string, []interface{}) {
+	follows := `EXISTS (
+                SELECT 1 FROM follow_requests fr
+                WHERE fr.follower_id = ? AND fr.following_id = ` + alias + `.user_id
+                  AND fr.status = 'accepted'
+            )`
+	condition := `(
+            ` + alias + `.user_id = ?
+            OR (` + alias + `.privacy = 'public' AND (
+                EXISTS (SELECT 1 FROM users au WHERE au.id = ` + alias + `.user_id AND au.is_public)
+                OR ` + follows + `
+            ))
+            OR (` + alias + `.privacy = 'private' AND ` + follows + `)
+            OR (` + alias + `.privacy = 'almost_private' AND EXISTS (
+                SELECT 1 FROM post_viewers pv WHERE pv.post_id = ` + alias + `.id AND pv.user_id = ?
+            ))
+        )`
+	return condition, []interface{}{v.viewerID, v.viewerID, v.viewerID, v.viewerID}
+}
+
+// check returns ErrPostNotFound when the post does not exist and
+// ErrPostAccessDenied when the viewer may not see it.
+func (v postVisibility) check(db *sql.DB, postID string) error {
+	condition, args := v.condition("p")
+	var canView bool
+	err := db.QueryRow(`
+        SELECT `+condition+`
+        FROM posts p WHERE p.id = ?`,
+		append(args, postID)...,
+	).Scan(&canView)
+	if err == sql.ErrNoRows {
+		return ErrPostNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if !canView {
+		return ErrPostAccessDenied
+	}
+	return nil
+}
+
+// canViewProfile reports whether viewerID may see the details and posts of
+// ownerID's profile: always for public profiles and their owner, and for
+// private profiles only once the viewer's follow request was accepted.
+func canViewProfile(db *sql.DB, ownerID, viewerID string) (bool, error) {
+	if ownerID == viewerID {
+		return true, nil
+	}
+	var canView bool
+	err := db.QueryRow(`
+        SELECT u.is_public OR EXISTS (
+            SELECT 1 FROM follow_requests fr
+            WHERE fr.follower_id = ? AND fr.following_id = u.id AND fr.status = 'accepted'
+        )
+        FROM users u WHERE u.id = ?`,
+		viewerID, ownerID,
+	).Scan(&canView)
+	if err == sql.ErrNoRows {
+		return false, ErrUserNotFound
+	}
+	return canView, err
+}
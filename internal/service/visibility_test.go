@@ -0,0 +1,84 @@
+package service
+
+import (
+	"testing"
+)
+
+func TestPostVisibility(t *testing.T) {
+	db := newTestDB(t)
+	seedUser(t, db, "open", true)
+	seedUser(t, db, "closed", false)
+	for _, id := range []string{"follower", "chosen", "pending", "stranger"} {
+		seedUser(t, db, id, true)
+	}
+	seedFollow(t, db, "follower", "open")
+	seedFollow(t, db, "follower", "closed")
+	_, err := db.Exec(`
+        INSERT INTO follow_requests (id, follower_id, following_id, status)
+        VALUES ('pending->closed', 'pending', 'closed', 'pending')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	posts := map[string][2]string{
+		"open-public":          {"open", "public"},
+		"open-private":         {"open", "private"},
+		"closed-public":        {"closed", "public"},
+		"closed-private":       {"closed", "private"},
+		"closed-almost":        {"closed", "almost_private"},
+		"closed-almost-nobody": {"closed", "almost_private"},
+	}
+	for id, post := range posts {
+		_, err := db.Exec(`
+            INSERT INTO posts (id, user_id, content, privacy) VALUES (?, ?, 'x', ?)`,
+			id, post[0], post[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := db.Exec(`INSERT INTO post_viewers (post_id, user_id) VALUES ('closed-almost', 'chosen')`); err != nil {
+		t.Fatal(err)
+	}
+
+	visible := map[string][]string{
+		"closed":   {"open-public", "closed-public", "closed-private", "closed-almost", "closed-almost-nobody"},
+		"follower": {"open-public", "open-private", "closed-public", "closed-private"},
+		"chosen":   {"open-public", "closed-almost"},
+		"pending":  {"open-public"},
+		"stranger": {"open-public"},
+	}
+	for viewerID, want := range visible {
+		canSee := make(map[string]bool)
+		for _, id := range want {
+			canSee[id] = true
+		}
+		for postID := range posts {
+			err := newPostVisibility(viewerID).check(db, postID)
+			if canSee[postID] && err != nil {
+				t.Errorf("%s can't see %s: %v", viewerID, postID, err)
+			}
+			if !canSee[postID] && err != ErrPostAccessDenied {
+				t.Errorf("%s seeing %s: %v, want ErrPostAccessDenied", viewerID, postID, err)
+			}
+		}
+
+		// Listings apply the same rules
+		list, err := NewPostService(db, nil).GetPublicPosts(viewerID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		listed := make(map[string]bool)
+		for _, post := range list {
+			listed[post["id"].(string)] = true
+		}
+		for postID := range posts {
+			if listed[postID] != canSee[postID] {
+				t.Errorf("%s: %s listed = %v", viewerID, postID, listed[postID])
+			}
+		}
+	}
+
+	if err := newPostVisibility("stranger").check(db, "missing"); err != ErrPostNotFound {
+		t.Errorf("missing post: %v, want ErrPostNotFound", err)
+	}
+}
@@ -0,0 +1,4 @@
+ALTER TABLE sessions DROP COLUMN last_active_at;
+ALTER TABLE sessions DROP COLUMN ip_address;
+ALTER TABLE sessions DROP COLUMN user_agent;
+ALTER TABLE sessions DROP COLUMN device;
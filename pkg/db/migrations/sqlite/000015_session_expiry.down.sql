@@ -0,0 +1,2 @@
+ALTER TABLE sessions DROP COLUMN remember_me;
+ALTER TABLE sessions DROP COLUMN absolute_expires_at;
@@ -0,0 +1,3 @@
+ALTER TABLE sessions ADD COLUMN absolute_expires_at DATETIME;
+ALTER TABLE sessions ADD COLUMN remember_me BOOLEAN DEFAULT false;
+UPDATE sessions SET absolute_expires_at = expires_at WHERE absolute_expires_at IS NULL;
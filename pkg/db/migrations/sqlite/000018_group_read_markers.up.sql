@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS group_read_markers (
+    group_id TEXT NOT NULL,
+    user_id TEXT NOT NULL,
+    last_read_message_id TEXT NOT NULL,
+    last_read_at DATETIME NOT NULL,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (group_id, user_id),
+    FOREIGN KEY (group_id) REFERENCES groups(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
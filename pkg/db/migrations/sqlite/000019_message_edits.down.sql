@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_message_edits_message;
+DROP TABLE IF EXISTS message_edits;
+ALTER TABLE group_messages DROP COLUMN deleted_at;
+ALTER TABLE group_messages DROP COLUMN edited_at;
+ALTER TABLE messages DROP COLUMN deleted_at;
+ALTER TABLE messages DROP COLUMN edited_at;
@@ -0,0 +1,14 @@
+ALTER TABLE messages ADD COLUMN edited_at DATETIME;
+ALTER TABLE messages ADD COLUMN deleted_at DATETIME;
+ALTER TABLE group_messages ADD COLUMN edited_at DATETIME;
+ALTER TABLE group_messages ADD COLUMN deleted_at DATETIME;
+CREATE TABLE IF NOT EXISTS message_edits (
+    id TEXT PRIMARY KEY,
+    message_id TEXT NOT NULL,
+    message_type TEXT CHECK(message_type IN ('private', 'group')) NOT NULL,
+    previous_content TEXT NOT NULL,
+    edited_by TEXT NOT NULL,
+    edited_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (edited_by) REFERENCES users(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_message_edits_message ON message_edits(message_id);
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS reactions (
+    id TEXT PRIMARY KEY,
+    target_type TEXT CHECK(target_type IN ('post', 'comment', 'group_post', 'group_comment', 'message', 'group_message')) NOT NULL,
+    target_id TEXT NOT NULL,
+    user_id TEXT NOT NULL,
+    emoji TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (target_type, target_id, user_id, emoji),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_reactions_target ON reactions(target_type, target_id);
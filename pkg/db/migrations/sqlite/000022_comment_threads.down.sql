@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_group_post_comments_parent;
+DROP INDEX IF EXISTS idx_post_comments_parent;
+ALTER TABLE group_post_comments DROP COLUMN parent_comment_id;
+ALTER TABLE post_comments DROP COLUMN deleted_at;
+ALTER TABLE post_comments DROP COLUMN parent_comment_id;
@@ -0,0 +1,6 @@
+ALTER TABLE post_comments ADD COLUMN parent_comment_id TEXT REFERENCES post_comments(id) ON DELETE CASCADE;
+ALTER TABLE post_comments ADD COLUMN deleted_at DATETIME;
+ALTER TABLE group_post_comments ADD COLUMN parent_comment_id TEXT REFERENCES group_post_comments(id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS idx_post_comments_parent ON post_comments(parent_comment_id);
+CREATE INDEX IF NOT EXISTS idx_group_post_comments_parent ON group_post_comments(parent_comment_id);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_comment_edits_comment;
+DROP TABLE IF EXISTS comment_edits;
+ALTER TABLE group_post_comments DROP COLUMN edited_at;
+ALTER TABLE post_comments DROP COLUMN edited_at;
@@ -0,0 +1,12 @@
+ALTER TABLE post_comments ADD COLUMN edited_at DATETIME;
+ALTER TABLE group_post_comments ADD COLUMN edited_at DATETIME;
+CREATE TABLE IF NOT EXISTS comment_edits (
+    id TEXT PRIMARY KEY,
+    comment_id TEXT NOT NULL,
+    comment_type TEXT CHECK(comment_type IN ('post', 'group')) NOT NULL,
+    previous_content TEXT NOT NULL,
+    edited_by TEXT NOT NULL,
+    edited_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (edited_by) REFERENCES users(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_comment_edits_comment ON comment_edits(comment_id);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_group_posts_group_created;
+DROP INDEX IF EXISTS idx_posts_user_created;
+DROP VIEW IF EXISTS feed_items;
@@ -0,0 +1,11 @@
+-- Posts and group posts in one list, so the home feed can be paged with a
+-- single (created_at, id) cursor. Ids are UUIDs, so they don't collide.
+CREATE VIEW IF NOT EXISTS feed_items AS
+SELECT 'post' AS type, id, user_id, NULL AS group_id, content, image_path, privacy, created_at, updated_at
+FROM posts
+UNION ALL
+SELECT 'group_post' AS type, id, user_id, group_id, content, image_path, NULL AS privacy, created_at, updated_at
+FROM group_posts;
+
+CREATE INDEX IF NOT EXISTS idx_posts_user_created ON posts(user_id, created_at, id);
+CREATE INDEX IF NOT EXISTS idx_group_posts_group_created ON group_posts(group_id, created_at, id);
@@ -0,0 +1,3 @@
+UPDATE message_attachments
+SET path = '/uploads/chat/' || substr(path, length('/attachments/') + 1)
+WHERE path LIKE '/attachments/%';
@@ -0,0 +1,5 @@
+-- Chat attachments moved out of the public uploads directory and are served
+-- under /attachments/ to participants only.
+UPDATE message_attachments
+SET path = '/attachments/' || substr(path, length('/uploads/chat/') + 1)
+WHERE path LIKE '/uploads/chat/%';
@@ -0,0 +1,2 @@
+-- The deleted edit history can't be restored.
+SELECT 1;
@@ -0,0 +1,3 @@
+-- Deleting a post used to leave the edit history of its comments behind.
+DELETE FROM comment_edits
+WHERE comment_type = 'post' AND comment_id NOT IN (SELECT id FROM post_comments);
@@ -85,9 +85,16 @@ function NotificationSidebar({
       if (onOpenChat) {
         onOpenChat(); 
       }
-    } else if (notification.type === 'follow_request') {
+    } else if (
+      notification.type === 'follow_request' ||
+      notification.type === 'follow_accepted' ||
+      notification.type === 'new_follower'
+    ) {
       navigate('/followers');
-    } else if (notification.type === 'group_invite') {
+    } else if (
+      notification.type === 'group_invite' ||
+      notification.type === 'group_request_accepted'
+    ) {
       navigate('/your-groups');
     }
 
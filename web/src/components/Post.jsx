@@ -437,6 +437,11 @@ function Post({ post, onPostUpdate, onPostDelete }) {
               No comments found for you.
             </Typography>
           )}
+          {post.comment_count > (post.comments?.length || 0) && (
+            <Typography variant="caption" sx={{ color: '#90caf9' }}>
+              Showing the latest {post.comments?.length || 0} of {post.comment_count} comments
+            </Typography>
+          )}
         </Box>
       </Box>
       
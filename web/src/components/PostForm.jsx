@@ -268,7 +268,7 @@ function PostForm() {
           required
         >
           <MenuItem value="public">Public</MenuItem>
-          <MenuItem value="private">Private</MenuItem>
+          <MenuItem value="private">Followers Only</MenuItem>
           <MenuItem value="almost_private">Partially Private</MenuItem>
         </Select>
       </FormControl>
@@ -21,7 +21,7 @@ const Member = ({ members }) => {
               >
                 {`${member.first_name} ${member.last_name}`} <br />
                 <Typography variant="caption" sx={{ color: "#b0bec5" }}>
-                  {member.email}
+                  {member.nickname && `@${member.nickname}`}
                 </Typography>
               </Paper>
             </Grid>
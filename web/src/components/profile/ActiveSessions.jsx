@@ -0,0 +1,83 @@
+import React, { useEffect, useState } from "react";
+import { Paper, Typography, List, ListItem, ListItemText, Button, Box } from "@mui/material";
+import { getSessions, revokeSession, revokeOtherSessions } from "../../service/session";
+
+const ActiveSessions = () => {
+  const [sessions, setSessions] = useState([]);
+
+  const fetchSessions = async () => {
+    try {
+      const res = await getSessions();
+      setSessions(Array.isArray(res.data) ? res.data : []);
+    } catch (error) {
+      console.error("Error fetching sessions:", error);
+    }
+  };
+
+  useEffect(() => {
+    fetchSessions();
+  }, []);
+
+  const handleRevoke = async (sessionId) => {
+    try {
+      await revokeSession(sessionId);
+      fetchSessions();
+    } catch (error) {
+      console.error("Error revoking session:", error);
+      alert("Failed to log out the session.");
+    }
+  };
+
+  const handleRevokeOthers = async () => {
+    try {
+      await revokeOtherSessions();
+      fetchSessions();
+    } catch (error) {
+      console.error("Error revoking sessions:", error);
+      alert("Failed to log out other sessions.");
+    }
+  };
+
+  return (
+    <Paper
+      sx={{
+        padding: 3,
+        marginTop: 3,
+        backgroundColor: "#1f1f1f",
+        color: "#ffffff",
+        borderRadius: 3,
+      }}
+    >
+      <Box sx={{ display: "flex", justifyContent: "space-between", alignItems: "center" }}>
+        <Typography variant="h6">Active Sessions</Typography>
+        {sessions.length > 1 && (
+          <Button variant="outlined" color="error" onClick={handleRevokeOthers}>
+            Log out all other sessions
+          </Button>
+        )}
+      </Box>
+      <List>
+        {sessions.map((session) => (
+          <ListItem
+            key={session.id}
+            secondaryAction={
+              !session.current && (
+                <Button color="error" onClick={() => handleRevoke(session.id)}>
+                  Log out
+                </Button>
+              )
+            }
+          >
+            <ListItemText
+              primary={`${session.device}${session.current ? " (this device)" : ""}`}
+              secondary={`${session.ip_address} - last active ${new Date(session.last_active_at).toLocaleString()}`}
+              secondaryTypographyProps={{ sx: { color: "#b0bec5" } }}
+            />
+          </ListItem>
+        ))}
+      </List>
+    </Paper>
+  );
+};
+
+export default ActiveSessions;
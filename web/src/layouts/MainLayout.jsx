@@ -48,9 +48,11 @@ function MainLayout({ children }) {
     if (!user) return;
 
     if (message.type === 'notifications') {
-      setNotifications(message.content);
+      const payload = message.payload ?? message.content;
+      const list = Array.isArray(payload) ? payload : payload?.notifications || [];
+      setNotifications(list);
       if (!isNotificationSidebarOpen) {
-        const hasUnread = message.content.some(notif => !notif.is_read);
+        const hasUnread = list.some(notif => !notif.is_read);
         setHasUnreadNotifications(hasUnread);
       }
       return;
@@ -0,0 +1,12 @@
+import axios from "axios";
+
+const API = axios.create({
+  baseURL: "http://localhost:8080",
+  withCredentials: true,
+});
+
+// Active sessions of the logged in user
+export const getSessions = () => API.get("/sessions");
+export const revokeSession = (sessionId) =>
+  API.post("/sessions/revoke", { session_id: sessionId });
+export const revokeOtherSessions = () => API.post("/sessions/revoke-others");
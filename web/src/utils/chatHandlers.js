@@ -66,6 +66,7 @@ export const handleWebSocketSetup = (
             handleGroupMessageHistory(message, selectedUser, user, setMessages);
             break;
         case "new_private_message":
+        case "private_message":
             handleNewPrivateMessage(
                 message,
                 selectedUser,
@@ -88,6 +89,13 @@ export const handleWebSocketSetup = (
                 setHasUnreadMessages
             );
             break;
+        case "message_edited":
+        case "message_deleted":
+            handleMessageUpdate(message, setMessages);
+            break;
+        case "reaction":
+            handleReaction(message, user, setMessages);
+            break;
         case "unread_messages":
             handleUnreadMessages(
                 message,
@@ -103,19 +111,17 @@ export const handleWebSocketSetup = (
   
   const handlePrivateMessageHistory = (message, selectedUser, user, setMessages) => {
     if (selectedUser?.type === "private") {
-      const messagesArray = [...(message.content.sent || []), ...(message.content.received || [])];
-      const data = messagesArray.map((msg) => ({
+      const data = (message.payload?.messages || []).map((msg) => ({
         ...msg,
-        isSent: msg.sender_id === user.user_id,
+        isSent: msg.direction === "sent",
       }));
-      data.sort((a, b) => new Date(a.created_at) - new Date(b.created_at));
       setMessages(data);
     }
   };
   
   const handleGroupMessageHistory = (message, selectedUser, user, setMessages) => {
     if (selectedUser?.type === "group") {
-        const messagesArray = message.content || [];
+        const messagesArray = message.payload?.messages || [];
         const data = messagesArray.map((msg) => ({
             ...msg,
             isSent: msg.sender_id === user.user_id,
@@ -139,7 +145,7 @@ const handleNewPrivateMessage = (
   setUnreadCounts,
   setHasUnreadMessages
 ) => {
-  const { sender_id, recipient_id } = message.content;
+  const { sender_id, recipient_id } = message.payload;
 
   if (selectedUser?.type === "private") {
     const isRelevantMessage =
@@ -148,7 +154,7 @@ const handleNewPrivateMessage = (
 
     if (isRelevantMessage) {
       const newMsg = {
-        ...message.content,
+        ...message.payload,
         isSent: sender_id === user.user_id,
       };
       setMessages((prev) => [...prev, newMsg]);
@@ -181,11 +187,11 @@ const handleNewGroupMessage = (
   setUnreadCounts,
   setHasUnreadMessages
 ) => {
-  const { group_id, sender_id } = message.content;
+  const { group_id, sender_id } = message.payload;
 
   if (selectedUser?.type === "group" && selectedUser.id === group_id) {
     const newMsg = {
-      ...message.content,
+      ...message.payload,
       isSent: sender_id === user.user_id,
     };
     setMessages((prev) => [...prev, newMsg]);
@@ -202,6 +208,32 @@ const handleNewGroupMessage = (
   }
 };
   
+  const handleMessageUpdate = (message, setMessages) => {
+    const updated = message.payload;
+    if (!updated?.id) return;
+    setMessages((prev) =>
+      prev.map((msg) => (msg.id === updated.id ? { ...msg, ...updated } : msg))
+    );
+  };
+
+  const handleReaction = (message, user, setMessages) => {
+    const event = message.payload;
+    if (event?.target_type !== "message" && event?.target_type !== "group_message") return;
+    setMessages((prev) =>
+      prev.map((msg) => {
+        if (msg.id !== event.target_id) return msg;
+        // Events don't carry the viewer's own reactions, so keep them from the
+        // previous summary
+        const reacted = new Set((msg.reactions || []).filter((r) => r.reacted).map((r) => r.emoji));
+        if (event.user_id === user.user_id) {
+          event.added ? reacted.add(event.emoji) : reacted.delete(event.emoji);
+        }
+        const reactions = (event.reactions || []).map((r) => ({ ...r, reacted: reacted.has(r.emoji) }));
+        return { ...msg, reactions };
+      })
+    );
+  };
+
   const handleUnreadMessages = (
     message,
     selectedUser,
@@ -209,7 +241,7 @@ const handleNewGroupMessage = (
     setUnreadCounts,
     setHasUnreadMessages
   ) => {
-    const senderList = Array.isArray(message.content.senders) ? message.content.senders : [];
+    const senderList = Array.isArray(message.payload.senders) ? message.payload.senders : [];
     
     setUnreadCounts((prev) => {
       const newCounts = { ...prev };
@@ -218,6 +250,11 @@ const handleNewGroupMessage = (
           newCounts[senderId] = 1;
         }
       });
+      Object.entries(message.payload.groups || {}).forEach(([groupId, count]) => {
+        if (!selectedUser || selectedUser.id !== groupId || !isChatSidebarOpen) {
+          newCounts[groupId] = count;
+        }
+      });
       const totalUnread = Object.values(newCounts).reduce((a, b) => a + b, 0);
       setHasUnreadMessages(totalUnread > 0);
       return newCounts;
@@ -40,22 +40,26 @@ export const handleWebSocketNotifications = (
     setNotifications,
     setHasUnreadNotifications
   ) => {
-    if (message.type === "notifications_list") {
+    if (message.type === "notifications_list" || message.type === "notifications") {
       handleNotificationsList(message, setNotifications, setHasUnreadNotifications);
-    } else if (message.type === "new_notification") {
+    } else if (message.type === "new_notification" || message.type === "notification") {
       handleNewNotification(message, setNotifications, setHasUnreadNotifications);
+    } else if (message.type === "notifications_removed") {
+      handleRemovedNotifications(message, setNotifications, setHasUnreadNotifications);
     }
   };
   
   const handleNotificationsList = (message, setNotifications, setHasUnreadNotifications) => {
-    const notifications = message.content.notifications || [];
+    const notifications = Array.isArray(message.payload)
+      ? message.payload
+      : message.payload?.notifications || [];
     setNotifications(notifications);
     const hasUnread = notifications.some(notification => !notification.read);
     setHasUnreadNotifications(hasUnread);
   };
   
   const handleNewNotification = (message, setNotifications, setHasUnreadNotifications) => {
-    const newNotification = message.content;
+    const newNotification = message.payload;
     setNotifications(prev => {
       const updated = [newNotification, ...prev];
       const hasUnread = updated.some(notification => !notification.read);
@@ -64,6 +68,16 @@ export const handleWebSocketNotifications = (
     });
   };
   
+  const handleRemovedNotifications = (message, setNotifications, setHasUnreadNotifications) => {
+    const removed = new Set(message.payload?.notification_ids || []);
+    setNotifications(prev => {
+      const updated = prev.filter(notification => !removed.has(notification.id));
+      const hasUnread = updated.some(notification => !notification.read);
+      setHasUnreadNotifications(hasUnread);
+      return updated;
+    });
+  };
+
   export const formatNotificationTimestamp = (timestamp) => {
     const date = new Date(timestamp);
     const now = new Date();
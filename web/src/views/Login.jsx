@@ -1,6 +1,6 @@
 import { useState } from 'react';
 import { useNavigate, useLocation, Link as RouterLink } from 'react-router-dom';
-import { Box, Button, Container, TextField, Typography, Paper, Link } from '@mui/material';
+import { Box, Button, Container, TextField, Typography, Paper, Link, FormControlLabel, Checkbox } from '@mui/material';
 import { useAuth } from '../context/AuthContext';
 import { useAxios } from '../utils/axiosInstance';
 
@@ -11,6 +11,7 @@ function Login() {
   const location = useLocation();
   const [email, setEmail] = useState('');
   const [password, setPassword] = useState('');
+  const [rememberMe, setRememberMe] = useState(false);
   const [error, setError] = useState(null);
 
   const handleLogin = async () => {
@@ -20,6 +21,7 @@ function Login() {
       const response = await axios.post('/login', {
         email,
         password,
+        remember_me: rememberMe,
       });
 
       console.log('Raw response:', response);
@@ -121,6 +123,17 @@ function Login() {
               sx: { color: 'grey.400' },
             }}
           />
+          <FormControlLabel
+            control={
+              <Checkbox
+                checked={rememberMe}
+                onChange={(e) => setRememberMe(e.target.checked)}
+                sx={{ color: 'grey.400' }}
+              />
+            }
+            label="Remember me"
+            sx={{ color: 'grey.400' }}
+          />
           {error && <Typography color="error">{error}</Typography>}
           <Button
             variant="contained"
@@ -9,6 +9,7 @@ import AboutSection from "../components/profile/AboutSection";
 import UserPosts from "../components/profile/UserPosts";
 import ConnectionsList from "../components/profile/ConnectionsList";
 import GroupInviteModal from "../components/profile/GroupInviteModal";
+import ActiveSessions from "../components/profile/ActiveSessions";
 
 function ProfilePage() {
   const { identifier } = useParams();
@@ -56,7 +57,7 @@ function ProfilePage() {
         );
         if (!res.ok) throw new Error("Failed to fetch user posts");
         const data = await res.json();
-        setUserPosts(data || []);
+        setUserPosts(data?.posts || []);
       } catch (err) {
         console.error("Error fetching posts:", err);
       }
@@ -299,6 +300,8 @@ function ProfilePage() {
           following={following}
           canViewFullProfile={canViewFullProfile}
         />
+
+        {isOwnProfile && <ActiveSessions />}
         
         <GroupInviteModal
           open={modalOpen}
@@ -23,7 +23,7 @@ function AllUsersPage() {
     useEffect(() => {
         async function fetchUsers() {
             try {
-                const res = await fetch("http://localhost:8080/users");
+                const res = await fetch("http://localhost:8080/users", { credentials: "include" });
                 if (!res.ok) {
                     console.error("Failed to fetch users:", res.status, res.statusText);
                     return;